@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/sirupsen/logrus"
+	"os"
 	"store_api/internal/app"
 	"store_api/internal/config"
 )
@@ -11,7 +12,21 @@ func main() {
 	if err != nil {
 		logrus.Panic(err)
 	}
-	server := app.NewStoreWebApi()
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(cfg, os.Args[2:])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	server, err := app.NewStoreWebApi(cfg)
+	if err != nil {
+		logrus.Panic(err)
+	}
 	err = server.StartApp()
 	if err != nil {
 		logrus.Panic(err)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"store_api/internal/app"
+	"store_api/internal/config"
+	"strconv"
+)
+
+const migrateUsage = `usage: store_api migrate <command>
+  up          apply all pending migrations
+  down N      roll back N last applied migrations
+  status      show current schema version and migrations
+  force V     set schema version to V without running migrations`
+
+// runMigrate - выполнение подкоманды migrate с аргументами args
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := app.NewMigrator(cfg.DB)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("migrate down: N must be a positive number, got %q", args[1])
+		}
+		return migrator.Down(ctx, n)
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate force: bad version %q", args[1])
+		}
+		return migrator.Force(ctx, version)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d, dirty: %t\n", status.Version, status.Dirty)
+		for _, m := range status.Migrations {
+			state := "pending"
+			if m.Version <= status.Version {
+				state = "applied"
+			}
+			fmt.Printf("%06d_%s\t%s\n", m.Version, m.Name, state)
+		}
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
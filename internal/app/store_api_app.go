@@ -1,15 +1,87 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"store_api/internal/config"
 	"store_api/internal/controller/http"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/service"
+	"store_api/internal/migrate"
+	"store_api/internal/repository"
+	"store_api/internal/repository/memory"
+	"store_api/internal/repository/postgresql"
+	"store_api/migrations"
 )
 
 type StoreWebApiApp struct {
 	router *http.ApiServer
 }
 
-func NewStoreWebApi() *StoreWebApiApp {
-	return &StoreWebApiApp{router: http.NewApiServer()}
+// NewStoreWebApi - сборка приложения: репозиторий, сервис, хэндлеры и http сервер
+func NewStoreWebApi(cfg *config.Config) (*StoreWebApiApp, error) {
+	err := models.SetMoneyFormat(cfg.Money.Format, cfg.Money.DefaultCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("[NewStoreWebApi]: %v", err)
+	}
+	auth, err := http.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("[NewStoreWebApi]: %v", err)
+	}
+	repo, err := newStoreRepository(cfg.DB)
+	if err != nil {
+		return nil, fmt.Errorf("[NewStoreWebApi]: %v", err)
+	}
+	validator, err := http.NewValidator()
+	if err != nil {
+		return nil, fmt.Errorf("[NewStoreWebApi]: %v", err)
+	}
+	handlers := http.NewApiHandlers(service.NewStore(repo), validator)
+	return &StoreWebApiApp{router: http.NewApiServer(cfg, handlers, auth)}, nil
+}
+
+// newStoreRepository - создание репозитория, выбранного в конфиге параметром db.driver
+func newStoreRepository(cfg config.DB) (repository.StoreRepository, error) {
+	switch cfg.Driver {
+	case "", "postgres":
+		db, err := postgresql.Connect(cfg.Connection)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AutoMigrate {
+			err = migrateUp(db)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return postgresql.NewStoreRepository(db, cfg)
+	case "memory":
+		return memory.NewStoreRepository(), nil
+	}
+	return nil, fmt.Errorf("unknown db driver %q", cfg.Driver)
+}
+
+// NewMigrator - подключение к БД из конфига и создание Migrator для встроенных миграций
+func NewMigrator(cfg config.DB) (*migrate.Migrator, error) {
+	db, err := postgresql.Connect(cfg.Connection)
+	if err != nil {
+		return nil, fmt.Errorf("[NewMigrator]: %v", err)
+	}
+	migrator, err := migrate.New(db.DB, migrations.Postgres, "postgresql")
+	if err != nil {
+		return nil, fmt.Errorf("[NewMigrator]: %v", err)
+	}
+	return migrator, nil
+}
+
+// migrateUp - применение встроенных миграций при старте, если включён db.auto_migrate
+func migrateUp(db *sqlx.DB) error {
+	migrator, err := migrate.New(db.DB, migrations.Postgres, "postgresql")
+	if err != nil {
+		return err
+	}
+	return migrator.Up(context.Background())
 }
 
 func (a StoreWebApiApp) StartApp() error {
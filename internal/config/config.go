@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// Config - конфигурация приложения
+type Config struct {
+	DB     DB     `mapstructure:"db"`
+	Server Server `mapstructure:"server"`
+	Money  Money  `mapstructure:"money"`
+	Auth   Auth   `mapstructure:"auth"`
+}
+
+// DB - настройки подключения к БД и работы с транзакциями
+type DB struct {
+	// Driver - реализация репозитория: postgres (по умолчанию) или memory
+	Driver       string        `mapstructure:"driver"`
+	Connection   string        `mapstructure:"connection"`
+	TxIsolation  string        `mapstructure:"tx_isolation"`
+	TxMaxRetries int           `mapstructure:"tx_max_retries"`
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
+	// BulkTimeout - таймаут импорта и экспорта каталога вместо QueryTimeout, 0 - без ограничения
+	BulkTimeout time.Duration `mapstructure:"bulk_timeout"`
+	// AutoMigrate - применять встроенные миграции при старте приложения
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+}
+
+// Server - настройки http сервера
+type Server struct {
+	Host string `mapstructure:"host"`
+	// ImportMaxBytes - максимальный размер тела запроса импорта каталога в байтах, 0 - без ограничения
+	ImportMaxBytes int64 `mapstructure:"import_max_bytes"`
+}
+
+// Money - настройки денежных сумм
+type Money struct {
+	// Format - вывод сумм в JSON: decimal ("499.99"), number (499.99) или minor (49999)
+	Format string `mapstructure:"format"`
+	// DefaultCurrency - валюта сумм, переданных клиентом без валюты
+	DefaultCurrency string `mapstructure:"default_currency"`
+}
+
+// Auth - ключи проверки bearer токенов (JWT). Токены HS256 проверяются общим ключом,
+// RS256 - открытым ключом из конфига или из JWKS файла
+type Auth struct {
+	// Secret - общий ключ HS256, не короче 32 байт. Задаётся переменной окружения STORE_AUTH_SECRET
+	Secret string `mapstructure:"secret"`
+	// PublicKey - открытый ключ RS256 в формате PEM
+	PublicKey string `mapstructure:"public_key"`
+	// JWKSFile - путь к локальному JWKS файлу, ключ выбирается по kid токена
+	JWKSFile string `mapstructure:"jwks_file"`
+	// Issuer - ожидаемый claim iss, не проверяется, если пуст
+	Issuer string `mapstructure:"issuer"`
+	// Audience - ожидаемый claim aud, не проверяется, если пуст
+	Audience string `mapstructure:"audience"`
+}
+
+// Load - чтение конфигурации, загруженной в viper, в структуру Config
+func Load() (*Config, error) {
+	cfg := &Config{}
+	err := viper.Unmarshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[Load]: failed to unmarshal config. Error: %v", err)
+	}
+	return cfg, nil
+}
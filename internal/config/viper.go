@@ -2,9 +2,16 @@ package config
 
 import "github.com/spf13/viper"
 
+// SecretEnv - переменная окружения с общим ключом HS256, ключ не хранится в конфиге репозитория
+const SecretEnv = "STORE_AUTH_SECRET"
+
 // InitViper - инициализация viper для чтения конфигов
 func InitViper() error {
 	viper.AddConfigPath("internal/config")
 	viper.SetConfigName("configs")
+	err := viper.BindEnv("auth.secret", SecretEnv)
+	if err != nil {
+		return err
+	}
 	return viper.ReadInConfig()
 }
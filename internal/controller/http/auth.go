@@ -0,0 +1,261 @@
+package http
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	jsoniter "github.com/json-iterator/go"
+	"math/big"
+	"net/http"
+	"os"
+	"store_api/internal/config"
+	"strings"
+)
+
+const (
+	// roleCustomer - роль покупателя, subject его токена - customer_id
+	roleCustomer = "customer"
+	// roleAdmin - роль сотрудника магазина, управляющего каталогом и заказами
+	roleAdmin = "admin"
+)
+
+// hmacMinSecret - минимальная длина общего ключа HS256 в байтах
+const hmacMinSecret = 32
+
+// principal - пользователь запроса: subject и роли из проверенного токена
+type principal struct {
+	Subject string
+	Roles   []string
+}
+
+// hasRole - есть ли у пользователя роль role
+func (p *principal) hasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey - ключ пользователя в контексте запроса
+type principalKey struct{}
+
+// principalFrom - пользователь из контекста запроса, nil, если запрос не прошёл authenticate
+func principalFrom(ctx context.Context) *principal {
+	user, _ := ctx.Value(principalKey{}).(*principal)
+	return user
+}
+
+// tokenClaims - claims токена, роли передаются в claim roles
+type tokenClaims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+// verifyKey - ключ проверки подписи, kid пуст у ключей из конфига и у ключей JWKS без kid
+type verifyKey struct {
+	kid string
+	key interface{}
+}
+
+// Authenticator - проверка bearer токенов HS256 и RS256 ключами из конфига и JWKS файла
+type Authenticator struct {
+	keys     []verifyKey
+	parser   *jwt.Parser
+	issuer   string
+	audience string
+}
+
+// NewAuthenticator - загрузка ключей проверки токенов, нужен хотя бы один ключ
+func NewAuthenticator(cfg config.Auth) (*Authenticator, error) {
+	auth := &Authenticator{
+		parser:   jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()})),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+	if cfg.Secret != "" {
+		if len(cfg.Secret) < hmacMinSecret {
+			return nil, fmt.Errorf("[NewAuthenticator]: auth.secret must be at least %d bytes", hmacMinSecret)
+		}
+		auth.keys = append(auth.keys, verifyKey{key: []byte(cfg.Secret)})
+	}
+	if cfg.PublicKey != "" {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(cfg.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("[NewAuthenticator]: failed to parse auth.public_key. Error: %v", err)
+		}
+		auth.keys = append(auth.keys, verifyKey{key: key})
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("[NewAuthenticator]: %v", err)
+		}
+		auth.keys = append(auth.keys, keys...)
+	}
+	if len(auth.keys) == 0 {
+		return nil, fmt.Errorf(
+			"[NewAuthenticator]: no token keys, set %s, auth.public_key or auth.jwks_file",
+			config.SecretEnv,
+		)
+	}
+	return auth, nil
+}
+
+// jwk - ключ JWKS файла (RFC 7517): RSA ключ с полями n и e или общий ключ (oct) с полем k
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// loadJWKS - чтение ключей подписи из JWKS файла. Ключи шифрования и ключи других алгоритмов пропускаются
+func loadJWKS(path string) ([]verifyKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks file: %v", err)
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err = jsoniter.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal jwks file: %v", err)
+	}
+
+	keys := make([]verifyKey, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA" && (k.Alg == "" || k.Alg == jwt.SigningMethodRS256.Alg()):
+			key, err := k.rsaKey()
+			if err != nil {
+				return nil, fmt.Errorf("jwks key %d: %v", i, err)
+			}
+			keys = append(keys, verifyKey{kid: k.Kid, key: key})
+		case k.Kty == "oct" && (k.Alg == "" || k.Alg == jwt.SigningMethodHS256.Alg()):
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil || len(secret) < hmacMinSecret {
+				return nil, fmt.Errorf("jwks key %d: k must be a base64url key of at least %d bytes", i, hmacMinSecret)
+			}
+			keys = append(keys, verifyKey{kid: k.Kid, key: secret})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks file %s has no HS256 or RS256 signing keys", path)
+	}
+	return keys, nil
+}
+
+// rsaKey - открытый RSA ключ из полей n и e
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("n must be a base64url integer")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("e must be a base64url integer")
+	}
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+}
+
+// keyFunc - ключ проверки подписи токена. Тип ключа должен соответствовать алгоритму токена,
+// чтобы открытый RSA ключ нельзя было использовать как общий ключ HS256
+func (a *Authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, k := range a.keys {
+		if kid != "" && k.kid != kid {
+			continue
+		}
+		switch k.key.(type) {
+		case []byte:
+			if token.Method == jwt.SigningMethodHS256 {
+				return k.key, nil
+			}
+		case *rsa.PublicKey:
+			if token.Method == jwt.SigningMethodRS256 {
+				return k.key, nil
+			}
+		}
+	}
+	if kid != "" {
+		return nil, fmt.Errorf("no %s key with kid %q", token.Method.Alg(), kid)
+	}
+	return nil, fmt.Errorf("no %s key", token.Method.Alg())
+}
+
+// verify - проверка подписи и claims токена. Токен должен содержать sub и exp
+func (a *Authenticator) verify(raw string) (*principal, error) {
+	claims := &tokenClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, a.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token has no sub claim")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("token has no exp claim")
+	}
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, fmt.Errorf("token issuer %q is not accepted", claims.Issuer)
+	}
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return nil, fmt.Errorf("token audience %v is not accepted", claims.Audience)
+	}
+	return &principal{Subject: claims.Subject, Roles: claims.Roles}, nil
+}
+
+// authenticate - проверка bearer токена из заголовка Authorization. Пользователь токена кладётся
+// в контекст запроса, запрос без действительного токена отклоняется с 401
+func authenticate(auth *Authenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		scheme, raw, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(raw) == "" {
+			ctx.Header("WWW-Authenticate", `Bearer realm="store_api"`)
+			catchErrGin(ctx, http.StatusUnauthorized, "Bearer token required", fmt.Errorf(
+				"[authenticate]: no bearer token in Authorization header"))
+			return
+		}
+
+		user, err := auth.verify(strings.TrimSpace(raw))
+		if err != nil {
+			ctx.Header("WWW-Authenticate", `Bearer realm="store_api", error="invalid_token"`)
+			catchErrGin(ctx, http.StatusUnauthorized, "Invalid bearer token", fmt.Errorf(
+				"[authenticate]: %v", err))
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), principalKey{}, user))
+		ctx.Next()
+	}
+}
+
+// requireRole - пропускает только запросы пользователя с ролью role, остальные отклоняются с 403.
+// Подключается после authenticate
+func requireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := principalFrom(ctx.Request.Context())
+		if user == nil || !user.hasRole(role) {
+			catchErrGin(ctx, http.StatusForbidden, fmt.Sprintf("The %s role required", role), fmt.Errorf(
+				"[requireRole]: request without %s role", role))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,315 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	jsoniter "github.com/json-iterator/go"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"store_api/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "store-api-test-secret-0123456789abcdef"
+
+// testRSAKey - ключ RS256 для подписи тестовых токенов
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// publicKeyPEM - открытый ключ в формате PEM, как в auth.public_key
+func publicKeyPEM(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// claims - действительные claims покупателя 1, изменяются через fn
+func claims(fn func(c jwt.MapClaims)) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub":   "1",
+		"roles": []string{roleCustomer},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	if fn != nil {
+		fn(c)
+	}
+	return c
+}
+
+// sign - токен с claims c, подписанный методом method ключом key, kid не указывается, если пуст
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, c jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// writeJWKS - JWKS файл с ключами keys во временной директории теста
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := jsoniter.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, data, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rsaJWK - открытый RSA ключ в формате JWK
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	key := testRSAKey(t)
+	tests := []struct {
+		name    string
+		cfg     config.Auth
+		wantErr bool
+	}{
+		{"no keys", config.Auth{}, true},
+		{"short secret", config.Auth{Secret: "short"}, true},
+		{"secret", config.Auth{Secret: testSecret}, false},
+		{"invalid public key", config.Auth{PublicKey: "not a pem"}, true},
+		{"public key", config.Auth{PublicKey: publicKeyPEM(t, key)}, false},
+		{"missing jwks file", config.Auth{JWKSFile: filepath.Join(t.TempDir(), "missing.json")}, true},
+	}
+	for _, tt := range tests {
+		_, err := NewAuthenticator(tt.cfg)
+		if tt.wantErr && err == nil {
+			t.Errorf("NewAuthenticator(%s): expected error", tt.name)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("NewAuthenticator(%s): %v", tt.name, err)
+		}
+	}
+}
+
+func TestAuthenticatorVerify(t *testing.T) {
+	key := testRSAKey(t)
+	other := testRSAKey(t)
+	hs := func(c jwt.MapClaims) string { return sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", c) }
+	rs := func(c jwt.MapClaims) string { return sign(t, jwt.SigningMethodRS256, key, "", c) }
+	none := sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims(nil))
+	// Токен HS256, подписанный открытым RSA ключом как общим ключом
+	confused := sign(t, jwt.SigningMethodHS256, []byte(publicKeyPEM(t, key)), "", claims(nil))
+
+	hsAuth, err := NewAuthenticator(config.Auth{Secret: testSecret, Issuer: "store", Audience: "store_api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsAuth, err := NewAuthenticator(config.Auth{PublicKey: publicKeyPEM(t, key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func(c jwt.MapClaims) {
+		c["iss"] = "store"
+		c["aud"] = "store_api"
+	}
+
+	tests := []struct {
+		name  string
+		auth  *Authenticator
+		token string
+		valid bool
+	}{
+		{"hs256", hsAuth, hs(claims(valid)), true},
+		{"rs256", rsAuth, rs(claims(nil)), true},
+		{"alg none", hsAuth, none, false},
+		{"alg none against rs256", rsAuth, none, false},
+		{"hs256 against rs256 config", rsAuth, hs(claims(nil)), false},
+		{"hs256 signed with rsa public key", rsAuth, confused, false},
+		{"rs256 against hs256 config", hsAuth, rs(claims(valid)), false},
+		{"rs256 signed with another key", rsAuth, sign(t, jwt.SigningMethodRS256, other, "", claims(nil)), false},
+		{"wrong secret", hsAuth, sign(t, jwt.SigningMethodHS256, []byte(testSecret+"x"), "", claims(valid)), false},
+		{"no sub", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); delete(c, "sub") })), false},
+		{"no exp", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); delete(c, "exp") })), false},
+		{"expired", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); c["exp"] = time.Now().Add(-time.Minute).Unix() })), false},
+		{"no iss", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); delete(c, "iss") })), false},
+		{"wrong iss", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); c["iss"] = "evil" })), false},
+		{"no aud", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); delete(c, "aud") })), false},
+		{"wrong aud", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); c["aud"] = "other_api" })), false},
+		{"aud list", hsAuth, hs(claims(func(c jwt.MapClaims) { valid(c); c["aud"] = []string{"other_api", "store_api"} })), true},
+		{"garbage", hsAuth, "not.a.token", false},
+	}
+	for _, tt := range tests {
+		user, err := tt.auth.verify(tt.token)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("verify(%s): expected token to be rejected, got %+v", tt.name, user)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("verify(%s): %v", tt.name, err)
+			continue
+		}
+		if user.Subject != "1" || !user.hasRole(roleCustomer) || user.hasRole(roleAdmin) {
+			t.Errorf("verify(%s): unexpected user %+v", tt.name, user)
+		}
+	}
+}
+
+func TestAuthenticatorJWKS(t *testing.T) {
+	key := testRSAKey(t)
+	rotated := testRSAKey(t)
+	secret := []byte(testSecret)
+	path := writeJWKS(t,
+		rsaJWK("k1", key),
+		rsaJWK("k2", rotated),
+		map[string]string{"kty": "oct", "kid": "h1", "alg": "HS256", "k": base64.RawURLEncoding.EncodeToString(secret)},
+		// Ключи шифрования и ключи других алгоритмов пропускаются
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256"},
+	)
+	auth, err := NewAuthenticator(config.Auth{JWKSFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auth.keys) != 3 {
+		t.Fatalf("NewAuthenticator: expected 3 signing keys from jwks, got %d", len(auth.keys))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rs256 by kid", sign(t, jwt.SigningMethodRS256, key, "k1", claims(nil)), true},
+		{"rotated rs256 by kid", sign(t, jwt.SigningMethodRS256, rotated, "k2", claims(nil)), true},
+		{"rs256 without kid", sign(t, jwt.SigningMethodRS256, key, "", claims(nil)), true},
+		{"hs256 by kid", sign(t, jwt.SigningMethodHS256, secret, "h1", claims(nil)), true},
+		{"rs256 with kid of another key", sign(t, jwt.SigningMethodRS256, key, "k2", claims(nil)), false},
+		{"rs256 with kid of hs256 key", sign(t, jwt.SigningMethodRS256, key, "h1", claims(nil)), false},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, key, "k9", claims(nil)), false},
+		{"encryption key kid", sign(t, jwt.SigningMethodRS256, key, "enc", claims(nil)), false},
+	}
+	for _, tt := range tests {
+		_, err := auth.verify(tt.token)
+		if tt.valid && err != nil {
+			t.Errorf("verify(%s): %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("verify(%s): expected token to be rejected", tt.name)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		keys []map[string]string
+	}{
+		{"no signing keys", []map[string]string{{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}}},
+		{"short oct key", []map[string]string{{"kty": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte("short"))}}},
+		{"rsa key without n", []map[string]string{{"kty": "RSA", "e": "AQAB"}}},
+		{"rsa key with long e", []map[string]string{{"kty": "RSA", "n": "AQAB", "e": "AQABAQAB"}}},
+	}
+	for _, tt := range invalid {
+		_, err := NewAuthenticator(config.Auth{JWKSFile: writeJWKS(t, tt.keys...)})
+		if err == nil {
+			t.Errorf("NewAuthenticator(%s): expected error", tt.name)
+		}
+	}
+	broken := filepath.Join(t.TempDir(), "broken.json")
+	err = os.WriteFile(broken, []byte("{"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewAuthenticator(config.Auth{JWKSFile: broken})
+	if err == nil {
+		t.Error("NewAuthenticator(broken jwks): expected error")
+	}
+}
+
+func TestAuthenticateMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth, err := NewAuthenticator(config.Auth{Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	subject := func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, principalFrom(ctx.Request.Context()).Subject)
+	}
+	router.GET("/customer", authenticate(auth), requireRole(roleCustomer), subject)
+	router.GET("/admin", authenticate(auth), requireRole(roleAdmin), subject)
+	// Без authenticate в контексте нет пользователя
+	router.GET("/unauthenticated", requireRole(roleAdmin), subject)
+
+	hs := func(c jwt.MapClaims) string { return sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", c) }
+	customer := hs(claims(nil))
+	admin := hs(claims(func(c jwt.MapClaims) { c["sub"] = "manager"; c["roles"] = []string{roleAdmin} }))
+	noRoles := hs(claims(func(c jwt.MapClaims) { delete(c, "roles") }))
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+		body          string
+	}{
+		{"customer", "/customer", "Bearer " + customer, http.StatusOK, "1"},
+		{"lowercase scheme", "/customer", "bearer " + customer, http.StatusOK, "1"},
+		{"admin", "/admin", "Bearer " + admin, http.StatusOK, "manager"},
+		{"no header", "/customer", "", http.StatusUnauthorized, `"unauthorized"`},
+		{"basic scheme", "/customer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, `"unauthorized"`},
+		{"empty token", "/customer", "Bearer ", http.StatusUnauthorized, `"unauthorized"`},
+		{"invalid token", "/customer", "Bearer not.a.token", http.StatusUnauthorized, `"unauthorized"`},
+		{"expired token", "/customer", "Bearer " + hs(claims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+		})), http.StatusUnauthorized, `"unauthorized"`},
+		{"customer on admin route", "/admin", "Bearer " + customer, http.StatusForbidden, `"forbidden"`},
+		{"admin on customer route", "/customer", "Bearer " + admin, http.StatusForbidden, `"forbidden"`},
+		{"no roles", "/customer", "Bearer " + noRoles, http.StatusForbidden, `"forbidden"`},
+		{"without authenticate", "/unauthenticated", "Bearer " + admin, http.StatusForbidden, `"forbidden"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: expected %d with %s, got %d with %s", tt.name, tt.status, tt.body, rec.Code, rec.Body)
+		}
+		if tt.status == http.StatusUnauthorized && !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer") {
+			t.Errorf("%s: expected WWW-Authenticate challenge, got %q", tt.name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"net/http"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"strconv"
+	"strings"
+)
+
+// goodsCSVColumns - колонки CSV файла каталога, при импорте goods_id можно не указывать
+var goodsCSVColumns = []string{"goods_id", "name", "price", "currency", "quantity"}
+
+// ndjsonMaxLine - максимальная длина строки NDJSON файла импорта
+const ndjsonMaxLine = 1 << 20
+
+// goodsFormat - формат файла каталога из параметра format, а при его отсутствии из заголовка Content-Type
+func goodsFormat(ctx *gin.Context, fallback dto.GoodsImportFormat) (dto.GoodsImportFormat, error) {
+	format := dto.GoodsImportFormat(ctx.Request.URL.Query().Get("format"))
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = dto.GoodsImportCSV
+		case "application/x-ndjson", "application/jsonl":
+			format = dto.GoodsImportNDJSON
+		default:
+			format = fallback
+		}
+	}
+	switch format {
+	case dto.GoodsImportCSV, dto.GoodsImportNDJSON:
+		return format, nil
+	case "":
+		return "", fmt.Errorf("format is required: csv or ndjson")
+	}
+	return "", fmt.Errorf("unknown format %q, expected csv or ndjson", format)
+}
+
+// importTooLarge - ответ 413, если чтение файла импорта превысило server.import_max_bytes
+func importTooLarge(ctx *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	catchErrGin(
+		ctx,
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("The import file is larger than %d bytes", tooLarge.Limit),
+		fmt.Errorf("[GoodsImport]: %v", err),
+	)
+	return true
+}
+
+// goodsRowError - ошибка разбора одной строки файла импорта, после неё импорт продолжается
+type goodsRowError struct {
+	line int64
+	err  error
+}
+
+func (e *goodsRowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+// goodsDecoder - разбор файла импорта по одной строке. decode возвращает номер строки и товар,
+// *goodsRowError для строки, которую не удалось разобрать, или io.EOF после последней строки
+type goodsDecoder interface {
+	decode() (int64, *models.Goods, error)
+}
+
+// csvGoodsDecoder - разбор CSV файла с заголовком из goodsCSVColumns в любом порядке
+type csvGoodsDecoder struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVGoodsDecoder(r io.Reader) (*csvGoodsDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("csv header is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, column := range goodsCSVColumns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, column := range goodsCSVColumns[1:] {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("csv column %q is required", column)
+		}
+	}
+	return &csvGoodsDecoder{reader: reader, columns: columns}, nil
+}
+
+func (d *csvGoodsDecoder) decode() (int64, *models.Goods, error) {
+	record, err := d.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return 0, nil, &goodsRowError{line: int64(parseErr.StartLine), err: parseErr.Err}
+		}
+		return 0, nil, err
+	}
+	lineNo, _ := d.reader.FieldPos(0)
+	line := int64(lineNo)
+
+	goods := &models.Goods{Name: record[d.columns["name"]]}
+	if i, ok := d.columns["goods_id"]; ok && record[i] != "" {
+		goods.GoodsId, err = strconv.ParseInt(record[i], 10, 64)
+		if err != nil {
+			return 0, nil, &goodsRowError{line: line, err: fmt.Errorf("invalid goods_id %q", record[i])}
+		}
+	}
+	goods.Price, err = models.ParseMoney(record[d.columns["price"]], record[d.columns["currency"]])
+	if err != nil {
+		return 0, nil, &goodsRowError{line: line, err: err}
+	}
+	quantity := record[d.columns["quantity"]]
+	goods.Quantity, err = strconv.ParseInt(quantity, 10, 64)
+	if err != nil {
+		return 0, nil, &goodsRowError{line: line, err: fmt.Errorf("invalid quantity %q", quantity)}
+	}
+	return line, goods, nil
+}
+
+// ndjsonGoodsDecoder - разбор NDJSON файла, в каждой строке товар в формате ответа GoodsGet
+type ndjsonGoodsDecoder struct {
+	scanner *bufio.Scanner
+	line    int64
+}
+
+func newNDJSONGoodsDecoder(r io.Reader) *ndjsonGoodsDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), ndjsonMaxLine)
+	return &ndjsonGoodsDecoder{scanner: scanner}
+}
+
+func (d *ndjsonGoodsDecoder) decode() (int64, *models.Goods, error) {
+	for d.scanner.Scan() {
+		d.line++
+		data := bytes.TrimSpace(d.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		goods := &models.Goods{}
+		err := jsoniter.Unmarshal(data, goods)
+		if err != nil {
+			return 0, nil, &goodsRowError{line: d.line, err: fmt.Errorf("failed to unmarshal goods: %v", err)}
+		}
+		return d.line, goods, nil
+	}
+	err := d.scanner.Err()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return 0, nil, err
+	}
+	if err != nil {
+		return 0, nil, errs.New(errs.Validation, "failed to read line %d: %v", d.line+1, err)
+	}
+	return 0, nil, io.EOF
+}
+
+// goodsImportReader - источник строк импорта для репозитория. Строки, которые не удалось разобрать
+// или которые не прошли валидацию по тегам models.Goods, пропускаются и попадают в errors
+type goodsImportReader struct {
+	decoder   goodsDecoder
+	validator *Validator
+	errors    []models.GoodsImportError
+}
+
+func (r *goodsImportReader) Next() (*dto.GoodsImportRow, error) {
+	for {
+		line, goods, err := r.decoder.decode()
+		var rowErr *goodsRowError
+		if errors.As(err, &rowErr) {
+			r.errors = append(r.errors, models.GoodsImportError{Line: rowErr.line, Error: rowErr.err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// goods_id новых товаров выдаёт БД
+		if goods.GoodsId == 0 {
+			err = r.validator.StructExcept(goods, "GoodsId")
+		} else {
+			err = r.validator.Struct(goods)
+		}
+		if err != nil {
+			r.errors = append(r.errors, models.GoodsImportError{
+				Line:  line,
+				Error: translateError(err, r.validator.ts).Error(),
+			})
+			continue
+		}
+		return &dto.GoodsImportRow{Line: line, Goods: *goods}, nil
+	}
+}
+
+// goodsEncoder - запись товаров в файл экспорта по одному
+type goodsEncoder interface {
+	encode(goods *models.Goods) error
+	flush() error
+}
+
+// csvGoodsEncoder - запись CSV файла с заголовком goodsCSVColumns
+type csvGoodsEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVGoodsEncoder(w io.Writer) (*csvGoodsEncoder, error) {
+	writer := csv.NewWriter(w)
+	err := writer.Write(goodsCSVColumns)
+	if err != nil {
+		return nil, err
+	}
+	return &csvGoodsEncoder{writer: writer}, nil
+}
+
+func (e *csvGoodsEncoder) encode(goods *models.Goods) error {
+	return e.writer.Write([]string{
+		strconv.FormatInt(goods.GoodsId, 10),
+		goods.Name,
+		goods.Price.Decimal(),
+		goods.Price.Currency,
+		strconv.FormatInt(goods.Quantity, 10),
+	})
+}
+
+func (e *csvGoodsEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// ndjsonGoodsEncoder - запись NDJSON файла, товар на строку в формате ответа GoodsGet
+type ndjsonGoodsEncoder struct {
+	writer *bufio.Writer
+}
+
+func newNDJSONGoodsEncoder(w io.Writer) *ndjsonGoodsEncoder {
+	return &ndjsonGoodsEncoder{writer: bufio.NewWriter(w)}
+}
+
+func (e *ndjsonGoodsEncoder) encode(goods *models.Goods) error {
+	data, err := jsoniter.Marshal(goods)
+	if err != nil {
+		return err
+	}
+	_, err = e.writer.Write(append(data, '\n'))
+	return err
+}
+
+func (e *ndjsonGoodsEncoder) flush() error {
+	return e.writer.Flush()
+}
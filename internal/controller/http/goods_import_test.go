@@ -0,0 +1,64 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"net/http"
+	"net/http/httptest"
+	"store_api/internal/domain/service"
+	"store_api/internal/repository/memory"
+	"strings"
+	"testing"
+)
+
+func TestGoodsImportBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := NewApiHandlers(service.NewStore(memory.NewStoreRepository()), validator)
+	router := gin.New()
+	router.Use(errorHandler())
+	router.POST("/goods/import", bodyLimit(256), handlers.GoodsImport)
+
+	row := `{"name": "Мышь", "price": {"amount": "499.99", "currency": "RUB"}, "quantity": 3}` + "\n"
+	cases := []struct {
+		name   string
+		format string
+		body   string
+		status int
+		code   string
+	}{
+		{"ndjson within limit", "ndjson", row, http.StatusOK, ""},
+		{"ndjson over limit", "ndjson", strings.Repeat(row, 10), http.StatusRequestEntityTooLarge, "request_too_large"},
+		{"csv within limit", "csv", "name,price,currency,quantity\nМышь,499.99,RUB,3\n", http.StatusOK, ""},
+		{
+			"csv over limit",
+			"csv",
+			"name,price,currency,quantity\n" + strings.Repeat("Мышь,499.99,RUB,3\n", 20),
+			http.StatusRequestEntityTooLarge,
+			"request_too_large",
+		},
+		{"csv header over limit", "csv", strings.Repeat("name", 100), http.StatusRequestEntityTooLarge, "request_too_large"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/goods/import?format="+c.format, strings.NewReader(c.body))
+		router.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d: %s", c.name, c.status, rec.Code, rec.Body.String())
+			continue
+		}
+		if c.code == "" {
+			continue
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		err = jsoniter.Unmarshal(rec.Body.Bytes(), &body)
+		if err != nil || body.Code != c.code {
+			t.Errorf("%s: expected code %q, got %s", c.name, c.code, rec.Body.String())
+		}
+	}
+}
@@ -1,14 +1,15 @@
 package http
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	ut "github.com/go-playground/universal-translator"
-	"github.com/go-playground/validator/v10"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/sirupsen/logrus"
 	"io"
+	"mime"
 	"net/http"
+	"sort"
 	"store_api/internal/domain/models"
 	"store_api/internal/domain/models/dto"
 	"store_api/internal/domain/service"
@@ -18,20 +19,14 @@ import (
 // ApiHandlers - структура хэндлеров для эндпоинтов ApiServer Store Web API
 type ApiHandlers struct {
 	service   service.StoreService
-	validator *validator.Validate
-	ts        ut.Translator
+	validator *Validator
 }
 
 // NewApiHandlers - создание экземпляра структуры с хэндлерами для ApiServer
-func NewApiHandlers(validate *validator.Validate, ts ut.Translator) *ApiHandlers {
-	acceptance, err := service.NewStore()
-	if err != nil {
-		logrus.Panicf("[NewApiHandlers]: failed to create StoreService. %v", err)
-	}
+func NewApiHandlers(svc service.StoreService, validator *Validator) *ApiHandlers {
 	return &ApiHandlers{
-		service:   acceptance,
-		validator: validate,
-		ts:        ts,
+		service:   svc,
+		validator: validator,
 	}
 }
 
@@ -44,7 +39,7 @@ func (h *ApiHandlers) GoodsAdd(ctx *gin.Context) {
 		))
 		return
 	}
-	goods := models.Goods{}
+	goods := dto.GoodsCreate{}
 	err = jsoniter.Unmarshal(body, &goods)
 	if err != nil {
 		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
@@ -56,7 +51,7 @@ func (h *ApiHandlers) GoodsAdd(ctx *gin.Context) {
 
 	err = h.validator.Struct(goods)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
@@ -66,50 +61,53 @@ func (h *ApiHandlers) GoodsAdd(ctx *gin.Context) {
 		return
 	}
 
-	err = h.service.GoodsAdd(&goods)
+	created, err := h.service.GoodsAdd(ctx.Request.Context(), &goods)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[GoodsAdd]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(created)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[GoodsAdd]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/goods/get?goods_id=%d", created.GoodsId))
+	ctx.Header("ETag", etag(created.Version))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
 			"[GoodsAdd]: %v",
 			err,
 		))
 		return
 	}
-	ctx.Status(http.StatusNoContent)
 }
 
 func (h *ApiHandlers) GoodsGet(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("goods_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The goods_id in query required", fmt.Errorf(
-			"[GoodsGet]: no value in query error"))
-		return
-	}
-	goodsId, err := strconv.ParseInt(key, 10, 64)
-	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 goods_id from query", fmt.Errorf(
-			"[GoodsGet]: %v", err,
-		))
+	goodsId, ok := h.queryId(ctx, "goods_id", "GoodsGet")
+	if !ok {
 		return
 	}
 
-	err = h.validator.Var(goodsId, "required,gt=0")
+	currency, err := queryCurrency(ctx, h.validator)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
+			fmt.Sprintf("The currency validation failed. %v", err),
 			fmt.Errorf("[GoodsGet]: %v", err),
 		)
 		return
 	}
 
-	goods, err := h.service.GoodsGet(goodsId)
+	goods, err := h.service.GoodsGet(ctx.Request.Context(), goodsId, currency)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[GoodsGet]: %v",
-			err,
-		))
+		_ = ctx.Error(fmt.Errorf("[GoodsGet]: %w", err))
 		return
 	}
 
@@ -121,6 +119,7 @@ func (h *ApiHandlers) GoodsGet(ctx *gin.Context) {
 		))
 		return
 	}
+	ctx.Header("ETag", etag(goods.Version))
 	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
@@ -131,237 +130,390 @@ func (h *ApiHandlers) GoodsGet(ctx *gin.Context) {
 	}
 }
 
-func (h *ApiHandlers) GoodsUpdate(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("goods_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The goods_id in query required", fmt.Errorf(
-			"[GoodsUpdate]: no value in query error"))
-		return
-	}
-	goodsId, err := strconv.ParseInt(key, 10, 64)
+func (h *ApiHandlers) GoodsList(ctx *gin.Context) {
+	filter, err := h.goodsFilter(ctx)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 goods_id from query", fmt.Errorf(
-			"[GoodsUpdate]: %v", err,
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Query validation failed. %v", err), fmt.Errorf(
+			"[GoodsList]: %v",
+			err,
 		))
 		return
 	}
 
-	err = h.validator.Var(goodsId, "required,gt=0")
+	page, err := h.service.GoodsList(ctx.Request.Context(), filter)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
-			fmt.Errorf("[GoodsUpdate]: %v", err),
-		)
+		_ = ctx.Error(fmt.Errorf("[GoodsList]: %w", err))
 		return
 	}
 
-	body, err := io.ReadAll(ctx.Request.Body)
+	respBody, err := jsoniter.Marshal(&page)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[GoodsUpdate]: %v",
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[GoodsList]: %v",
 			err,
 		))
 		return
 	}
-	goods := dto.GoodsUpdate{}
-	err = jsoniter.Unmarshal(body, &goods)
+	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
-		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[GoodsUpdate]: %v",
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[GoodsList]: %v",
 			err,
 		))
 		return
 	}
+}
 
-	err = h.validator.Struct(goods)
+func (h *ApiHandlers) GoodsSearch(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		catchErrGin(ctx, http.StatusBadRequest, "The q in query required", fmt.Errorf(
+			"[GoodsSearch]: no value in query error"))
+		return
+	}
+	limit := dto.GoodsSearchDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int limit from query", fmt.Errorf(
+				"[GoodsSearch]: %v", err,
+			))
+			return
+		}
+		err = h.validator.Var(limit, fmt.Sprintf("gt=0,lte=%d", dto.GoodsSearchMaxLimit))
+		if err != nil {
+			translatedErr := translateError(err, h.validator.ts)
+			catchErrGin(
+				ctx,
+				http.StatusBadRequest,
+				fmt.Sprintf("The limit validation failed. %v", translatedErr),
+				fmt.Errorf("[GoodsSearch]: %v", err),
+			)
+			return
+		}
+	}
+
+	found, err := h.service.GoodsSearch(ctx.Request.Context(), dto.NewGoodsSearch(q, limit))
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[GoodsUpdate]: %v", err),
-		)
+		_ = ctx.Error(fmt.Errorf("[GoodsSearch]: %w", err))
 		return
 	}
 
-	err = h.service.GoodsUpdate(goodsId, &goods)
+	respBody, err := jsoniter.Marshal(gin.H{"goods": found})
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[GoodsAdd]: %v",
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[GoodsSearch]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[GoodsSearch]: %v",
 			err,
 		))
 		return
 	}
-	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) GoodsDelete(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("goods_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The goods_id in query required", fmt.Errorf(
-			"[GoodsDelete]: no value in query error"))
-		return
+// queryId - положительный идентификатор key из параметров запроса, при ошибке отвечает клиенту 400
+func (h *ApiHandlers) queryId(ctx *gin.Context, key, op string) (int64, bool) {
+	value := ctx.Request.URL.Query().Get(key)
+	if value == "" {
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("The %s in query required", key), fmt.Errorf(
+			"[%s]: no value in query error", op))
+		return 0, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Failed to parse int64 %s from query", key), fmt.Errorf(
+			"[%s]: %v", op, err,
+		))
+		return 0, false
+	}
+
+	err = h.validator.Var(id, "required,gt=0")
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("The %s validation failed. %v", key, translatedErr),
+			fmt.Errorf("[%s]: %v", op, err),
+		)
+		return 0, false
 	}
-	goodsId, err := strconv.ParseInt(key, 10, 64)
+	return id, true
+}
+
+// pathId - положительный идентификатор из параметра пути key, при ошибке ответ уже отправлен
+func (h *ApiHandlers) pathId(ctx *gin.Context, key, op string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(key), 10, 64)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 goods_id from query", fmt.Errorf(
-			"[GoodsDelete]: %v", err,
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Failed to parse int64 %s from path", key), fmt.Errorf(
+			"[%s]: %v", op, err,
 		))
-		return
+		return 0, false
 	}
 
-	err = h.validator.Var(goodsId, "required,gt=0")
+	err = h.validator.Var(id, "required,gt=0")
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
-			fmt.Errorf("[GoodsDelete]: %v", err),
+			fmt.Sprintf("The %s validation failed. %v", key, translatedErr),
+			fmt.Errorf("[%s]: %v", op, err),
 		)
-		return
+		return 0, false
+	}
+	return id, true
+}
+
+// goodsFilter - разбор параметров запроса списка товаров
+func (h *ApiHandlers) goodsFilter(ctx *gin.Context) (*dto.GoodsFilter, error) {
+	query := ctx.Request.URL.Query()
+	filter := &dto.GoodsFilter{Name: query.Get("name"), Limit: dto.GoodsListDefaultLimit}
+
+	var err error
+	filter.Sort, filter.Desc, err = dto.ParseGoodsSort(query.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse limit: %v", err)
+		}
+		err = h.validator.Var(filter.Limit, fmt.Sprintf("gt=0,lte=%d", dto.GoodsListMaxLimit))
+		if err != nil {
+			return nil, fmt.Errorf("limit: %v", translateError(err, h.validator.ts))
+		}
+	}
+	if value := query.Get("in_stock"); value != "" {
+		filter.InStock, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse in_stock: %v", err)
+		}
 	}
 
-	err = h.service.GoodsDelete(goodsId)
+	currency, err := queryCurrency(ctx, h.validator)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[GoodsDelete]: %v",
-			err,
-		))
-		return
+		return nil, fmt.Errorf("currency: %v", err)
+	}
+	if currency == "" {
+		currency = models.DefaultCurrency()
+	}
+	if value := query.Get("price_min"); value != "" {
+		price, err := models.ParseMoney(value, currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse price_min: %v", err)
+		}
+		filter.PriceMin = &price
+	}
+	if value := query.Get("price_max"); value != "" {
+		price, err := models.ParseMoney(value, currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse price_max: %v", err)
+		}
+		filter.PriceMax = &price
 	}
 
-	ctx.Status(http.StatusNoContent)
+	if value := query.Get("category_id"); value != "" {
+		filter.CategoryId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse category_id: %v", err)
+		}
+		err = h.validator.Var(filter.CategoryId, "gt=0")
+		if err != nil {
+			return nil, fmt.Errorf("category_id: %v", translateError(err, h.validator.ts))
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		filter.After, err = dto.DecodeGoodsCursor(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
 }
 
-func (h *ApiHandlers) CartCreate(ctx *gin.Context) {
+func (h *ApiHandlers) GoodsUpdate(ctx *gin.Context) {
+	goodsId, ok := h.queryId(ctx, "goods_id", "GoodsUpdate")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(ctx, "GoodsUpdate")
+	if !ok {
+		return
+	}
+
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[CartCreate]: %v",
+			"[GoodsUpdate]: %v",
 			err,
 		))
 		return
 	}
-	cart := models.Cart{}
-	err = jsoniter.Unmarshal(body, &cart)
+	goods := dto.GoodsUpdate{}
+	err = jsoniter.Unmarshal(body, &goods)
 	if err != nil {
 		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[CartCreate]: %v",
+			"[GoodsUpdate]: %v",
 			err,
 		))
 		return
 	}
 
-	err = h.validator.Struct(cart)
+	err = h.validator.Struct(goods)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
 			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[CartCreate]: %v", err),
+			fmt.Errorf("[GoodsUpdate]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.CartCreate(&cart)
+	version, err = h.service.GoodsUpdate(ctx.Request.Context(), goodsId, version, &goods)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartCreate]: %v",
-			err,
-		))
+		_ = ctx.Error(fmt.Errorf("[GoodsUpdate]: %w", err))
 		return
 	}
+	ctx.Header("ETag", etag(version))
 	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) CartGoodsAdd(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("cart_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The cart_id in query required", fmt.Errorf(
-			"[CartGoodsAdd]: no value in query error"))
-		return
-	}
-	cartId, err := strconv.ParseInt(key, 10, 64)
-	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 cart_id from query", fmt.Errorf(
-			"[CartGoodsAdd]: %v", err,
-		))
+func (h *ApiHandlers) GoodsPatch(ctx *gin.Context) {
+	goodsId, ok := h.pathId(ctx, "goods_id", "GoodsPatch")
+	if !ok {
 		return
 	}
 
-	err = h.validator.Var(cartId, "required,gt=0")
-	if err != nil {
-		translatedErr := translateError(err, h.ts)
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType != "" && mediaType != "application/merge-patch+json" && mediaType != "application/json" {
 		catchErrGin(
 			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsAdd]: %v", err),
+			http.StatusUnsupportedMediaType,
+			"The Content-Type must be application/merge-patch+json",
+			fmt.Errorf("[GoodsPatch]: unsupported media type %s", mediaType),
 		)
 		return
 	}
+	version, ok := ifMatch(ctx, "GoodsPatch")
+	if !ok {
+		return
+	}
 
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[CartGoodsAdd]: %v",
+			"[GoodsPatch]: %v",
 			err,
 		))
 		return
 	}
-	goods := dto.GoodsAdd{}
-	err = jsoniter.Unmarshal(body, &goods)
+	patch, err := dto.DecodeGoodsPatch(body)
 	if err != nil {
-		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[CartGoodsAdd]: %v",
+		catchErrGin(ctx, http.StatusUnprocessableEntity, fmt.Sprintf("Failed to unmarshal body. %v", err), fmt.Errorf(
+			"[GoodsPatch]: %v",
 			err,
 		))
 		return
 	}
 
-	err = h.validator.Struct(goods)
+	// Валидируются только переданные поля, остальные остаются nil
+	err = h.validator.Struct(patch)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
 			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsAdd]: %v", err),
+			fmt.Errorf("[GoodsPatch]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.CartAddGoods(&goods)
+	version, err = h.service.GoodsPatch(ctx.Request.Context(), goodsId, version, patch)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartGoodsAdd]: %v",
-			err,
-		))
+		_ = ctx.Error(fmt.Errorf("[GoodsPatch]: %w", err))
 		return
 	}
+	ctx.Header("ETag", etag(version))
 	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) CartGoodsGet(ctx *gin.Context) {
-	goods, err := h.service.CartGetGoods()
+func (h *ApiHandlers) GoodsDelete(ctx *gin.Context) {
+	goodsId, ok := h.queryId(ctx, "goods_id", "GoodsDelete")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(ctx, "GoodsDelete")
+	if !ok {
+		return
+	}
+
+	err := h.service.GoodsDelete(ctx.Request.Context(), goodsId, version)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[GoodsDelete]: %w", err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) GoodsImport(ctx *gin.Context) {
+	format, err := goodsFormat(ctx, "")
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartGetGoods]: %v",
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Query validation failed. %v", err), fmt.Errorf(
+			"[GoodsImport]: %v",
 			err,
 		))
 		return
 	}
 
-	respBody, err := jsoniter.Marshal(&goods)
+	var decoder goodsDecoder
+	switch format {
+	case dto.GoodsImportCSV:
+		decoder, err = newCSVGoodsDecoder(ctx.Request.Body)
+		if importTooLarge(ctx, err) {
+			return
+		}
+		if err != nil {
+			catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Body validation failed. %v", err), fmt.Errorf(
+				"[GoodsImport]: %v",
+				err,
+			))
+			return
+		}
+	case dto.GoodsImportNDJSON:
+		decoder = newNDJSONGoodsDecoder(ctx.Request.Body)
+	}
+	rows := &goodsImportReader{decoder: decoder, validator: h.validator, errors: make([]models.GoodsImportError, 0)}
+
+	result, err := h.service.GoodsImport(ctx.Request.Context(), rows)
+	if importTooLarge(ctx, err) {
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[GoodsImport]: %w", err))
+		return
+	}
+	result.Errors = append(result.Errors, rows.errors...)
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+
+	respBody, err := jsoniter.Marshal(&result)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
-			"[CartGetGoods]: %v",
+			"[GoodsImport]: %v",
 			err,
 		))
 		return
@@ -369,310 +521,1033 @@ func (h *ApiHandlers) CartGoodsGet(ctx *gin.Context) {
 	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
-			"[CartGetGoods]: %v",
+			"[GoodsImport]: %v",
 			err,
 		))
 		return
 	}
 }
 
-func (h *ApiHandlers) CartGoodsUpdate(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("cart_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The cart_id in query required", fmt.Errorf(
-			"[CartGoodsUpdate]: no value in query error"))
-		return
-	}
-	cartId, err := strconv.ParseInt(key, 10, 64)
+func (h *ApiHandlers) GoodsExport(ctx *gin.Context) {
+	format, err := goodsFormat(ctx, dto.GoodsImportCSV)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 cart_id from query", fmt.Errorf(
-			"[CartGoodsUpdate]: %v", err,
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Query validation failed. %v", err), fmt.Errorf(
+			"[GoodsExport]: %v",
+			err,
 		))
 		return
 	}
 
-	key = ctx.Request.URL.Query().Get("goods_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The goods_id in query required", fmt.Errorf(
-			"[CartGoodsUpdate]: no value in query error"))
-		return
+	var encoder goodsEncoder
+	switch format {
+	case dto.GoodsImportCSV:
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		encoder, err = newCSVGoodsEncoder(ctx.Writer)
+		if err != nil {
+			catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+				"[GoodsExport]: %v",
+				err,
+			))
+			return
+		}
+	case dto.GoodsImportNDJSON:
+		ctx.Header("Content-Type", "application/x-ndjson")
+		encoder = newNDJSONGoodsEncoder(ctx.Writer)
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="goods.%s"`, format))
+
+	// Товары пишутся в ответ по мере чтения из БД, весь каталог в памяти не собирается
+	err = h.service.GoodsExport(ctx.Request.Context(), encoder.encode)
+	if err == nil {
+		err = encoder.flush()
 	}
-	goodsId, err := strconv.ParseInt(key, 10, 64)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 goods_id from query", fmt.Errorf(
-			"[CartGoodsUpdate]: %v", err,
-		))
+		if ctx.Writer.Written() {
+			// Статус уже отправлен клиенту, остаётся только оборвать ответ
+			logrus.Errorf("Failed to stream goods export. Error: %s", err)
+			ctx.Abort()
+			return
+		}
+		ctx.Writer.Header().Del("Content-Disposition")
+		_ = ctx.Error(fmt.Errorf("[GoodsExport]: %w", err))
 		return
 	}
+}
 
-	err = h.validator.Var(cartId, "required,gt=0")
-	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The cart_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsUpdate]: %v", err),
-		)
+func (h *ApiHandlers) VariantAdd(ctx *gin.Context) {
+	goodsId, ok := h.queryId(ctx, "goods_id", "VariantAdd")
+	if !ok {
 		return
 	}
-	err = h.validator.Var(goodsId, "required,gt=0")
-	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsUpdate]: %v", err),
-		)
+	version, ok := ifMatch(ctx, "VariantAdd")
+	if !ok {
 		return
 	}
 
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[CartGoodsUpdate]: %v",
+			"[VariantAdd]: %v",
 			err,
 		))
 		return
 	}
-	goods := struct {
-		Quantity int64 `json:"quantity" validate:"required,gt=0"`
-	}{}
-	err = jsoniter.Unmarshal(body, &goods)
+	variant := dto.VariantCreate{}
+	err = jsoniter.Unmarshal(body, &variant)
 	if err != nil {
 		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[CartGoodsUpdate]: %v",
+			"[VariantAdd]: %v",
 			err,
 		))
 		return
 	}
 
-	err = h.validator.Struct(goods)
+	err = h.validator.Struct(variant)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
 			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsUpdate]: %v", err),
+			fmt.Errorf("[VariantAdd]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.CartGoodsUpdate(cartId, goodsId, goods.Quantity)
+	created, version, err := h.service.VariantAdd(ctx.Request.Context(), goodsId, version, &variant)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartGoodsUpdate]: %v",
+		_ = ctx.Error(fmt.Errorf("[VariantAdd]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&created)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[VariantAdd]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/goods/variants/get?variant_id=%d", created.VariantId))
+	ctx.Header("ETag", etag(version))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[VariantAdd]: %v",
 			err,
 		))
 		return
 	}
-	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) CartGoodsDelete(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("cart_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The cart_id in query required", fmt.Errorf(
-			"[CartGoodsDelete]: no value in query error"))
+func (h *ApiHandlers) VariantGet(ctx *gin.Context) {
+	variantId, ok := h.queryId(ctx, "variant_id", "VariantGet")
+	if !ok {
 		return
 	}
-	cartId, err := strconv.ParseInt(key, 10, 64)
+
+	variant, err := h.service.VariantGet(ctx.Request.Context(), variantId)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 cart_id from query", fmt.Errorf(
-			"[CartGoodsDelete]: %v", err,
-		))
+		_ = ctx.Error(fmt.Errorf("[VariantGet]: %w", err))
 		return
 	}
-	key = ctx.Request.URL.Query().Get("goods_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The goods_id in query required", fmt.Errorf(
-			"[CartGoodsDelete]: no value in query error"))
+
+	respBody, err := jsoniter.Marshal(&variant)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[VariantGet]: %v",
+			err,
+		))
 		return
 	}
-	goodsId, err := strconv.ParseInt(key, 10, 64)
+	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 goods_id from query", fmt.Errorf(
-			"[CartGoodsDelete]: %v", err,
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[VariantGet]: %v",
+			err,
 		))
 		return
 	}
+}
 
-	err = h.validator.Var(cartId, "required,gt=0")
-	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The cart_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsDelete]: %v", err),
-		)
+func (h *ApiHandlers) VariantUpdate(ctx *gin.Context) {
+	variantId, ok := h.queryId(ctx, "variant_id", "VariantUpdate")
+	if !ok {
 		return
 	}
-	err = h.validator.Var(goodsId, "required,gt=0")
-	if err != nil {
-		translatedErr := translateError(err, h.ts)
-		catchErrGin(
-			ctx,
-			http.StatusBadRequest,
-			fmt.Sprintf("The goods_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartGoodsDelete]: %v", err),
-		)
+	version, ok := ifMatch(ctx, "VariantUpdate")
+	if !ok {
 		return
 	}
 
-	err = h.service.CartDeleteGoods(cartId, goodsId)
+	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartGoodsDelete]: %v",
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[VariantUpdate]: %v",
 			err,
 		))
 		return
 	}
-
-	ctx.Status(http.StatusNoContent)
-}
-
-func (h *ApiHandlers) CartDelete(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("cart_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The cart_id in query required", fmt.Errorf(
-			"[CartDelete]: no value in query error"))
-		return
-	}
-	cartId, err := strconv.ParseInt(key, 10, 64)
+	variant := dto.VariantUpdate{}
+	err = jsoniter.Unmarshal(body, &variant)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 cart_id from query", fmt.Errorf(
-			"[CartDelete]: %v", err,
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[VariantUpdate]: %v",
+			err,
 		))
 		return
 	}
 
-	err = h.validator.Var(cartId, "required,gt=0")
+	err = h.validator.Struct(variant)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The cart_id validation failed. %v", translatedErr),
-			fmt.Errorf("[CartDelete]: %v", err),
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[VariantUpdate]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.CartDelete(cartId)
+	version, err = h.service.VariantUpdate(ctx.Request.Context(), variantId, version, &variant)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[CartDelete]: %v",
-			err,
-		))
+		_ = ctx.Error(fmt.Errorf("[VariantUpdate]: %w", err))
+		return
+	}
+	ctx.Header("ETag", etag(version))
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) VariantDelete(ctx *gin.Context) {
+	variantId, ok := h.queryId(ctx, "variant_id", "VariantDelete")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(ctx, "VariantDelete")
+	if !ok {
 		return
 	}
 
+	version, err := h.service.VariantDelete(ctx.Request.Context(), variantId, version)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[VariantDelete]: %w", err))
+		return
+	}
+	ctx.Header("ETag", etag(version))
 	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) OrderCreate(ctx *gin.Context) {
+func (h *ApiHandlers) CustomerAdd(ctx *gin.Context) {
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[OrderCreate]: %v",
+			"[CustomerAdd]: %v",
 			err,
 		))
 		return
 	}
-	cart := struct {
-		CartId int64 `json:"cart_id" validate:"required,gt=0"`
-	}{}
-	err = jsoniter.Unmarshal(body, &cart)
+	customer := dto.CustomerCreate{}
+	err = jsoniter.Unmarshal(body, &customer)
 	if err != nil {
 		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[OrderCreate]: %v",
+			"[CustomerAdd]: %v",
 			err,
 		))
 		return
 	}
 
-	err = h.validator.Struct(cart)
+	err = h.validator.Struct(customer)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
 			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[OrderCreate]: %v", err),
+			fmt.Errorf("[CustomerAdd]: %v", err),
 		)
 		return
 	}
 
-	order, err := h.service.OrderCreate(cart.CartId)
+	created, err := h.service.CustomerAdd(ctx.Request.Context(), &customer)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[OrderCreate]: %v",
-			err,
-		))
+		_ = ctx.Error(fmt.Errorf("[CustomerAdd]: %w", err))
 		return
 	}
 
-	respBody, err := jsoniter.Marshal(&order)
+	respBody, err := jsoniter.Marshal(&created)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
-			"[OrderCreate]: %v",
+			"[CustomerAdd]: %v",
 			err,
 		))
 		return
 	}
+	ctx.Header("Location", fmt.Sprintf("/api/customers/get?customer_id=%d", created.CustomerId))
+	ctx.Writer.WriteHeader(http.StatusCreated)
 	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
-			"[OrderCreate]: %v",
+			"[CustomerAdd]: %v",
 			err,
 		))
 		return
 	}
 }
 
-func (h *ApiHandlers) OrderGet(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("order_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The order_id in query required", fmt.Errorf(
-			"[OrderGet]: no value in query error"))
+func (h *ApiHandlers) CustomerGet(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CustomerGet")
+	if !ok {
 		return
 	}
-	orderId, err := strconv.ParseInt(key, 10, 64)
+	requestedId, ok := h.queryId(ctx, "customer_id", "CustomerGet")
+	if !ok {
+		return
+	}
+
+	customer, err := h.service.CustomerGet(ctx.Request.Context(), customerId, requestedId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CustomerGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&customer)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CustomerGet]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CustomerGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) CartCreate(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartCreate")
+	if !ok {
+		return
+	}
+	cart, err := h.service.CartCreate(ctx.Request.Context(), customerId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartCreate]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(struct {
+		CartId  int64 `json:"cart_id"`
+		Version int64 `json:"version"`
+	}{CartId: cart.CartId, Version: cart.Version})
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CartCreate]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/carts/goods/get?cart_id=%d", cart.CartId))
+	ctx.Header("ETag", etag(cart.Version))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CartCreate]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) CartGoodsAdd(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartGoodsAdd")
+	if !ok {
+		return
+	}
+	cartId, ok := h.queryId(ctx, "cart_id", "CartGoodsAdd")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(ctx, "CartGoodsAdd")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[CartGoodsAdd]: %v",
+			err,
+		))
+		return
+	}
+	goods := dto.GoodsAdd{}
+	err = jsoniter.Unmarshal(body, &goods)
+	if err != nil {
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[CartGoodsAdd]: %v",
+			err,
+		))
+		return
+	}
+
+	err = h.validator.Struct(goods)
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[CartGoodsAdd]: %v", err),
+		)
+		return
+	}
+
+	version, err = h.service.CartAddGoods(ctx.Request.Context(), customerId, cartId, version, &goods)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartGoodsAdd]: %w", err))
+		return
+	}
+	ctx.Header("ETag", etag(version))
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CartGoodsGet(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartGoodsGet")
+	if !ok {
+		return
+	}
+	cartId, ok := h.queryId(ctx, "cart_id", "CartGoodsGet")
+	if !ok {
+		return
+	}
+
+	currency, err := queryCurrency(ctx, h.validator)
+	if err != nil {
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("The currency validation failed. %v", err),
+			fmt.Errorf("[CartGoodsGet]: %v", err),
+		)
+		return
+	}
+
+	cart, err := h.service.CartGetGoods(ctx.Request.Context(), customerId, cartId, currency)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartGoodsGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&cart)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CartGoodsGet]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("ETag", etag(cart.Version))
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CartGoodsGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) CartGoodsUpdate(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartGoodsUpdate")
+	if !ok {
+		return
+	}
+	cartId, ok := h.queryId(ctx, "cart_id", "CartGoodsUpdate")
+	if !ok {
+		return
+	}
+	variantId, ok := h.queryId(ctx, "variant_id", "CartGoodsUpdate")
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatch(ctx, "CartGoodsUpdate")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[CartGoodsUpdate]: %v",
+			err,
+		))
+		return
+	}
+	goods := struct {
+		Quantity int64 `json:"quantity" validate:"required,gt=0"`
+	}{}
+	err = jsoniter.Unmarshal(body, &goods)
+	if err != nil {
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[CartGoodsUpdate]: %v",
+			err,
+		))
+		return
+	}
+
+	err = h.validator.Struct(goods)
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[CartGoodsUpdate]: %v", err),
+		)
+		return
+	}
+
+	version, err = h.service.CartGoodsUpdate(ctx.Request.Context(), customerId, cartId, variantId, version, goods.Quantity)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartGoodsUpdate]: %w", err))
+		return
+	}
+	ctx.Header("ETag", etag(version))
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CartGoodsDelete(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartGoodsDelete")
+	if !ok {
+		return
+	}
+	cartId, ok := h.queryId(ctx, "cart_id", "CartGoodsDelete")
+	if !ok {
+		return
+	}
+	variantId, ok := h.queryId(ctx, "variant_id", "CartGoodsDelete")
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatch(ctx, "CartGoodsDelete")
+	if !ok {
+		return
+	}
+
+	version, err := h.service.CartDeleteGoods(ctx.Request.Context(), customerId, cartId, variantId, version)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartGoodsDelete]: %w", err))
+		return
+	}
+	ctx.Header("ETag", etag(version))
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CartDelete(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "CartDelete")
+	if !ok {
+		return
+	}
+	cartId, ok := h.queryId(ctx, "cart_id", "CartDelete")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(ctx, "CartDelete")
+	if !ok {
+		return
+	}
+
+	err := h.service.CartDelete(ctx.Request.Context(), customerId, cartId, version)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CartDelete]: %w", err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) OrderCreate(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "OrderCreate")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[OrderCreate]: %v",
+			err,
+		))
+		return
+	}
+	cart := struct {
+		CartId int64 `json:"cart_id" validate:"required,gt=0"`
+	}{}
+	err = jsoniter.Unmarshal(body, &cart)
+	if err != nil {
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[OrderCreate]: %v",
+			err,
+		))
+		return
+	}
+
+	err = h.validator.Struct(cart)
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[OrderCreate]: %v", err),
+		)
+		return
+	}
+
+	currency, err := queryCurrency(ctx, h.validator)
+	if err != nil {
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("The currency validation failed. %v", err),
+			fmt.Errorf("[OrderCreate]: %v", err),
+		)
+		return
+	}
+
+	order, err := h.service.OrderCreate(ctx.Request.Context(), customerId, cart.CartId, currency)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderCreate]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&order)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderCreate]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/orders/get?order_id=%d", order.OrderId))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderCreate]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderGet(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "OrderGet")
+	if !ok {
+		return
+	}
+	orderId, ok := h.queryId(ctx, "order_id", "OrderGet")
+	if !ok {
+		return
+	}
+
+	order, err := h.service.OrderGet(ctx.Request.Context(), customerId, orderId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&order)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderGet]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderList(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "OrderList")
+	if !ok {
+		return
+	}
+	filter, err := h.orderFilter(ctx)
+	if err != nil {
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Query validation failed. %v", err), fmt.Errorf(
+			"[OrderList]: %v",
+			err,
+		))
+		return
+	}
+
+	page, err := h.service.OrderList(ctx.Request.Context(), customerId, filter)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderList]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&page)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderList]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderList]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderListAll(ctx *gin.Context) {
+	filter, err := h.orderFilter(ctx)
+	if err != nil {
+		catchErrGin(ctx, http.StatusBadRequest, fmt.Sprintf("Query validation failed. %v", err), fmt.Errorf(
+			"[OrderListAll]: %v",
+			err,
+		))
+		return
+	}
+
+	page, err := h.service.OrderListAll(ctx.Request.Context(), filter)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderListAll]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&page)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderListAll]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderListAll]: %v",
+			err,
+		))
+		return
+	}
+}
+
+// orderFilter - разбор параметров запроса списка заказов
+func (h *ApiHandlers) orderFilter(ctx *gin.Context) (*dto.OrderFilter, error) {
+	query := ctx.Request.URL.Query()
+	filter := &dto.OrderFilter{Status: models.OrderStatus(query.Get("status")), Limit: dto.OrderListDefaultLimit}
+
+	var err error
+	if value := query.Get("customer_id"); value != "" {
+		filter.CustomerId, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse customer_id: %v", err)
+		}
+		err = h.validator.Var(filter.CustomerId, "gt=0")
+		if err != nil {
+			return nil, fmt.Errorf("customer_id: %v", translateError(err, h.validator.ts))
+		}
+	}
+	if filter.Status != "" {
+		err = h.validator.Var(filter.Status, "oneof=created paid shipped delivered cancelled refunded")
+		if err != nil {
+			return nil, fmt.Errorf("status: %v", translateError(err, h.validator.ts))
+		}
+	}
+	filter.From, err = queryTime(ctx, "from")
+	if err != nil {
+		return nil, err
+	}
+	filter.To, err = queryTime(ctx, "to")
+	if err != nil {
+		return nil, err
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse limit: %v", err)
+		}
+		err = h.validator.Var(filter.Limit, fmt.Sprintf("gt=0,lte=%d", dto.OrderListMaxLimit))
+		if err != nil {
+			return nil, fmt.Errorf("limit: %v", translateError(err, h.validator.ts))
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		filter.After, err = dto.DecodeOrderCursor(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+func (h *ApiHandlers) OrderTransition(ctx *gin.Context) {
+	orderId, ok := h.pathId(ctx, "order_id", "OrderTransition")
+	if !ok {
+		return
+	}
+	actor, ok := requestActor(ctx, "OrderTransition")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[OrderTransition]: %v",
+			err,
+		))
+		return
+	}
+	transition := dto.OrderTransition{}
+	err = jsoniter.Unmarshal(body, &transition)
+	if err != nil {
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[OrderTransition]: %v",
+			err,
+		))
+		return
+	}
+
+	err = h.validator.Struct(transition)
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[OrderTransition]: %v", err),
+		)
+		return
+	}
+
+	change, err := h.service.OrderTransition(ctx.Request.Context(), orderId, actor, &transition)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderTransition]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(change)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 order_id from query", fmt.Errorf(
-			"[OrderGet]: %v", err,
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderTransition]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/orders/%d/transitions", orderId))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderTransition]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderTransitionsGet(ctx *gin.Context) {
+	customerId, ok := requestCustomer(ctx, "OrderTransitionsGet")
+	if !ok {
+		return
+	}
+	orderId, ok := h.pathId(ctx, "order_id", "OrderTransitionsGet")
+	if !ok {
+		return
+	}
+
+	history, err := h.service.OrderStatusHistory(ctx.Request.Context(), customerId, orderId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderTransitionsGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(history)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderTransitionsGet]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderTransitionsGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderTransitionsGetAll(ctx *gin.Context) {
+	orderId, ok := h.pathId(ctx, "order_id", "OrderTransitionsGetAll")
+	if !ok {
+		return
+	}
+
+	history, err := h.service.OrderStatusHistoryAll(ctx.Request.Context(), orderId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderTransitionsGetAll]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(history)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[OrderTransitionsGetAll]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[OrderTransitionsGetAll]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) OrderCancel(ctx *gin.Context) {
+	orderId, ok := h.pathId(ctx, "order_id", "OrderCancel")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[OrderCancel]: %v",
+			err,
 		))
 		return
 	}
+	// Тело необязательно, без него заказ отменяется без причины
+	cancel := dto.OrderCancel{}
+	if len(bytes.TrimSpace(body)) != 0 {
+		err = jsoniter.Unmarshal(body, &cancel)
+		if err != nil {
+			catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+				"[OrderCancel]: %v",
+				err,
+			))
+			return
+		}
+	}
 
-	err = h.validator.Var(orderId, "required,gt=0")
+	err = h.validator.Struct(cancel)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The order_id validation failed. %v", translatedErr),
-			fmt.Errorf("[OrderGet]: %v", err),
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[OrderCancel]: %v", err),
 		)
 		return
 	}
 
-	order, err := h.service.OrderGet(orderId)
+	h.orderCancel(ctx, "OrderCancel", orderId, cancel.Reason)
+}
+
+// OrderDelete - отмена заказа по прежнему адресу DELETE /orders/delete?order_id&reason,
+// оставлена для совместимости с клиентами, удалявшими заказы до появления статусов
+func (h *ApiHandlers) OrderDelete(ctx *gin.Context) {
+	orderId, ok := h.queryId(ctx, "order_id", "OrderDelete")
+	if !ok {
+		return
+	}
+
+	cancel := dto.OrderCancel{Reason: ctx.Request.URL.Query().Get("reason")}
+	err := h.validator.Struct(cancel)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[OrderGet]: %v",
-			err,
-		))
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("The reason validation failed. %v", translatedErr),
+			fmt.Errorf("[OrderDelete]: %v", err),
+		)
 		return
 	}
 
-	respBody, err := jsoniter.Marshal(&order)
+	h.orderCancel(ctx, "OrderDelete", orderId, cancel.Reason)
+}
+
+// orderCancel - отмена заказа orderId от имени покупателя из токена, op - имя хэндлера для ошибок
+func (h *ApiHandlers) orderCancel(ctx *gin.Context, op string, orderId int64, reason string) {
+	customerId, ok := requestCustomer(ctx, op)
+	if !ok {
+		return
+	}
+	actor, ok := requestActor(ctx, op)
+	if !ok {
+		return
+	}
+
+	err := h.service.OrderCancel(ctx.Request.Context(), customerId, orderId, actor, reason)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[%s]: %w", op, err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) OrderPurge(ctx *gin.Context) {
+	orderId, ok := h.queryId(ctx, "order_id", "OrderPurge")
+	if !ok {
+		return
+	}
+
+	err := h.service.OrderPurge(ctx.Request.Context(), orderId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[OrderPurge]: %w", err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) ExchangeRatesGet(ctx *gin.Context) {
+	rates, err := h.service.ExchangeRatesGet(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[ExchangeRatesGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&rates)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
-			"[OrderGet]: %v",
+			"[ExchangeRatesGet]: %v",
 			err,
 		))
 		return
@@ -680,116 +1555,295 @@ func (h *ApiHandlers) OrderGet(ctx *gin.Context) {
 	_, err = ctx.Writer.Write(respBody)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
-			"[OrderGet]: %v",
+			"[ExchangeRatesGet]: %v",
 			err,
 		))
 		return
 	}
 }
 
-func (h *ApiHandlers) OrderUpdate(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("order_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The order_id in query required", fmt.Errorf(
-			"[OrderUpdate]: no value in query error"))
+func (h *ApiHandlers) ExchangeRateUpdate(ctx *gin.Context) {
+	currency := ctx.Request.URL.Query().Get("currency")
+	err := h.validator.Var(currency, "required,iso4217")
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("The currency validation failed. %v", translatedErr),
+			fmt.Errorf("[ExchangeRateUpdate]: %v", err),
+		)
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[ExchangeRateUpdate]: %v",
+			err,
+		))
 		return
 	}
-	orderId, err := strconv.ParseInt(key, 10, 64)
+	rate := dto.ExchangeRateUpdate{}
+	err = jsoniter.Unmarshal(body, &rate)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 order_id from query", fmt.Errorf(
-			"[OrderUpdate]: %v", err,
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[ExchangeRateUpdate]: %v",
+			err,
 		))
 		return
 	}
 
-	err = h.validator.Var(orderId, "required,gt=0")
+	err = h.validator.Struct(rate)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The OrderUpdate validation failed. %v", translatedErr),
-			fmt.Errorf("[OrderUpdate]: %v", err),
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[ExchangeRateUpdate]: %v", err),
 		)
 		return
 	}
 
+	err = h.service.ExchangeRateSet(ctx.Request.Context(), &models.ExchangeRate{Currency: currency, Rate: rate.Rate})
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[ExchangeRateUpdate]: %w", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CategoryAdd(ctx *gin.Context) {
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
 		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
-			"[OrderUpdate]: %v",
+			"[CategoryAdd]: %v",
 			err,
 		))
 		return
 	}
-	order := dto.OrderUpdate{}
-	err = jsoniter.Unmarshal(body, &order)
+	category := dto.CategoryCreate{}
+	err = jsoniter.Unmarshal(body, &category)
 	if err != nil {
 		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
-			"[OrderUpdate]: %v",
+			"[CategoryAdd]: %v",
 			err,
 		))
 		return
 	}
 
-	err = h.validator.Struct(order)
+	err = h.validator.Struct(category)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
 			fmt.Sprintf("Body validation failed. %v", translatedErr),
-			fmt.Errorf("[OrderUpdate]: %v", err),
+			fmt.Errorf("[CategoryAdd]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.OrderUpdate(orderId, &order)
+	created, err := h.service.CategoryAdd(ctx.Request.Context(), &category)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[OrderUpdate]: %v",
+		_ = ctx.Error(fmt.Errorf("[CategoryAdd]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&created)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CategoryAdd]: %v",
+			err,
+		))
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("/api/categories/get?category_id=%d", created.CategoryId))
+	ctx.Writer.WriteHeader(http.StatusCreated)
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CategoryAdd]: %v",
 			err,
 		))
 		return
 	}
-	ctx.Status(http.StatusNoContent)
 }
 
-func (h *ApiHandlers) OrderDelete(ctx *gin.Context) {
-	key := ctx.Request.URL.Query().Get("order_id")
-	if key == "" {
-		catchErrGin(ctx, http.StatusBadRequest, "The order_id in query required", fmt.Errorf(
-			"[OrderDelete]: no value in query error"))
+func (h *ApiHandlers) CategoryGet(ctx *gin.Context) {
+	categoryId, ok := h.queryId(ctx, "category_id", "CategoryGet")
+	if !ok {
+		return
+	}
+
+	category, err := h.service.CategoryGet(ctx.Request.Context(), categoryId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoryGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&category)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CategoryGet]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CategoryGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) CategoriesGet(ctx *gin.Context) {
+	tree, err := h.service.CategoriesGet(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoriesGet]: %w", err))
+		return
+	}
+
+	respBody, err := jsoniter.Marshal(&tree)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to marshal response body", fmt.Errorf(
+			"[CategoriesGet]: %v",
+			err,
+		))
+		return
+	}
+	_, err = ctx.Writer.Write(respBody)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to write response body", fmt.Errorf(
+			"[CategoriesGet]: %v",
+			err,
+		))
+		return
+	}
+}
+
+func (h *ApiHandlers) CategoryUpdate(ctx *gin.Context) {
+	categoryId, ok := h.queryId(ctx, "category_id", "CategoryUpdate")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[CategoryUpdate]: %v",
+			err,
+		))
 		return
 	}
-	orderId, err := strconv.ParseInt(key, 10, 64)
+	category := dto.CategoryUpdate{}
+	err = jsoniter.Unmarshal(body, &category)
 	if err != nil {
-		catchErrGin(ctx, http.StatusBadRequest, "Failed to parse int64 order_id from query", fmt.Errorf(
-			"[OrderDelete]: %v", err,
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[CategoryUpdate]: %v",
+			err,
 		))
 		return
 	}
 
-	err = h.validator.Var(orderId, "required,gt=0")
+	err = h.validator.Struct(category)
 	if err != nil {
-		translatedErr := translateError(err, h.ts)
+		translatedErr := translateError(err, h.validator.ts)
 		catchErrGin(
 			ctx,
 			http.StatusBadRequest,
-			fmt.Sprintf("The order_id validation failed. %v", translatedErr),
-			fmt.Errorf("[OrderDelete]: %v", err),
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[CategoryUpdate]: %v", err),
 		)
 		return
 	}
 
-	err = h.service.OrderDelete(orderId)
+	err = h.service.CategoryUpdate(ctx.Request.Context(), categoryId, &category)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoryUpdate]: %w", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CategoryDelete(ctx *gin.Context) {
+	categoryId, ok := h.queryId(ctx, "category_id", "CategoryDelete")
+	if !ok {
+		return
+	}
+
+	err := h.service.CategoryDelete(ctx.Request.Context(), categoryId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoryDelete]: %w", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CategoryGoodsAdd(ctx *gin.Context) {
+	categoryId, ok := h.queryId(ctx, "category_id", "CategoryGoodsAdd")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		catchErrGin(ctx, http.StatusInternalServerError, "Failed to read body", fmt.Errorf(
+			"[CategoryGoodsAdd]: %v",
+			err,
+		))
+		return
+	}
+	goods := struct {
+		GoodsId int64 `json:"goods_id" validate:"required,gt=0"`
+	}{}
+	err = jsoniter.Unmarshal(body, &goods)
 	if err != nil {
-		catchErrGin(ctx, http.StatusInternalServerError, "Request to DB doesn't succeed", fmt.Errorf(
-			"[OrderDelete]: %v",
+		catchErrGin(ctx, http.StatusUnprocessableEntity, "Failed to unmarshal body", fmt.Errorf(
+			"[CategoryGoodsAdd]: %v",
 			err,
 		))
 		return
 	}
 
+	err = h.validator.Struct(goods)
+	if err != nil {
+		translatedErr := translateError(err, h.validator.ts)
+		catchErrGin(
+			ctx,
+			http.StatusBadRequest,
+			fmt.Sprintf("Body validation failed. %v", translatedErr),
+			fmt.Errorf("[CategoryGoodsAdd]: %v", err),
+		)
+		return
+	}
+
+	err = h.service.CategoryAddGoods(ctx.Request.Context(), categoryId, goods.GoodsId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoryGoodsAdd]: %w", err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (h *ApiHandlers) CategoryGoodsDelete(ctx *gin.Context) {
+	categoryId, ok := h.queryId(ctx, "category_id", "CategoryGoodsDelete")
+	if !ok {
+		return
+	}
+	goodsId, ok := h.queryId(ctx, "goods_id", "CategoryGoodsDelete")
+	if !ok {
+		return
+	}
+
+	err := h.service.CategoryDeleteGoods(ctx.Request.Context(), categoryId, goodsId)
+	if err != nil {
+		_ = ctx.Error(fmt.Errorf("[CategoryGoodsDelete]: %w", err))
+		return
+	}
 	ctx.Status(http.StatusNoContent)
 }
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"store_api/internal/domain/errs"
+	"time"
+)
+
+// queryDeadline - ограничивает время обработки запроса, чтобы запросы к БД отменялись
+// по истечении таймаута или при отключении клиента
+func queryDeadline(timeout time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
+
+// bodyLimit - ограничивает размер тела запроса, чтение сверх limit байт завершается *http.MaxBytesError
+func bodyLimit(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if limit > 0 {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		}
+		ctx.Next()
+	}
+}
+
+// errorHandler - переводит ошибки, переданные хэндлерами через ctx.Error, в статус код и JSON ответ
+// вида {"error": "...", "code": "..."}, где code - стабильный машиночитаемый код ошибки
+func errorHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+		err := ctx.Errors.Last().Err
+		domainErr, ok := errs.As(err)
+		if !ok {
+			logrus.Errorf("Request to DB doesn't succeed. Error: %s", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Request to DB doesn't succeed",
+				"code":  errs.Internal,
+			})
+			return
+		}
+		logrus.Warnf("Request failed. Error: %s", err)
+		ctx.AbortWithStatusJSON(statusByKind(domainErr.Kind), gin.H{
+			"error": domainErr.Message,
+			"code":  domainErr.Kind,
+		})
+	}
+}
+
+// statusByKind - статус код ответа для вида доменной ошибки
+func statusByKind(kind errs.Kind) int {
+	switch kind {
+	case errs.NotFound:
+		return http.StatusNotFound
+	case errs.Conflict, errs.InsufficientStock:
+		return http.StatusConflict
+	case errs.Validation:
+		return http.StatusUnprocessableEntity
+	case errs.VersionConflict:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusInternalServerError
+}
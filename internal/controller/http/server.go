@@ -3,55 +3,113 @@ package http
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/locales/en"
-	ut "github.com/go-playground/universal-translator"
-	"github.com/go-playground/validator/v10"
-	enTranslations "github.com/go-playground/validator/v10/translations/en"
-	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
+	"store_api/internal/config"
 )
 
 // ApiServer - http сервер на основе gin регистрирующий хэндлеры и запускающий http сервер
 type ApiServer struct {
+	cfg      *config.Config
 	router   *gin.Engine
 	handlers *ApiHandlers
+	auth     *Authenticator
 }
 
 // NewApiServer - создание нового экземпляра ApiServer
-func NewApiServer() *ApiServer {
-	validate := validator.New()
-	eng := en.New()
-	uni := ut.New(eng, eng)
-	ts, _ := uni.GetTranslator("en")
-	err := enTranslations.RegisterDefaultTranslations(validate, ts)
-	if err != nil {
-		logrus.Panicf("[NewApiServer]: failed to RegisterDefaultTranslations. Error: %v", err)
-	}
-	return &ApiServer{
-		router:   gin.Default(),
-		handlers: NewApiHandlers(validate, ts),
+func NewApiServer(cfg *config.Config, handlers *ApiHandlers, auth *Authenticator) *ApiServer {
+	server := &ApiServer{
+		cfg:      cfg,
+		router:   newRouter(),
+		handlers: handlers,
+		auth:     auth,
 	}
+	server.registerHandlers()
+	return server
 }
 
-// RunHttpApi - регистрация хэндлеров и запуск http сервера
-func (r ApiServer) RunHttpApi() error {
-	api := r.router.Group("/api")
+// newRouter - gin роутер с логированием, восстановлением после паники и обработкой ошибок хэндлеров
+func newRouter() *gin.Engine {
+	router := gin.Default()
+	router.Use(errorHandler())
+	return router
+}
+
+// Handler - роутер с зарегистрированными хэндлерами, пригоден для httptest
+func (r ApiServer) Handler() *gin.Engine {
+	return r.router
+}
+
+// registerHandlers - регистрация хэндлеров. Каталог, курсы валют и регистрация покупателя доступны
+// без токена, корзины и заказы - с токеном покупателя, изменение каталога и статусов заказов - с токеном админа.
+// Импорт и экспорт каталога ограничены отдельным таймаутом db.bulk_timeout, остальные запросы - db.query_timeout.
+// Размер файла импорта ограничен server.import_max_bytes
+func (r ApiServer) registerHandlers() {
+	root := r.router.Group("/api")
+	bulk := root.Group("", queryDeadline(r.cfg.DB.BulkTimeout))
+	{
+		bulk.GET("/goods/export", r.handlers.GoodsExport)
+		bulk.POST(
+			"/goods/import",
+			authenticate(r.auth),
+			requireRole(roleAdmin),
+			bodyLimit(r.cfg.Server.ImportMaxBytes),
+			r.handlers.GoodsImport,
+		)
+	}
+
+	api := root.Group("", queryDeadline(r.cfg.DB.QueryTimeout))
 	{
-		api.POST("/goods/add", r.handlers.GoodsAdd)
+		api.GET("/goods", r.handlers.GoodsList)
+		api.GET("/goods/search", r.handlers.GoodsSearch)
 		api.GET("/goods/get", r.handlers.GoodsGet)
-		api.PUT("/goods/update", r.handlers.GoodsUpdate)
-		api.DELETE("/goods/delete", r.handlers.GoodsDelete)
-		api.POST("/carts/create", r.handlers.CartCreate)
-		api.PUT("/carts/goods/add", r.handlers.CartGoodsAdd)
-		api.GET("/carts/goods/get", r.handlers.CartGoodsGet)
-		api.DELETE("/carts/goods/delete", r.handlers.CartGoodsDelete)
-		api.DELETE("/carts/delete", r.handlers.CartDelete)
-		api.POST("/orders/create", r.handlers.OrderCreate)
-		api.GET("/orders/get", r.handlers.OrderGet)
-		api.PUT("/orders/update", r.handlers.OrderUpdate)
-		api.DELETE("/orders/delete", r.handlers.OrderDelete)
+		api.GET("/goods/variants/get", r.handlers.VariantGet)
+		api.GET("/categories", r.handlers.CategoriesGet)
+		api.GET("/categories/get", r.handlers.CategoryGet)
+		api.POST("/customers/add", r.handlers.CustomerAdd)
+		api.GET("/exchange_rates/get", r.handlers.ExchangeRatesGet)
 	}
-	err := r.router.Run(viper.GetString("server.host"))
+
+	customer := api.Group("", authenticate(r.auth), requireRole(roleCustomer))
+	{
+		customer.GET("/customers/get", r.handlers.CustomerGet)
+		customer.POST("/carts/create", r.handlers.CartCreate)
+		customer.PUT("/carts/goods/add", r.handlers.CartGoodsAdd)
+		customer.GET("/carts/goods/get", r.handlers.CartGoodsGet)
+		customer.PUT("/carts/goods/update", r.handlers.CartGoodsUpdate)
+		customer.DELETE("/carts/goods/delete", r.handlers.CartGoodsDelete)
+		customer.DELETE("/carts/delete", r.handlers.CartDelete)
+		customer.POST("/orders/create", r.handlers.OrderCreate)
+		customer.GET("/orders", r.handlers.OrderList)
+		customer.GET("/orders/get", r.handlers.OrderGet)
+		customer.GET("/orders/:order_id/transitions", r.handlers.OrderTransitionsGet)
+		customer.POST("/orders/:order_id/cancel", r.handlers.OrderCancel)
+		customer.DELETE("/orders/delete", r.handlers.OrderDelete)
+	}
+
+	admin := api.Group("", authenticate(r.auth), requireRole(roleAdmin))
+	{
+		admin.POST("/goods/add", r.handlers.GoodsAdd)
+		admin.PUT("/goods/update", r.handlers.GoodsUpdate)
+		admin.PATCH("/goods/:goods_id", r.handlers.GoodsPatch)
+		admin.DELETE("/goods/delete", r.handlers.GoodsDelete)
+		admin.POST("/goods/variants/add", r.handlers.VariantAdd)
+		admin.PUT("/goods/variants/update", r.handlers.VariantUpdate)
+		admin.DELETE("/goods/variants/delete", r.handlers.VariantDelete)
+		admin.POST("/categories/add", r.handlers.CategoryAdd)
+		admin.PUT("/categories/update", r.handlers.CategoryUpdate)
+		admin.DELETE("/categories/delete", r.handlers.CategoryDelete)
+		admin.PUT("/categories/goods/add", r.handlers.CategoryGoodsAdd)
+		admin.DELETE("/categories/goods/delete", r.handlers.CategoryGoodsDelete)
+		admin.POST("/orders/:order_id/transitions", r.handlers.OrderTransition)
+		admin.GET("/admin/orders", r.handlers.OrderListAll)
+		admin.GET("/admin/orders/:order_id/transitions", r.handlers.OrderTransitionsGetAll)
+		admin.DELETE("/admin/orders/purge", r.handlers.OrderPurge)
+		admin.PUT("/exchange_rates/update", r.handlers.ExchangeRateUpdate)
+	}
+}
+
+// RunHttpApi - запуск http сервера
+func (r ApiServer) RunHttpApi() error {
+	err := r.router.Run(r.cfg.Server.Host)
 	if err != nil {
 		return fmt.Errorf("[RunHttpApi]: failed to run gin router. Error: %v", err)
 	}
@@ -3,11 +3,37 @@ package http
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
 	"github.com/sirupsen/logrus"
+	"net/http"
+	"store_api/internal/domain/errs"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Validator - валидатор структур и значений с переводчиком ошибок валидации на английский
+type Validator struct {
+	*validator.Validate
+	ts ut.Translator
+}
+
+// NewValidator - создание валидатора с зарегистрированными английскими переводами ошибок
+func NewValidator() (*Validator, error) {
+	validate := validator.New()
+	eng := en.New()
+	uni := ut.New(eng, eng)
+	ts, _ := uni.GetTranslator("en")
+	err := enTranslations.RegisterDefaultTranslations(validate, ts)
+	if err != nil {
+		return nil, fmt.Errorf("[NewValidator]: failed to RegisterDefaultTranslations. Error: %v", err)
+	}
+	return &Validator{Validate: validate, ts: ts}, nil
+}
+
 // translateError - приводит ошибку валидации к человекочитабельному виду
 func translateError(err error, ts ut.Translator) error {
 	if err == nil {
@@ -21,13 +47,114 @@ func translateError(err error, ts ut.Translator) error {
 	return fmt.Errorf("%s", finalErr)
 }
 
-// catchErrGin - логирует ошибку и отправляет статус код и сообщение клиенту
+// queryCurrency - валюта из параметра currency запроса, пустая строка, если параметр не передан
+func queryCurrency(ctx *gin.Context, v *Validator) (string, error) {
+	currency := ctx.Request.URL.Query().Get("currency")
+	err := v.Var(currency, "omitempty,iso4217")
+	if err != nil {
+		return "", translateError(err, v.ts)
+	}
+	return currency, nil
+}
+
+// queryTime - время в формате RFC 3339 из параметра key запроса, nil, если параметр не передан
+func queryTime(ctx *gin.Context, key string) (*time.Time, error) {
+	value := ctx.Request.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s, expected RFC 3339 time: %v", key, err)
+	}
+	return &parsed, nil
+}
+
+// catchErrGin - логирует ошибку и отправляет статус код, сообщение и машиночитаемый код ошибки клиенту
 func catchErrGin(ctx *gin.Context, code int, msg string, err error) {
 	if err == nil {
 		logrus.Errorf("%s.", msg)
-		ctx.AbortWithStatusJSON(code, gin.H{"error": msg})
+		ctx.AbortWithStatusJSON(code, gin.H{"error": msg, "code": errCodeByStatus(code)})
 		return
 	}
 	logrus.Errorf("%s. Error: %s", msg, err)
-	ctx.AbortWithStatusJSON(code, gin.H{"error": msg})
+	ctx.AbortWithStatusJSON(code, gin.H{"error": msg, "code": errCodeByStatus(code)})
+}
+
+// errCodeByStatus - машиночитаемый код ошибки для ошибок, обнаруженных в самом хэндлере
+func errCodeByStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnprocessableEntity:
+		return "unprocessable_entity"
+	case http.StatusPreconditionRequired:
+		return "precondition_required"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	}
+	return string(errs.Internal)
+}
+
+// requestCustomer - идентификатор покупателя из subject токена запроса,
+// если subject не является customer_id, ответ с ошибкой уже отправлен
+func requestCustomer(ctx *gin.Context, op string) (int64, bool) {
+	subject, ok := requestActor(ctx, op)
+	if !ok {
+		return 0, false
+	}
+	customerId, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil || customerId <= 0 {
+		catchErrGin(ctx, http.StatusForbidden, "The token subject must be a customer id", fmt.Errorf(
+			"[%s]: token subject %q is not a customer id", op, subject))
+		return 0, false
+	}
+	return customerId, true
+}
+
+// requestActor - subject токена запроса, от имени которого меняется статус заказа,
+// для запроса без токена ответ с ошибкой уже отправлен
+func requestActor(ctx *gin.Context, op string) (string, bool) {
+	user := principalFrom(ctx.Request.Context())
+	if user == nil {
+		catchErrGin(ctx, http.StatusUnauthorized, "Bearer token required", fmt.Errorf(
+			"[%s]: request is not authenticated", op))
+		return "", false
+	}
+	return user.Subject, true
+}
+
+// etag - сильный ETag версии ресурса
+func etag(version int64) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(version, 10))
+}
+
+// ifMatch - версия ресурса из заголовка If-Match, с которой клиент его изменяет. Принимается только
+// сильный ETag из ответа сервера, при отсутствии или неверном формате заголовка ответ с ошибкой уже отправлен
+func ifMatch(ctx *gin.Context, op string) (int64, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		catchErrGin(ctx, http.StatusPreconditionRequired, "The If-Match header required", fmt.Errorf(
+			"[%s]: no If-Match header error", op))
+		return 0, false
+	}
+	value, err := strconv.Unquote(header)
+	if err != nil || !strings.HasPrefix(header, `"`) {
+		catchErrGin(ctx, http.StatusBadRequest, "The If-Match header must be a strong ETag", fmt.Errorf(
+			"[%s]: invalid If-Match header %s", op, header))
+		return 0, false
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		catchErrGin(ctx, http.StatusBadRequest, "The If-Match header must be a strong ETag", fmt.Errorf(
+			"[%s]: invalid If-Match header %s", op, header))
+		return 0, false
+	}
+	return version, true
 }
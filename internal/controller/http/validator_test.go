@@ -0,0 +1,57 @@
+package http
+
+import (
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"strings"
+	"testing"
+)
+
+func TestValidatorDTO(t *testing.T) {
+	v, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	price := models.NewMoney(49999, "RUB")
+	long := strings.Repeat("ю", 41)
+	tests := []struct {
+		name  string
+		value interface{}
+		valid bool
+	}{
+		{"goods create", dto.GoodsCreate{Name: "Ноутбук", Price: price, Quantity: 1}, true},
+		{"goods create out of stock", dto.GoodsCreate{Name: "Ноутбук", Price: price}, true},
+		{"goods create negative quantity", dto.GoodsCreate{Name: "Ноутбук", Price: price, Quantity: -1}, false},
+		{"goods create max name", dto.GoodsCreate{Name: long[2:], Price: price, Quantity: 1}, true},
+		{"goods create long name", dto.GoodsCreate{Name: long, Price: price, Quantity: 1}, false},
+		{"goods create no name", dto.GoodsCreate{Price: price, Quantity: 1}, false},
+		{"goods update out of stock", dto.GoodsUpdate{Name: "Ноутбук", Price: price}, true},
+		{"goods update long name", dto.GoodsUpdate{Name: long, Price: price, Quantity: 1}, false},
+		{"goods update negative quantity", dto.GoodsUpdate{Name: "Ноутбук", Price: price, Quantity: -1}, false},
+		{"variant create", dto.VariantCreate{Sku: "LAPTOP-32GB", Price: price}, true},
+		{"variant create no price", dto.VariantCreate{Sku: "LAPTOP-32GB"}, false},
+		{"variant create zero price", dto.VariantCreate{Sku: "LAPTOP-32GB", Price: models.NewMoney(0, "RUB")}, false},
+		{"variant create negative price", dto.VariantCreate{Sku: "LAPTOP-32GB", Price: models.NewMoney(-1, "RUB")}, false},
+		{"variant create no currency", dto.VariantCreate{Sku: "LAPTOP-32GB", Price: models.NewMoney(100, "")}, false},
+		{"variant create unknown currency", dto.VariantCreate{Sku: "LAPTOP-32GB", Price: models.NewMoney(100, "XYZ")}, false},
+		{"variant update", dto.VariantUpdate{Sku: "LAPTOP-32GB", Price: price}, true},
+		{"variant update negative price", dto.VariantUpdate{Sku: "LAPTOP-32GB", Price: models.NewMoney(-1, "RUB")}, false},
+		{"variant update unknown currency", dto.VariantUpdate{Sku: "LAPTOP-32GB", Price: models.NewMoney(100, "XYZ")}, false},
+		{"variant update negative quantity", dto.VariantUpdate{Sku: "LAPTOP-32GB", Price: price, Quantity: -1}, false},
+		{"order cancel", dto.OrderCancel{Reason: "changed mind"}, true},
+		{"order cancel without reason", dto.OrderCancel{}, true},
+		{"order cancel long reason", dto.OrderCancel{Reason: strings.Repeat("ю", 501)}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.value)
+			if tt.valid && err != nil {
+				t.Fatalf("expected %+v to be valid, got %v", tt.value, err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatalf("expected %+v to be invalid", tt.value)
+			}
+		})
+	}
+}
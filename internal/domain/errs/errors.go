@@ -0,0 +1,83 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind - вид доменной ошибки, он же стабильный машиночитаемый код ошибки для клиента
+type Kind string
+
+const (
+	Internal          Kind = "internal"
+	NotFound          Kind = "not_found"
+	Conflict          Kind = "conflict"
+	InsufficientStock Kind = "insufficient_stock"
+	Validation        Kind = "validation_failed"
+	// VersionConflict - версия ресурса не совпала с ожидаемой: его изменили после того, как клиент его прочитал
+	VersionConflict Kind = "version_conflict"
+)
+
+// Эталонные ошибки для сравнения через errors.Is(err, errs.ErrNotFound)
+var (
+	ErrNotFound          = &Error{Kind: NotFound}
+	ErrConflict          = &Error{Kind: Conflict}
+	ErrInsufficientStock = &Error{Kind: InsufficientStock}
+	ErrValidation        = &Error{Kind: Validation}
+	ErrVersionConflict   = &Error{Kind: VersionConflict}
+)
+
+// Error - доменная ошибка: вид, сообщение, которое можно показать клиенту, и исходная причина
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is - доменные ошибки равны, если совпадает их вид
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// New - создание доменной ошибки вида kind
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap - оборачивает err в доменную ошибку вида kind
+func Wrap(kind Kind, err error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// As - достаёт из цепочки err доменную ошибку, если она там есть
+func As(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// KindOf - вид доменной ошибки в цепочке err, Internal если доменной ошибки нет
+func KindOf(err error) Kind {
+	domainErr, ok := As(err)
+	if !ok {
+		return Internal
+	}
+	return domainErr.Kind
+}
@@ -1,9 +1,59 @@
 package models
 
+import "fmt"
+
 // Cart - корзина в магазине
 type Cart struct {
-	CartId   int64 `json:"cart_id" db:"cart_id" validate:"required,gt=0"`
-	GoodsId  int64 `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
-	Quantity int64 `json:"quantity" db:"quantity" validate:"required,gt=0"`
-	Total    int64 `json:"total" db:"total" validate:"required,gt=0"`
+	CartId int64      `json:"cart_id" db:"cart_id" validate:"required,gt=0"`
+	Goods  []CartItem `json:"goods" db:"-"`
+	Total  Money      `json:"total" db:"-"`
+	// Version - версия корзины, меняется при каждом изменении её позиций
+	Version int64 `json:"version" db:"version"`
+}
+
+// CartItem - позиция корзины: вариант товара и его количество в корзине
+type CartItem struct {
+	GoodsId    int64      `json:"goods_id" db:"goods_id"`
+	VariantId  int64      `json:"variant_id" db:"variant_id"`
+	Sku        string     `json:"sku" db:"sku"`
+	Name       string     `json:"name" db:"name"`
+	Attributes Attributes `json:"attributes" db:"attributes"`
+	Price      Money      `json:"price" db:"price"`
+	Quantity   int64      `json:"quantity" db:"quantity"`
+	Total      Money      `json:"total" db:"-"`
+}
+
+// CalcTotal - пересчитывает стоимость каждой позиции и итоговую стоимость корзины.
+// Все позиции корзины должны быть в одной валюте
+func (c *Cart) CalcTotal() error {
+	c.Total = NewMoney(0, DefaultCurrency())
+	if len(c.Goods) > 0 {
+		c.Total.Currency = c.Goods[0].Price.Currency
+	}
+	for i := range c.Goods {
+		c.Goods[i].Total = c.Goods[i].Price.Mul(c.Goods[i].Quantity)
+		total, err := c.Total.Add(c.Goods[i].Total)
+		if err != nil {
+			return fmt.Errorf("failed to calc total of cart with id %d: %w", c.CartId, err)
+		}
+		c.Total = total
+	}
+	return nil
+}
+
+// Convert - переводит цены позиций корзины в валюту currency по курсам rates и пересчитывает итог
+func (c *Cart) Convert(rates *ExchangeRates, currency string) error {
+	for i := range c.Goods {
+		price, err := rates.Convert(c.Goods[i].Price, currency)
+		if err != nil {
+			return fmt.Errorf("failed to convert cart with id %d: %w", c.CartId, err)
+		}
+		c.Goods[i].Price = price
+	}
+	err := c.CalcTotal()
+	if err != nil {
+		return err
+	}
+	c.Total.Currency = currency
+	return nil
 }
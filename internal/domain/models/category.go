@@ -0,0 +1,36 @@
+package models
+
+// Category - категория товаров, ParentId равен nil у корневых категорий
+type Category struct {
+	CategoryId int64  `json:"category_id" db:"category_id"`
+	ParentId   *int64 `json:"parent_id" db:"parent_id"`
+	Name       string `json:"name" db:"name"`
+}
+
+// CategoryNode - категория вместе с дочерними категориями
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// NewCategoryTree - дерево категорий из плоского списка, упорядоченного по category_id
+func NewCategoryTree(categories []Category) []CategoryNode {
+	children := make(map[int64][]Category, len(categories))
+	roots := make([]Category, 0)
+	for _, category := range categories {
+		if category.ParentId == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentId] = append(children[*category.ParentId], category)
+	}
+	var build func(level []Category) []CategoryNode
+	build = func(level []Category) []CategoryNode {
+		nodes := make([]CategoryNode, 0, len(level))
+		for _, category := range level {
+			nodes = append(nodes, CategoryNode{Category: category, Children: build(children[category.CategoryId])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Customer - покупатель магазина, владелец корзин и заказов
+type Customer struct {
+	CustomerId int64     `json:"customer_id" db:"customer_id"`
+	Email      string    `json:"email" db:"email"`
+	Name       string    `json:"name" db:"name"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
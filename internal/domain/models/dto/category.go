@@ -0,0 +1,13 @@
+package dto
+
+// CategoryCreate - данные для создания категории, parent_id не указывается у корневой категории
+type CategoryCreate struct {
+	ParentId *int64 `json:"parent_id" db:"parent_id" validate:"omitempty,gt=0"`
+	Name     string `json:"name" db:"name" validate:"required,max=60"`
+}
+
+// CategoryUpdate - данные для обновления категории, в том числе её переноса в другую родительскую
+type CategoryUpdate struct {
+	ParentId *int64 `json:"parent_id" db:"parent_id" validate:"omitempty,gt=0"`
+	Name     string `json:"name" db:"name" validate:"required,max=60"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+// CustomerCreate - данные для регистрации покупателя, email уникален среди покупателей
+type CustomerCreate struct {
+	Email string `json:"email" db:"email" validate:"required,email,max=254"`
+	Name  string `json:"name" db:"name" validate:"required,max=100"`
+}
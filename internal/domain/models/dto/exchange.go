@@ -0,0 +1,6 @@
+package dto
+
+// ExchangeRateUpdate - установка курса валюты: сколько единиц валюты стоит одна единица базовой валюты
+type ExchangeRateUpdate struct {
+	Rate string `json:"rate" validate:"required,numeric"`
+}
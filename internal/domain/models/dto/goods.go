@@ -1,14 +1,25 @@
 package dto
 
+import "store_api/internal/domain/models"
+
+// GoodsCreate - данные для создания товара в магазине, goods_id выдаёт БД
+type GoodsCreate struct {
+	Name     string       `json:"name" db:"name" validate:"required,max=40"`
+	Price    models.Money `json:"price" db:"price"`
+	Quantity int64        `json:"quantity" db:"quantity" validate:"gte=0"`
+}
+
 // GoodsUpdate - данные для обновления информации о товаре в магазине
 type GoodsUpdate struct {
-	Name     string `json:"name" db:"name" validate:"required"`
-	Price    string `json:"price" db:"price" validate:"required,gt=0"`
-	Quantity int64  `json:"quantity" db:"quantity" validate:"required,gte=0"`
+	Name     string       `json:"name" db:"name" validate:"required,max=40"`
+	Price    models.Money `json:"price" db:"price"`
+	Quantity int64        `json:"quantity" db:"quantity" validate:"gte=0"`
 }
 
-// GoodsAdd - данные для добавления товара в корзину
+// GoodsAdd - данные для добавления варианта товара в корзину.
+// variant_id можно не указывать, если у товара один вариант
 type GoodsAdd struct {
-	GoodsId  int64 `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
-	Quantity int64 `json:"quantity" db:"quantity" validate:"required,gt=0"`
+	GoodsId   int64 `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
+	VariantId int64 `json:"variant_id" db:"variant_id" validate:"omitempty,gt=0"`
+	Quantity  int64 `json:"quantity" db:"quantity" validate:"required,gt=0"`
 }
@@ -0,0 +1,25 @@
+package dto
+
+import "store_api/internal/domain/models"
+
+// GoodsImportFormat - формат файла импорта и экспорта каталога
+type GoodsImportFormat string
+
+const (
+	GoodsImportCSV    GoodsImportFormat = "csv"
+	GoodsImportNDJSON GoodsImportFormat = "ndjson"
+)
+
+// GoodsImportRow - прошедшая валидацию строка импорта каталога.
+// Товар без goods_id добавляется, товар с goods_id обновляется как при GoodsUpdate
+type GoodsImportRow struct {
+	// Line - номер строки во входных данных, по нему сообщается об ошибках
+	Line int64
+	models.Goods
+}
+
+// GoodsImportReader - источник строк импорта, Next возвращает io.EOF после последней строки.
+// Строки читаются по одной, поэтому источник можно прочитать только один раз
+type GoodsImportReader interface {
+	Next() (*GoodsImportRow, error)
+}
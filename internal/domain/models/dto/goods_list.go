@@ -0,0 +1,109 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"store_api/internal/domain/models"
+	"strings"
+)
+
+// GoodsSort - поле сортировки каталога
+type GoodsSort string
+
+const (
+	GoodsSortId    GoodsSort = "id"
+	GoodsSortPrice GoodsSort = "price"
+	GoodsSortName  GoodsSort = "name"
+)
+
+const (
+	// GoodsListDefaultLimit - размер страницы каталога по умолчанию
+	GoodsListDefaultLimit = 20
+	// GoodsListMaxLimit - максимальный размер страницы каталога
+	GoodsListMaxLimit = 100
+)
+
+// GoodsFilter - фильтры, сортировка и позиция страницы при получении списка товаров
+type GoodsFilter struct {
+	// Name - подстрока названия без учёта регистра
+	Name string
+	// PriceMin, PriceMax - границы цены включительно, фильтр по цене оставляет только товары в валюте границ
+	PriceMin *models.Money
+	PriceMax *models.Money
+	// InStock - только товары с ненулевым остатком
+	InStock bool
+	// CategoryId - только товары категории и всех её подкатегорий, 0 - без фильтра
+	CategoryId int64
+	Sort       GoodsSort
+	Desc       bool
+	Limit      int
+	// After - курсор последнего товара предыдущей страницы, nil для первой страницы
+	After *GoodsCursor
+}
+
+// GoodsCursor - позиция в каталоге: значения ключа сортировки последнего товара страницы
+type GoodsCursor struct {
+	Sort    GoodsSort `json:"s"`
+	Desc    bool      `json:"d,omitempty"`
+	Price   int64     `json:"p,omitempty"`
+	Name    string    `json:"n,omitempty"`
+	GoodsId int64     `json:"id"`
+}
+
+// NewGoodsCursor - курсор, указывающий на товар goods при сортировке filter
+func NewGoodsCursor(filter *GoodsFilter, goods *models.Goods) *GoodsCursor {
+	cursor := &GoodsCursor{Sort: filter.Sort, Desc: filter.Desc, GoodsId: goods.GoodsId}
+	switch filter.Sort {
+	case GoodsSortPrice:
+		cursor.Price = goods.Price.Amount
+	case GoodsSortName:
+		cursor.Name = goods.Name
+	}
+	return cursor
+}
+
+// NewGoodsPage - страница из выборки не более чем filter.Limit+1 товаров,
+// лишний товар означает наличие следующей страницы
+func NewGoodsPage(filter *GoodsFilter, goods []models.Goods) *models.GoodsPage {
+	page := &models.GoodsPage{Goods: goods}
+	if len(goods) > filter.Limit {
+		page.Goods = goods[:filter.Limit]
+		page.NextCursor = NewGoodsCursor(filter, &page.Goods[filter.Limit-1]).Encode()
+	}
+	return page
+}
+
+// Encode - непрозрачное представление курсора для передачи клиенту
+func (c *GoodsCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeGoodsCursor - разбор курсора, полученного от клиента
+func DecodeGoodsCursor(value string) (*GoodsCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	cursor := &GoodsCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// ParseGoodsSort - разбор параметра sort вида "price" или "-price" (по убыванию)
+func ParseGoodsSort(value string) (GoodsSort, bool, error) {
+	if value == "" {
+		return GoodsSortId, false, nil
+	}
+	desc := strings.HasPrefix(value, "-")
+	sort := GoodsSort(strings.TrimPrefix(value, "-"))
+	switch sort {
+	case GoodsSortId, GoodsSortPrice, GoodsSortName:
+		return sort, desc, nil
+	}
+	return "", false, fmt.Errorf("unknown sort field %q", sort)
+}
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"sort"
+	"store_api/internal/domain/models"
+)
+
+// GoodsPatch - частичное обновление информации о товаре в формате JSON Merge Patch (RFC 7396).
+// Поля со значением nil не меняются
+type GoodsPatch struct {
+	Name     *string       `json:"name" validate:"omitempty,min=1,max=40"`
+	Price    *models.Money `json:"price"`
+	Quantity *int64        `json:"quantity" validate:"omitempty,gte=0"`
+}
+
+// goodsPatchFields - поля товара, которые можно изменить через GoodsPatch
+var goodsPatchFields = map[string]bool{"name": true, "price": true, "quantity": true}
+
+// DecodeGoodsPatch - разбор тела merge patch товара. Все поля товара обязательны,
+// поэтому удаление поля через null, как и неизвестные поля, считается ошибкой
+func DecodeGoodsPatch(data []byte) (*GoodsPatch, error) {
+	fields := make(map[string]jsoniter.RawMessage)
+	err := jsoniter.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("merge patch must change at least one field")
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !goodsPatchFields[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		// jsoniter декодирует null в пустой RawMessage
+		value := bytes.TrimSpace(fields[name])
+		if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+			return nil, fmt.Errorf("field %q cannot be removed", name)
+		}
+	}
+
+	patch := &GoodsPatch{}
+	err = jsoniter.Unmarshal(data, patch)
+	if err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
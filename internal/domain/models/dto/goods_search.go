@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// GoodsSearchDefaultLimit - количество результатов поиска по умолчанию
+	GoodsSearchDefaultLimit = 20
+	// GoodsSearchMaxLimit - максимальное количество результатов поиска
+	GoodsSearchMaxLimit = 100
+)
+
+// searchTerm - слово поискового запроса: буквы и цифры, остальные символы разделяют слова
+var searchTerm = regexp.MustCompile(`[\pL\pN]+`)
+
+// GoodsSearch - полнотекстовый поиск товаров по названию
+type GoodsSearch struct {
+	// Terms - слова запроса в нижнем регистре, каждое ищется как префикс слова названия
+	Terms []string
+	Limit int
+}
+
+// NewGoodsSearch - разбор строки поискового запроса q
+func NewGoodsSearch(q string, limit int) *GoodsSearch {
+	return &GoodsSearch{Terms: searchTerm.FindAllString(strings.ToLower(q), -1), Limit: limit}
+}
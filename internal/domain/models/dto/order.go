@@ -1,12 +1,14 @@
 package dto
 
-import "time"
+import "store_api/internal/domain/models"
 
-// OrderUpdate - обновление информации о заказе
-type OrderUpdate struct {
-	GoodsId    int64      `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
-	Quantity   int64      `json:"quantity" db:"quantity" validate:"required,gt=0"`
-	Total      int64      `json:"total" db:"total" validate:"required,gt=0"`
-	OrderTime  *time.Time `json:"order_time" db:"order_time" validate:"omitempty"`
-	FinishTime time.Time  `json:"finish_time" db:"finish_time" validate:"required"`
+// OrderTransition - перевод заказа в другой статус с указанием причины
+type OrderTransition struct {
+	Status models.OrderStatus `json:"status" validate:"required,oneof=created paid shipped delivered cancelled refunded"`
+	Reason string             `json:"reason" validate:"max=500"`
+}
+
+// OrderCancel - отмена заказа покупателем с указанием причины
+type OrderCancel struct {
+	Reason string `json:"reason" validate:"max=500"`
 }
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"store_api/internal/domain/models"
+	"time"
+)
+
+const (
+	// OrderListDefaultLimit - размер страницы списка заказов по умолчанию
+	OrderListDefaultLimit = 20
+	// OrderListMaxLimit - максимальный размер страницы списка заказов
+	OrderListMaxLimit = 100
+)
+
+// OrderFilter - фильтры и позиция страницы при получении списка заказов.
+// Заказы отсортированы от новых к старым по order_time, при равном времени по убыванию order_id
+type OrderFilter struct {
+	// CustomerId - только заказы покупателя, 0 - без фильтра
+	CustomerId int64
+	// From, To - границы order_time: From включительно, To не включительно
+	From *time.Time
+	To   *time.Time
+	// Status - только заказы в статусе, пустая строка - без фильтра
+	Status models.OrderStatus
+	Limit  int
+	// After - курсор последнего заказа предыдущей страницы, nil для первой страницы
+	After *OrderCursor
+}
+
+// OrderCursor - позиция в списке заказов: время оформления и идентификатор последнего заказа страницы
+type OrderCursor struct {
+	OrderTime time.Time `json:"t"`
+	OrderId   int64     `json:"id"`
+}
+
+// NewOrderPage - страница из выборки не более чем filter.Limit+1 заказов,
+// лишний заказ означает наличие следующей страницы
+func NewOrderPage(filter *OrderFilter, orders []models.OrderSummary) *models.OrderPage {
+	page := &models.OrderPage{Orders: orders}
+	if len(orders) > filter.Limit {
+		page.Orders = orders[:filter.Limit]
+		last := &page.Orders[filter.Limit-1]
+		page.NextCursor = (&OrderCursor{OrderTime: last.OrderTime, OrderId: last.OrderId}).Encode()
+	}
+	return page
+}
+
+// Encode - непрозрачное представление курсора для передачи клиенту
+func (c *OrderCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeOrderCursor - разбор курсора, полученного от клиента
+func DecodeOrderCursor(value string) (*OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	cursor := &OrderCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	return cursor, nil
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "store_api/internal/domain/models"
+
+// VariantCreate - данные для создания варианта товара
+type VariantCreate struct {
+	Sku        string            `json:"sku" db:"sku" validate:"required,max=64"`
+	Attributes models.Attributes `json:"attributes" db:"attributes"`
+	Price      models.Money      `json:"price" db:"price" validate:"required"`
+	Quantity   int64             `json:"quantity" db:"quantity" validate:"gte=0"`
+}
+
+// VariantUpdate - данные для обновления варианта товара
+type VariantUpdate struct {
+	Sku        string            `json:"sku" db:"sku" validate:"required,max=64"`
+	Attributes models.Attributes `json:"attributes" db:"attributes"`
+	Price      models.Money      `json:"price" db:"price" validate:"required"`
+	Quantity   int64             `json:"quantity" db:"quantity" validate:"gte=0"`
+}
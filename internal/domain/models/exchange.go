@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ExchangeRate - курс валюты: сколько единиц Currency стоит одна единица базовой валюты
+type ExchangeRate struct {
+	Currency  string    `json:"currency" db:"currency" validate:"required,iso4217"`
+	Rate      string    `json:"rate" db:"rate" validate:"required,numeric"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ExchangeRates - набор курсов валют относительно базовой валюты base
+type ExchangeRates struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewExchangeRates - курсы валют из rates, курс базовой валюты base всегда равен 1
+func NewExchangeRates(base string, rates []ExchangeRate) (*ExchangeRates, error) {
+	r := &ExchangeRates{base: base, rates: make(map[string]*big.Rat, len(rates)+1)}
+	for _, rate := range rates {
+		value, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok || value.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", rate.Rate, rate.Currency)
+		}
+		r.rates[rate.Currency] = value
+	}
+	r.rates[base] = big.NewRat(1, 1)
+	return r, nil
+}
+
+// Base - базовая валюта курсов
+func (r *ExchangeRates) Base() string {
+	return r.base
+}
+
+// Rate - курс валюты currency относительно базовой
+func (r *ExchangeRates) Rate(currency string) (*big.Rat, bool) {
+	rate, ok := r.rates[currency]
+	return rate, ok
+}
+
+// RateString - курс валюты currency в десятичной записи для сохранения в заказе
+func (r *ExchangeRates) RateString(currency string) (string, bool) {
+	rate, ok := r.rates[currency]
+	if !ok {
+		return "", false
+	}
+	return rate.FloatString(10), true
+}
+
+// Convert - перевод суммы m в валюту to с округлением до минимальной единицы валюты to
+func (r *ExchangeRates) Convert(m Money, to string) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	fromRate, ok := r.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s", m.Currency)
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s", to)
+	}
+	amount := new(big.Rat).SetInt64(m.Amount)
+	amount.Quo(amount, new(big.Rat).SetInt(pow10(CurrencyExponent(m.Currency))))
+	amount.Quo(amount, fromRate)
+	amount.Mul(amount, toRate)
+	amount.Mul(amount, new(big.Rat).SetInt(pow10(CurrencyExponent(to))))
+	rounded, err := roundHalfUp(amount)
+	if err != nil {
+		return Money{}, fmt.Errorf("failed to convert %s to %s: %w", m, to, err)
+	}
+	return Money{Amount: rounded, Currency: to}, nil
+}
+
+// roundHalfUp - округление до целого, половины округляются от нуля
+func roundHalfUp(value *big.Rat) (int64, error) {
+	num := new(big.Int).Abs(value.Num())
+	quo, rem := new(big.Int).QuoRem(num, value.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if value.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("amount is too large")
+	}
+	return quo.Int64(), nil
+}
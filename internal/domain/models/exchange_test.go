@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExchangeRatesConvert(t *testing.T) {
+	rates, err := NewExchangeRates("RUB", []ExchangeRate{
+		{Currency: "USD", Rate: "0.0125"},
+		{Currency: "EUR", Rate: "0.011"},
+		{Currency: "JPY", Rate: "1.6"},
+		{Currency: "KWD", Rate: "0.0037"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		money Money
+		to    string
+		want  Money
+	}{
+		{"same currency", NewMoney(49999, "RUB"), "RUB", NewMoney(49999, "RUB")},
+		{"from base", NewMoney(10000, "RUB"), "USD", NewMoney(125, "USD")},
+		{"to base", NewMoney(125, "USD"), "RUB", NewMoney(10000, "RUB")},
+		{"cross rate", NewMoney(100, "USD"), "EUR", NewMoney(88, "EUR")},
+		{"round down", NewMoney(100, "RUB"), "USD", NewMoney(1, "USD")},
+		{"round half up", NewMoney(200, "RUB"), "USD", NewMoney(3, "USD")},
+		{"negative half away from zero", NewMoney(-200, "RUB"), "USD", NewMoney(-3, "USD")},
+		{"to zero decimals", NewMoney(50, "RUB"), "JPY", NewMoney(1, "JPY")},
+		{"to zero decimals rounds to zero", NewMoney(1, "RUB"), "JPY", NewMoney(0, "JPY")},
+		{"from zero decimals", NewMoney(16, "JPY"), "RUB", NewMoney(1000, "RUB")},
+		{"to three decimals", NewMoney(10000, "RUB"), "KWD", NewMoney(370, "KWD")},
+		{"from three decimals", NewMoney(370, "KWD"), "RUB", NewMoney(10000, "RUB")},
+	}
+	for _, tt := range tests {
+		got, err := rates.Convert(tt.money, tt.to)
+		if err != nil {
+			t.Errorf("%s: Convert(%v, %s): %v", tt.name, tt.money, tt.to, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Convert(%v, %s): expected %v, got %v", tt.name, tt.money, tt.to, tt.want, got)
+		}
+	}
+
+	_, err = rates.Convert(NewMoney(100, "RUB"), "GBP")
+	if err == nil {
+		t.Error("Convert: expected error for currency without rate")
+	}
+	_, err = rates.Convert(NewMoney(100, "GBP"), "RUB")
+	if err == nil {
+		t.Error("Convert: expected error for currency without rate")
+	}
+	_, err = rates.Convert(NewMoney(math.MaxInt64, "USD"), "RUB")
+	if err == nil {
+		t.Error("Convert: expected overflow error")
+	}
+}
+
+func TestNewExchangeRates(t *testing.T) {
+	for _, rate := range []string{"0", "-1.5", "abc", ""} {
+		_, err := NewExchangeRates("RUB", []ExchangeRate{{Currency: "USD", Rate: rate}})
+		if err == nil {
+			t.Errorf("NewExchangeRates: expected error for rate %q", rate)
+		}
+	}
+
+	rates, err := NewExchangeRates("RUB", []ExchangeRate{{Currency: "USD", Rate: "0.0125"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate, ok := rates.RateString("RUB"); !ok || rate != "1.0000000000" {
+		t.Errorf("RateString: expected base rate 1, got %q", rate)
+	}
+	if rate, ok := rates.RateString("USD"); !ok || rate != "0.0125000000" {
+		t.Errorf("RateString: expected 0.0125, got %q", rate)
+	}
+	if _, ok := rates.RateString("EUR"); ok {
+		t.Error("RateString: expected no rate for EUR")
+	}
+}
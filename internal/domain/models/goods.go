@@ -3,7 +3,27 @@ package models
 // Goods - товар в магазине
 type Goods struct {
 	GoodsId  int64  `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
-	Name     string `json:"name" db:"name" validate:"required"`
-	Price    string `json:"price" db:"price" validate:"required,gt=0"`
-	Quantity int64  `json:"quantity" db:"quantity" validate:"required,gte=0"`
+	Name     string `json:"name" db:"name" validate:"required,max=40"`
+	Price    Money  `json:"price" db:"price"`
+	Quantity int64  `json:"quantity" db:"quantity" validate:"gte=0"`
+	// Version - версия товара, меняется при каждом изменении товара и его вариантов
+	Version int64 `json:"version" db:"version"`
+	// Variants - варианты товара, заполняются только при получении одного товара
+	Variants []Variant `json:"variants,omitempty" db:"-"`
+}
+
+// GoodsPage - страница каталога товаров
+type GoodsPage struct {
+	Goods []Goods `json:"goods"`
+	// NextCursor - курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GoodsSearchResult - товар, найденный полнотекстовым поиском
+type GoodsSearchResult struct {
+	Goods
+	// Rank - релевантность товара запросу, результаты отсортированы по её убыванию
+	Rank float64 `json:"rank" db:"rank"`
+	// Snippet - название товара, экранированное как HTML, с выделенными тегами <b></b> совпадениями
+	Snippet string `json:"snippet" db:"snippet"`
 }
@@ -0,0 +1,18 @@
+package models
+
+// GoodsImportResult - итог импорта каталога
+type GoodsImportResult struct {
+	// Inserted - количество добавленных товаров
+	Inserted int64 `json:"inserted"`
+	// Updated - количество обновлённых товаров
+	Updated int64 `json:"updated"`
+	// Errors - строки, которые не были импортированы, в порядке номеров строк
+	Errors []GoodsImportError `json:"errors"`
+}
+
+// GoodsImportError - причина, по которой строка импорта не была импортирована
+type GoodsImportError struct {
+	// Line - номер строки во входных данных, начиная с 1
+	Line  int64  `json:"line"`
+	Error string `json:"error"`
+}
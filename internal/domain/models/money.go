@@ -0,0 +1,190 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Форматы вывода суммы в JSON
+const (
+	// MoneyFormatDecimal - строка в основных единицах: "499.99"
+	MoneyFormatDecimal = "decimal"
+	// MoneyFormatNumber - число в основных единицах: 499.99
+	MoneyFormatNumber = "number"
+	// MoneyFormatMinor - целое число в минимальных единицах: 49999
+	MoneyFormatMinor = "minor"
+)
+
+var (
+	moneyFormat     = MoneyFormatDecimal
+	defaultCurrency = "RUB"
+)
+
+// currencyExponents - число знаков после запятой у валют, отличных от стандартных двух
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// SetMoneyFormat - настройка формата сумм в JSON и валюты для сумм, переданных без валюты
+func SetMoneyFormat(format, currency string) error {
+	switch format {
+	case "":
+		format = MoneyFormatDecimal
+	case MoneyFormatDecimal, MoneyFormatNumber, MoneyFormatMinor:
+	default:
+		return fmt.Errorf("unknown money format %q", format)
+	}
+	if currency != "" {
+		defaultCurrency = strings.ToUpper(currency)
+	}
+	moneyFormat = format
+	return nil
+}
+
+// DefaultCurrency - валюта по умолчанию для сумм без явно указанной валюты
+func DefaultCurrency() string {
+	return defaultCurrency
+}
+
+// CurrencyExponent - число знаков после запятой в валюте currency
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Money - денежная сумма в минимальных единицах валюты (копейках, центах) и ISO 4217 код валюты
+type Money struct {
+	Amount   int64  `db:"amount" validate:"gt=0"`
+	Currency string `db:"currency" validate:"required,iso4217"`
+}
+
+// NewMoney - сумма amount в минимальных единицах валюты currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney - разбор суммы в основных единицах вида "499.99" в валюте currency
+func ParseMoney(value, currency string) (Money, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid money amount %q", value)
+	}
+	rat.Mul(rat, new(big.Rat).SetInt(pow10(CurrencyExponent(currency))))
+	if !rat.IsInt() {
+		return Money{}, fmt.Errorf("money amount %q has too many decimal places for %s", value, currency)
+	}
+	if !rat.Num().IsInt64() {
+		return Money{}, fmt.Errorf("money amount %q is too large", value)
+	}
+	return Money{Amount: rat.Num().Int64(), Currency: currency}, nil
+}
+
+// Mul - стоимость quantity единиц по цене m
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// Add - сумма двух сумм в одной валюте
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Decimal - сумма в основных единицах валюты: "499.99"
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	var amount string
+	switch moneyFormat {
+	case MoneyFormatMinor:
+		amount = strconv.FormatInt(m.Amount, 10)
+	case MoneyFormatNumber:
+		amount = m.Decimal()
+	default:
+		amount = strconv.Quote(m.Decimal())
+	}
+	return []byte(`{"amount":` + amount + `,"currency":` + strconv.Quote(m.Currency) + `}`), nil
+}
+
+// UnmarshalJSON - принимает объект {"amount": ..., "currency": "RUB"} или просто сумму в валюте по умолчанию.
+// Сумма-строка всегда в основных единицах, сумма-число - в формате, заданном SetMoneyFormat
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	raw := struct {
+		Amount   jsoniter.RawMessage `json:"amount"`
+		Currency string              `json:"currency"`
+	}{Amount: data, Currency: defaultCurrency}
+	if len(data) > 0 && data[0] == '{' {
+		err := jsoniter.Unmarshal(data, &raw)
+		if err != nil {
+			return err
+		}
+		raw.Currency = strings.ToUpper(raw.Currency)
+	}
+	parsed, err := parseJSONAmount(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func parseJSONAmount(amount []byte, currency string) (Money, error) {
+	if len(amount) == 0 {
+		return Money{}, fmt.Errorf("money amount is required")
+	}
+	if amount[0] == '"' {
+		var value string
+		err := jsoniter.Unmarshal(amount, &value)
+		if err != nil {
+			return Money{}, err
+		}
+		return ParseMoney(value, currency)
+	}
+	if moneyFormat == MoneyFormatMinor {
+		value, err := strconv.ParseInt(string(amount), 10, 64)
+		if err != nil {
+			return Money{}, fmt.Errorf("invalid money amount %s: %w", amount, err)
+		}
+		return Money{Amount: value, Currency: currency}, nil
+	}
+	return ParseMoney(string(amount), currency)
+}
+
+func pow10(exp int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"testing"
+)
+
+// withMoneyFormat - формат сумм на время теста, после теста восстанавливается формат по умолчанию
+func withMoneyFormat(t *testing.T, format string) {
+	t.Helper()
+	err := SetMoneyFormat(format, "RUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetMoneyFormat(MoneyFormatDecimal, "RUB") })
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"499.99", "RUB", 49999, false},
+		{"499.9", "RUB", 49990, false},
+		{"499", "RUB", 49900, false},
+		{" 0.01 ", "USD", 1, false},
+		{"0", "RUB", 0, false},
+		{"-5.50", "RUB", -550, false},
+		{"1.005", "RUB", 0, true},
+		{"1500", "JPY", 1500, false},
+		{"1500.5", "JPY", 0, true},
+		{"1.234", "KWD", 1234, false},
+		{"1.2345", "KWD", 0, true},
+		{"0.5", "BHD", 500, false},
+		{"", "RUB", 0, true},
+		{"abc", "RUB", 0, true},
+		{"12.3.4", "RUB", 0, true},
+		{"92233720368547758.08", "RUB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %s): expected error, got %v", tt.value, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got != NewMoney(tt.want, tt.currency) {
+			t.Errorf("ParseMoney(%q, %s): expected %d, got %+v", tt.value, tt.currency, tt.want, got)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{NewMoney(49999, "RUB"), "499.99"},
+		{NewMoney(5, "RUB"), "0.05"},
+		{NewMoney(0, "RUB"), "0.00"},
+		{NewMoney(-550, "RUB"), "-5.50"},
+		{NewMoney(-5, "USD"), "-0.05"},
+		{NewMoney(1500, "JPY"), "1500"},
+		{NewMoney(-1500, "JPY"), "-1500"},
+		{NewMoney(1234, "KWD"), "1.234"},
+		{NewMoney(7, "BHD"), "0.007"},
+	}
+	for _, tt := range tests {
+		got := tt.money.Decimal()
+		if got != tt.want {
+			t.Errorf("Decimal(%+v): expected %s, got %s", tt.money, tt.want, got)
+		}
+		parsed, err := ParseMoney(got, tt.money.Currency)
+		if err != nil || parsed != tt.money {
+			t.Errorf("ParseMoney(Decimal(%+v)): expected round trip, got %+v, %v", tt.money, parsed, err)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(150, "RUB").Add(NewMoney(-50, "RUB"))
+	if err != nil || sum != NewMoney(100, "RUB") {
+		t.Fatalf("Add: expected 1.00 RUB, got %v, %v", sum, err)
+	}
+	_, err = NewMoney(150, "RUB").Add(NewMoney(50, "USD"))
+	if err == nil {
+		t.Fatal("Add: expected error for different currencies")
+	}
+	if got := NewMoney(49999, "RUB").Mul(3); got != NewMoney(149997, "RUB") {
+		t.Fatalf("Mul: expected 1499.97 RUB, got %v", got)
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		format string
+		money  Money
+		want   string
+	}{
+		{MoneyFormatDecimal, NewMoney(49999, "RUB"), `{"amount":"499.99","currency":"RUB"}`},
+		{MoneyFormatDecimal, NewMoney(-550, "RUB"), `{"amount":"-5.50","currency":"RUB"}`},
+		{MoneyFormatNumber, NewMoney(49999, "RUB"), `{"amount":499.99,"currency":"RUB"}`},
+		{MoneyFormatNumber, NewMoney(1500, "JPY"), `{"amount":1500,"currency":"JPY"}`},
+		{MoneyFormatMinor, NewMoney(1234, "KWD"), `{"amount":1234,"currency":"KWD"}`},
+	}
+	for _, tt := range tests {
+		withMoneyFormat(t, tt.format)
+		data, err := tt.money.MarshalJSON()
+		if err != nil {
+			t.Fatalf("MarshalJSON(%+v): %v", tt.money, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("MarshalJSON(%+v) in %s format: expected %s, got %s", tt.money, tt.format, tt.want, data)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		format  string
+		data    string
+		want    Money
+		wantErr bool
+	}{
+		{MoneyFormatDecimal, `{"amount":"499.99","currency":"RUB"}`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatDecimal, `{"amount":"10","currency":"usd"}`, NewMoney(1000, "USD"), false},
+		{MoneyFormatDecimal, `{"amount":499.99,"currency":"RUB"}`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatDecimal, `"499.99"`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatDecimal, `499.99`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatDecimal, `{"amount":"-5.5","currency":"RUB"}`, NewMoney(-550, "RUB"), false},
+		{MoneyFormatDecimal, `{"amount":"1.5","currency":"JPY"}`, Money{}, true},
+		{MoneyFormatDecimal, `{"amount":"1.234","currency":"KWD"}`, NewMoney(1234, "KWD"), false},
+		{MoneyFormatMinor, `{"amount":49999,"currency":"RUB"}`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatMinor, `{"amount":"499.99","currency":"RUB"}`, NewMoney(49999, "RUB"), false},
+		{MoneyFormatMinor, `{"amount":499.99,"currency":"RUB"}`, Money{}, true},
+		{MoneyFormatDecimal, `{"amount":}`, Money{}, true},
+		{MoneyFormatDecimal, `{"amount":"abc","currency":"RUB"}`, Money{}, true},
+		{MoneyFormatDecimal, `{"amount":true,"currency":"RUB"}`, Money{}, true},
+		{MoneyFormatDecimal, `{"currency":"RUB"}`, Money{}, true},
+		{MoneyFormatDecimal, `"499.99`, Money{}, true},
+		{MoneyFormatDecimal, `[1]`, Money{}, true},
+	}
+	for _, tt := range tests {
+		withMoneyFormat(t, tt.format)
+		var got Money
+		err := got.UnmarshalJSON([]byte(tt.data))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("UnmarshalJSON(%s) in %s format: expected error, got %+v", tt.data, tt.format, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s) in %s format: %v", tt.data, tt.format, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("UnmarshalJSON(%s) in %s format: expected %+v, got %+v", tt.data, tt.format, tt.want, got)
+		}
+	}
+}
+
+func TestSetMoneyFormat(t *testing.T) {
+	withMoneyFormat(t, MoneyFormatDecimal)
+	err := SetMoneyFormat("cents", "RUB")
+	if err == nil {
+		t.Fatal("SetMoneyFormat: expected error for unknown format")
+	}
+	err = SetMoneyFormat("", "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if DefaultCurrency() != "USD" {
+		t.Fatalf("SetMoneyFormat: expected default currency USD, got %s", DefaultCurrency())
+	}
+}
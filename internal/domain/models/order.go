@@ -1,13 +1,98 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Order - заказ в магазине
 type Order struct {
-	OrderId    int64      `json:"order_id" db:"order_id" validate:"required,gt=0"`
-	GoodsId    int64      `json:"goods_id" db:"goods_id" validate:"required,gt=0"`
-	Quantity   int64      `json:"quantity" db:"quantity" validate:"required,gt=0"`
-	Total      int64      `json:"total" db:"total" validate:"required,gt=0"`
-	OrderTime  *time.Time `json:"order_time" db:"order_time" validate:"omitempty"`
-	FinishTime time.Time  `json:"finish_time" db:"finish_time" validate:"required"`
+	OrderId int64 `json:"order_id" db:"order_id"`
+	// CustomerId - покупатель, оформивший заказ, nil для заказов без покупателя
+	CustomerId *int64      `json:"customer_id" db:"customer_id"`
+	Goods      []OrderItem `json:"goods" db:"-"`
+	Total      Money       `json:"total" db:"total"`
+	// ExchangeRate - курс валюты заказа относительно базовой валюты на момент оформления
+	ExchangeRate string      `json:"exchange_rate" db:"exchange_rate"`
+	Status       OrderStatus `json:"status" db:"status"`
+	OrderTime    time.Time   `json:"order_time" db:"order_time"`
+	// FinishTime - время перехода заказа в завершающий статус
+	FinishTime *time.Time `json:"finish_time" db:"finish_time"`
+}
+
+// OrderSummary - заголовок заказа для списка заказов: без позиций, только их количество
+type OrderSummary struct {
+	OrderId    int64       `json:"order_id" db:"order_id"`
+	CustomerId *int64      `json:"customer_id" db:"customer_id"`
+	Total      Money       `json:"total" db:"total"`
+	Status     OrderStatus `json:"status" db:"status"`
+	// ItemCount - число позиций заказа
+	ItemCount int64 `json:"item_count" db:"item_count"`
+	// Quantity - число единиц товаров во всех позициях заказа
+	Quantity   int64      `json:"quantity" db:"quantity"`
+	OrderTime  time.Time  `json:"order_time" db:"order_time"`
+	FinishTime *time.Time `json:"finish_time" db:"finish_time"`
+}
+
+// OrderPage - страница списка заказов
+type OrderPage struct {
+	Orders []OrderSummary `json:"orders"`
+	// NextCursor - курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// OrderItem - позиция заказа: вариант товара, его количество и цена на момент оформления
+type OrderItem struct {
+	GoodsId    int64      `json:"goods_id" db:"goods_id"`
+	VariantId  int64      `json:"variant_id" db:"variant_id"`
+	Sku        string     `json:"sku" db:"sku"`
+	Name       string     `json:"name" db:"name"`
+	Attributes Attributes `json:"attributes" db:"attributes"`
+	Price      Money      `json:"price" db:"price"`
+	Quantity   int64      `json:"quantity" db:"quantity"`
+}
+
+// Total - стоимость позиции заказа
+func (i OrderItem) Total() Money {
+	return i.Price.Mul(i.Quantity)
+}
+
+// CalcTotal - пересчитывает итоговую стоимость заказа по его позициям.
+// Все позиции заказа должны быть в одной валюте
+func (o *Order) CalcTotal() error {
+	o.Total = NewMoney(0, DefaultCurrency())
+	if len(o.Goods) > 0 {
+		o.Total.Currency = o.Goods[0].Price.Currency
+	}
+	for _, item := range o.Goods {
+		total, err := o.Total.Add(item.Total())
+		if err != nil {
+			return fmt.Errorf("failed to calc total of order: %w", err)
+		}
+		o.Total = total
+	}
+	return nil
+}
+
+// Convert - переводит цены позиций заказа в валюту currency по курсам rates,
+// пересчитывает итог и фиксирует использованный курс
+func (o *Order) Convert(rates *ExchangeRates, currency string) error {
+	rate, ok := rates.RateString(currency)
+	if !ok {
+		return fmt.Errorf("no exchange rate for %s", currency)
+	}
+	for i := range o.Goods {
+		price, err := rates.Convert(o.Goods[i].Price, currency)
+		if err != nil {
+			return fmt.Errorf("failed to convert order: %w", err)
+		}
+		o.Goods[i].Price = price
+	}
+	err := o.CalcTotal()
+	if err != nil {
+		return err
+	}
+	o.Total.Currency = currency
+	o.ExchangeRate = rate
+	return nil
 }
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// OrderStatus - статус заказа
+type OrderStatus string
+
+const (
+	OrderCreated   OrderStatus = "created"
+	OrderPaid      OrderStatus = "paid"
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions - статусы, в которые заказ может перейти из текущего
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderCreated:   {OrderPaid, OrderCancelled},
+	OrderPaid:      {OrderShipped, OrderRefunded},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderRefunded},
+}
+
+// CanTransitionTo - разрешён ли переход заказа из статуса s в статус next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, status := range orderTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Final - завершается ли заказ в статусе s: при переходе в него заказу проставляется finish_time
+func (s OrderStatus) Final() bool {
+	return s == OrderDelivered || s == OrderCancelled || s == OrderRefunded
+}
+
+// Restocks - возвращаются ли позиции заказа на склад при переходе из статуса s в статус next: при отмене
+// и при возврате денег за оплаченный, но ещё не отправленный заказ. Отправленные товары остаются у покупателя
+func (s OrderStatus) Restocks(next OrderStatus) bool {
+	return next == OrderCancelled || (s == OrderPaid && next == OrderRefunded)
+}
+
+// OrderStatusChange - запись истории статусов заказа: кто, когда и почему перевёл заказ из From в To
+type OrderStatusChange struct {
+	OrderId   int64       `json:"order_id" db:"order_id"`
+	From      OrderStatus `json:"from" db:"from_status"`
+	To        OrderStatus `json:"to" db:"to_status"`
+	Actor     string      `json:"actor" db:"actor"`
+	Reason    string      `json:"reason" db:"reason"`
+	ChangedAt time.Time   `json:"changed_at" db:"changed_at"`
+}
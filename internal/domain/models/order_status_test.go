@@ -0,0 +1,70 @@
+package models
+
+import "testing"
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	statuses := []OrderStatus{OrderCreated, OrderPaid, OrderShipped, OrderDelivered, OrderCancelled, OrderRefunded}
+	allowed := map[OrderStatus][]OrderStatus{
+		OrderCreated:   {OrderPaid, OrderCancelled},
+		OrderPaid:      {OrderShipped, OrderRefunded},
+		OrderShipped:   {OrderDelivered},
+		OrderDelivered: {OrderRefunded},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, status := range allowed[from] {
+				if status == to {
+					want = true
+				}
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("CanTransitionTo(%s -> %s): expected %v, got %v", from, to, want, got)
+			}
+		}
+		if from.CanTransitionTo("lost") {
+			t.Errorf("CanTransitionTo(%s -> lost): expected unknown status to be rejected", from)
+		}
+		if OrderStatus("lost").CanTransitionTo(from) {
+			t.Errorf("CanTransitionTo(lost -> %s): expected unknown status to be rejected", from)
+		}
+	}
+}
+
+func TestOrderStatusFinal(t *testing.T) {
+	tests := []struct {
+		status OrderStatus
+		final  bool
+	}{
+		{OrderCreated, false},
+		{OrderPaid, false},
+		{OrderShipped, false},
+		{OrderDelivered, true},
+		{OrderCancelled, true},
+		{OrderRefunded, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Final(); got != tt.final {
+			t.Errorf("Final(%s): expected %v, got %v", tt.status, tt.final, got)
+		}
+	}
+}
+
+func TestOrderStatusRestocks(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		restocks bool
+	}{
+		{OrderCreated, OrderPaid, false},
+		{OrderCreated, OrderCancelled, true},
+		{OrderPaid, OrderShipped, false},
+		{OrderPaid, OrderRefunded, true},
+		{OrderShipped, OrderDelivered, false},
+		{OrderDelivered, OrderRefunded, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.Restocks(tt.to); got != tt.restocks {
+			t.Errorf("Restocks(%s -> %s): expected %v, got %v", tt.from, tt.to, tt.restocks, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Variant - вариант товара (SKU) со своими характеристиками, ценой и остатком на складе
+type Variant struct {
+	VariantId  int64      `json:"variant_id" db:"variant_id"`
+	GoodsId    int64      `json:"goods_id" db:"goods_id"`
+	Sku        string     `json:"sku" db:"sku"`
+	Attributes Attributes `json:"attributes" db:"attributes"`
+	Price      Money      `json:"price" db:"price"`
+	Quantity   int64      `json:"quantity" db:"quantity"`
+}
+
+// DefaultSku - артикул варианта, создаваемого вместе с товаром
+func DefaultSku(goodsId int64) string {
+	return fmt.Sprintf("GOODS-%d", goodsId)
+}
+
+// Attributes - характеристики варианта товара: цвет, объём памяти и т.п., хранятся в jsonb
+type Attributes map[string]interface{}
+
+// Value - сериализация характеристик для записи в jsonb
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return jsoniter.Marshal(a)
+}
+
+// Scan - чтение характеристик из jsonb
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch value := src.(type) {
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	case nil:
+		*a = Attributes{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into attributes", src)
+	}
+	attributes := Attributes{}
+	err := jsoniter.Unmarshal(data, &attributes)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal attributes: %w", err)
+	}
+	*a = attributes
+	return nil
+}
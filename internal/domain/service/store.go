@@ -1,165 +1,704 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"store_api/internal/domain/errs"
 	"store_api/internal/domain/models"
 	"store_api/internal/domain/models/dto"
 	"store_api/internal/repository"
-	"store_api/internal/repository/postgresql"
+	"time"
 )
 
 // StoreService - сервисная логика взаимодействия с репозиторием приёмки
 type StoreService interface {
-	// GoodsAdd - добавление товара
-	GoodsAdd(goods *models.Goods) error
-	// GoodsGet - получение информации о товаре, возвращает товар
-	GoodsGet(goodsId int64) (*models.Goods, error)
-	// GoodsUpdate - обновление информации о товаре
-	GoodsUpdate(goodsId int64, goods *dto.GoodsUpdate) error
-	// GoodsDelete - удаление товара
-	GoodsDelete(goodsId int64) error
-	// CartCreate - создание корзины
-	CartCreate(cart *models.Cart) error
-	// CartAddGoods - добавление товара в корзину
-	CartAddGoods(goods *dto.GoodsAdd) error
-	// CartGetGoods - получение списка товаров в корзине
-	CartGetGoods() ([]models.Goods, error)
-	// CartGoodsUpdate - обновление информации о товаре в корзине
-	CartGoodsUpdate(cartId, goodsId, quantity int64) error
-	// CartDeleteGoods - удаление товара из корзины
-	CartDeleteGoods(cartId, goodsId int64) error
-	// CartDelete - удаление корзины
-	CartDelete(cartId int64) error
-	// OrderCreate - оформление заказа на основе корзины
-	OrderCreate(cartId int64) (*models.Order, error)
+	// GoodsAdd - добавление товара, возвращает созданный товар
+	GoodsAdd(ctx context.Context, goods *dto.GoodsCreate) (*models.Goods, error)
+	// GoodsGet - получение информации о товаре и его вариантах с ценами в валюте currency, пустая currency - валюта товара
+	GoodsGet(ctx context.Context, goodsId int64, currency string) (*models.Goods, error)
+	// GoodsList - страница каталога товаров, отобранных и отсортированных по filter
+	GoodsList(ctx context.Context, filter *dto.GoodsFilter) (*models.GoodsPage, error)
+	// GoodsSearch - полнотекстовый поиск товаров по названию в порядке релевантности
+	GoodsSearch(ctx context.Context, search *dto.GoodsSearch) ([]models.GoodsSearchResult, error)
+	// GoodsUpdate - обновление информации о товаре версии version, возвращает новую версию
+	GoodsUpdate(ctx context.Context, goodsId, version int64, goods *dto.GoodsUpdate) (int64, error)
+	// GoodsPatch - изменение переданных полей товара версии version, возвращает новую версию
+	GoodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch) (int64, error)
+	// GoodsDelete - удаление товара версии version
+	GoodsDelete(ctx context.Context, goodsId, version int64) error
+	// GoodsImport - добавление и обновление товаров из rows в одной транзакции через промежуточную таблицу.
+	// Строки, которые нельзя применить, пропускаются и возвращаются в Errors, версия обновлённых товаров увеличивается
+	GoodsImport(ctx context.Context, rows dto.GoodsImportReader) (*models.GoodsImportResult, error)
+	// GoodsExport - передача всех товаров в порядке goods_id в fn по одному, без загрузки каталога в память
+	GoodsExport(ctx context.Context, fn func(goods *models.Goods) error) error
+	// VariantAdd - добавление варианта к товару версии version, возвращает созданный вариант и новую версию товара
+	VariantAdd(ctx context.Context, goodsId, version int64, variant *dto.VariantCreate) (*models.Variant, int64, error)
+	// VariantGet - получение информации о варианте товара
+	VariantGet(ctx context.Context, variantId int64) (*models.Variant, error)
+	// VariantUpdate - обновление варианта товара версии version, остаток товара пересчитывается.
+	// Возвращает новую версию товара
+	VariantUpdate(ctx context.Context, variantId, version int64, variant *dto.VariantUpdate) (int64, error)
+	// VariantDelete - удаление варианта товара версии version, последний вариант удалить нельзя.
+	// Возвращает новую версию товара
+	VariantDelete(ctx context.Context, variantId, version int64) (int64, error)
+	// CategoryAdd - добавление категории, возвращает созданную категорию
+	CategoryAdd(ctx context.Context, category *dto.CategoryCreate) (*models.Category, error)
+	// CategoryGet - получение информации о категории
+	CategoryGet(ctx context.Context, categoryId int64) (*models.Category, error)
+	// CategoriesGet - получение дерева всех категорий
+	CategoriesGet(ctx context.Context) ([]models.CategoryNode, error)
+	// CategoryUpdate - обновление категории, категорию нельзя перенести в её же поддерево
+	CategoryUpdate(ctx context.Context, categoryId int64, category *dto.CategoryUpdate) error
+	// CategoryDelete - удаление категории без дочерних категорий
+	CategoryDelete(ctx context.Context, categoryId int64) error
+	// CategoryAddGoods - добавление товара в категорию
+	CategoryAddGoods(ctx context.Context, categoryId, goodsId int64) error
+	// CategoryDeleteGoods - удаление товара из категории
+	CategoryDeleteGoods(ctx context.Context, categoryId, goodsId int64) error
+	// CustomerAdd - регистрация покупателя, возвращает созданного покупателя
+	CustomerAdd(ctx context.Context, customer *dto.CustomerCreate) (*models.Customer, error)
+	// CustomerGet - получение информации о покупателе customerId от его имени
+	CustomerGet(ctx context.Context, customerId, requestedId int64) (*models.Customer, error)
+
+	// Методы корзин и заказов выполняются от имени покупателя customerId.
+	// Чужие корзины и заказы для покупателя не существуют: на них возвращается NotFound
+
+	// CartCreate - создание пустой корзины покупателя, возвращает созданную корзину
+	CartCreate(ctx context.Context, customerId int64) (*models.Cart, error)
+	// CartAddGoods - добавление варианта товара в корзину версии version, возвращает новую версию корзины
+	CartAddGoods(ctx context.Context, customerId, cartId, version int64, goods *dto.GoodsAdd) (int64, error)
+	// CartGetGoods - получение корзины с позициями и итоговой стоимостью в валюте currency
+	CartGetGoods(ctx context.Context, customerId, cartId int64, currency string) (*models.Cart, error)
+	// CartGoodsUpdate - обновление количества варианта товара в корзине версии version, возвращает новую версию корзины
+	CartGoodsUpdate(ctx context.Context, customerId, cartId, variantId, version, quantity int64) (int64, error)
+	// CartDeleteGoods - удаление варианта товара из корзины версии version, возвращает новую версию корзины
+	CartDeleteGoods(ctx context.Context, customerId, cartId, variantId, version int64) (int64, error)
+	// CartDelete - удаление корзины версии version
+	CartDelete(ctx context.Context, customerId, cartId, version int64) error
+	// OrderCreate - оформление заказа на основе корзины в валюте currency по текущему курсу
+	OrderCreate(ctx context.Context, customerId, cartId int64, currency string) (*models.Order, error)
 	// OrderGet - получение информации о заказе
-	OrderGet(orderId int64) (*models.Order, error)
-	// OrderUpdate - обновление информации о заказе
-	OrderUpdate(orderId int64, order *dto.OrderUpdate) error
-	// OrderDelete - Получение списка товаров в корзине
-	OrderDelete(orderId int64) error
+	OrderGet(ctx context.Context, customerId, orderId int64) (*models.Order, error)
+	// OrderList - страница заголовков заказов покупателя по фильтрам, от новых к старым
+	OrderList(ctx context.Context, customerId int64, filter *dto.OrderFilter) (*models.OrderPage, error)
+	// OrderStatusHistory - история статусов заказа в порядке изменения
+	OrderStatusHistory(ctx context.Context, customerId, orderId int64) ([]models.OrderStatusChange, error)
+	// OrderCancel - отмена заказа с возвратом его позиций на склад, повторная отмена ничего не меняет
+	OrderCancel(ctx context.Context, customerId, orderId int64, actor, reason string) error
+
+	// OrderTransition - перевод заказа в другой статус по таблице переходов, actor - кто переводит заказ
+	OrderTransition(ctx context.Context, orderId int64, actor string, transition *dto.OrderTransition) (*models.OrderStatusChange, error)
+	// OrderListAll - страница заголовков заказов всех покупателей по фильтрам, от новых к старым
+	OrderListAll(ctx context.Context, filter *dto.OrderFilter) (*models.OrderPage, error)
+	// OrderStatusHistoryAll - история статусов заказа любого покупателя в порядке изменения
+	OrderStatusHistoryAll(ctx context.Context, orderId int64) ([]models.OrderStatusChange, error)
+	// OrderPurge - удаление завершённого заказа вместе с позициями и историей статусов
+	OrderPurge(ctx context.Context, orderId int64) error
+	// ExchangeRatesGet - получение курсов валют относительно базовой валюты
+	ExchangeRatesGet(ctx context.Context) ([]models.ExchangeRate, error)
+	// ExchangeRateSet - установка курса валюты относительно базовой валюты
+	ExchangeRateSet(ctx context.Context, rate *models.ExchangeRate) error
 }
 
 type Store struct {
 	rep repository.StoreRepository
 }
 
-func NewStore() (*Store, error) {
-	storeRep, err := postgresql.NewStoreRepository()
+// NewStore - создание сервиса поверх репозитория rep
+func NewStore(rep repository.StoreRepository) *Store {
+	return &Store{rep: rep}
+}
+
+func (s *Store) GoodsAdd(ctx context.Context, goods *dto.GoodsCreate) (*models.Goods, error) {
+	created, err := s.rep.GoodsAdd(ctx, goods)
+	if err != nil {
+		return nil, fmt.Errorf("[GoodsAdd]: %w", err)
+	}
+	return created, nil
+}
+
+func (s *Store) GoodsGet(ctx context.Context, goodsId int64, currency string) (*models.Goods, error) {
+	goods, err := s.rep.GoodsGet(ctx, goodsId)
+	if err != nil {
+		return nil, fmt.Errorf("[GoodsGet]: %w", err)
+	}
+	if currency == "" {
+		return goods, nil
+	}
+	rates, err := s.exchangeRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[GoodsGet]: %w", err)
+	}
+	err = convertGoods(goods, rates, currency)
 	if err != nil {
-		return nil, fmt.Errorf("[NewStore]: %v", err)
+		return nil, fmt.Errorf("[GoodsGet]: %w", errs.New(
+			errs.Validation,
+			"failed to convert price of goods with id %d: %v",
+			goodsId,
+			err,
+		))
 	}
-	return &Store{rep: storeRep}, nil
+	return goods, nil
 }
 
-func (s *Store) GoodsAdd(goods *models.Goods) error {
-	err := s.rep.GoodsAdd(goods)
+// convertGoods - перевод цены товара и цен его вариантов в валюту currency
+func convertGoods(goods *models.Goods, rates *models.ExchangeRates, currency string) error {
+	var err error
+	goods.Price, err = rates.Convert(goods.Price, currency)
 	if err != nil {
-		return fmt.Errorf("[GoodsAdd]: %s", err)
+		return err
+	}
+	for i := range goods.Variants {
+		goods.Variants[i].Price, err = rates.Convert(goods.Variants[i].Price, currency)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (s *Store) GoodsGet(goodsId int64) (*models.Goods, error) {
-	goods, err := s.rep.GoodsGet(goodsId)
+func (s *Store) GoodsList(ctx context.Context, filter *dto.GoodsFilter) (*models.GoodsPage, error) {
+	if filter.After != nil && (filter.After.Sort != filter.Sort || filter.After.Desc != filter.Desc) {
+		return nil, fmt.Errorf("[GoodsList]: %w", errs.New(errs.Validation, "cursor was issued for another sort order"))
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = dto.GoodsListDefaultLimit
+	}
+	if filter.CategoryId != 0 {
+		_, err := s.rep.CategoryGet(ctx, filter.CategoryId)
+		if err != nil {
+			return nil, fmt.Errorf("[GoodsList]: %w", err)
+		}
+	}
+	page, err := s.rep.GoodsList(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("[GoodsGet]: %s", err)
+		return nil, fmt.Errorf("[GoodsList]: %w", err)
 	}
-	return goods, nil
+	return page, nil
 }
 
-func (s *Store) GoodsUpdate(goodsId int64, goods *dto.GoodsUpdate) error {
-	err := s.rep.GoodsUpdate(goodsId, goods)
+func (s *Store) GoodsSearch(ctx context.Context, search *dto.GoodsSearch) ([]models.GoodsSearchResult, error) {
+	if len(search.Terms) == 0 {
+		return nil, fmt.Errorf("[GoodsSearch]: %w", errs.New(errs.Validation, "search query contains no words"))
+	}
+	if search.Limit <= 0 {
+		search.Limit = dto.GoodsSearchDefaultLimit
+	}
+	found, err := s.rep.GoodsSearch(ctx, search)
 	if err != nil {
-		return fmt.Errorf("[GoodsUpdate]: %s", err)
+		return nil, fmt.Errorf("[GoodsSearch]: %w", err)
+	}
+	return found, nil
+}
+
+func (s *Store) GoodsUpdate(ctx context.Context, goodsId, version int64, goods *dto.GoodsUpdate) (int64, error) {
+	next, err := s.rep.GoodsUpdate(ctx, goodsId, version, goods)
+	if err != nil {
+		return 0, fmt.Errorf("[GoodsUpdate]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) GoodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch) (int64, error) {
+	next, err := s.rep.GoodsPatch(ctx, goodsId, version, patch)
+	if err != nil {
+		return 0, fmt.Errorf("[GoodsPatch]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) GoodsDelete(ctx context.Context, goodsId, version int64) error {
+	err := s.rep.GoodsDelete(ctx, goodsId, version)
+	if err != nil {
+		return fmt.Errorf("[GoodsDelete]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) GoodsDelete(goodsId int64) error {
-	err := s.rep.GoodsDelete(goodsId)
+func (s *Store) GoodsImport(ctx context.Context, rows dto.GoodsImportReader) (*models.GoodsImportResult, error) {
+	result, err := s.rep.GoodsImport(ctx, rows)
+	if err != nil {
+		return nil, fmt.Errorf("[GoodsImport]: %w", err)
+	}
+	return result, nil
+}
+
+func (s *Store) GoodsExport(ctx context.Context, fn func(goods *models.Goods) error) error {
+	err := s.rep.GoodsExport(ctx, fn)
 	if err != nil {
-		return fmt.Errorf("[GoodsDelete]: %s", err)
+		return fmt.Errorf("[GoodsExport]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) CartCreate(cart *models.Cart) error {
-	err := s.rep.CartCreate(cart)
+func (s *Store) VariantAdd(ctx context.Context, goodsId, version int64, variant *dto.VariantCreate) (*models.Variant, int64, error) {
+	created, next, err := s.rep.VariantAdd(ctx, goodsId, version, variant)
 	if err != nil {
-		return fmt.Errorf("[CartCreate]: %s", err)
+		return nil, 0, fmt.Errorf("[VariantAdd]: %w", err)
+	}
+	return created, next, nil
+}
+
+func (s *Store) VariantGet(ctx context.Context, variantId int64) (*models.Variant, error) {
+	variant, err := s.rep.VariantGet(ctx, variantId)
+	if err != nil {
+		return nil, fmt.Errorf("[VariantGet]: %w", err)
+	}
+	return variant, nil
+}
+
+func (s *Store) VariantUpdate(ctx context.Context, variantId, version int64, variant *dto.VariantUpdate) (int64, error) {
+	next, err := s.rep.VariantUpdate(ctx, variantId, version, variant)
+	if err != nil {
+		return 0, fmt.Errorf("[VariantUpdate]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) VariantDelete(ctx context.Context, variantId, version int64) (int64, error) {
+	next, err := s.rep.VariantDelete(ctx, variantId, version)
+	if err != nil {
+		return 0, fmt.Errorf("[VariantDelete]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) CategoryAdd(ctx context.Context, category *dto.CategoryCreate) (*models.Category, error) {
+	created, err := s.rep.CategoryAdd(ctx, category)
+	if err != nil {
+		return nil, fmt.Errorf("[CategoryAdd]: %w", err)
+	}
+	return created, nil
+}
+
+func (s *Store) CategoryGet(ctx context.Context, categoryId int64) (*models.Category, error) {
+	category, err := s.rep.CategoryGet(ctx, categoryId)
+	if err != nil {
+		return nil, fmt.Errorf("[CategoryGet]: %w", err)
+	}
+	return category, nil
+}
+
+func (s *Store) CategoriesGet(ctx context.Context) ([]models.CategoryNode, error) {
+	categories, err := s.rep.CategoriesGet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[CategoriesGet]: %w", err)
+	}
+	return models.NewCategoryTree(categories), nil
+}
+
+func (s *Store) CategoryUpdate(ctx context.Context, categoryId int64, category *dto.CategoryUpdate) error {
+	err := s.rep.CategoryUpdate(ctx, categoryId, category)
+	if err != nil {
+		return fmt.Errorf("[CategoryUpdate]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) CartAddGoods(goods *dto.GoodsAdd) error {
-	err := s.rep.CartAddGoods(goods)
+func (s *Store) CategoryDelete(ctx context.Context, categoryId int64) error {
+	err := s.rep.CategoryDelete(ctx, categoryId)
 	if err != nil {
-		return fmt.Errorf("[CartAddGoods]: %s", err)
+		return fmt.Errorf("[CategoryDelete]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) CartGetGoods() ([]models.Goods, error) {
-	goods, err := s.rep.CartGetGoods()
+func (s *Store) CategoryAddGoods(ctx context.Context, categoryId, goodsId int64) error {
+	err := s.rep.CategoryAddGoods(ctx, categoryId, goodsId)
 	if err != nil {
-		return nil, fmt.Errorf("[CartGetGoods]: %s", err)
+		return fmt.Errorf("[CategoryAddGoods]: %w", err)
 	}
-	return goods, nil
+	return nil
 }
 
-func (s *Store) CartGoodsUpdate(cartId, goodsId, quantity int64) error {
-	err := s.rep.CartGoodsUpdate(cartId, goodsId, quantity)
+func (s *Store) CategoryDeleteGoods(ctx context.Context, categoryId, goodsId int64) error {
+	err := s.rep.CategoryDeleteGoods(ctx, categoryId, goodsId)
 	if err != nil {
-		return fmt.Errorf("[CartGoodsUpdate]: %s", err)
+		return fmt.Errorf("[CategoryDeleteGoods]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) CartDeleteGoods(cartId, goodsId int64) error {
-	err := s.rep.CartDeleteGoods(cartId, goodsId)
+func (s *Store) CustomerAdd(ctx context.Context, customer *dto.CustomerCreate) (*models.Customer, error) {
+	created, err := s.rep.CustomerAdd(ctx, customer)
 	if err != nil {
-		return fmt.Errorf("[CartDeleteGoods]: %s", err)
+		return nil, fmt.Errorf("[CustomerAdd]: %w", err)
+	}
+	return created, nil
+}
+
+func (s *Store) CustomerGet(ctx context.Context, customerId, requestedId int64) (*models.Customer, error) {
+	if customerId != requestedId {
+		return nil, fmt.Errorf("[CustomerGet]: %w", errs.New(errs.NotFound, "failed to get customer with id %d", requestedId))
+	}
+	customer, err := s.rep.CustomerGet(ctx, requestedId)
+	if err != nil {
+		return nil, fmt.Errorf("[CustomerGet]: %w", err)
+	}
+	return customer, nil
+}
+
+// checkCartOwner - проверка, что корзина принадлежит покупателю customerId.
+// Чужая корзина неотличима от отсутствующей, чтобы по ответу нельзя было подобрать существующие cart_id
+func checkCartOwner(ctx context.Context, repo repository.StoreRepository, customerId, cartId int64) error {
+	owner, err := repo.CartOwner(ctx, cartId)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return err
+	}
+	if err != nil || owner != customerId {
+		return errs.New(errs.NotFound, "cart with id %d not found", cartId)
 	}
 	return nil
 }
 
-func (s *Store) CartDelete(cartId int64) error {
-	err := s.rep.CartDelete(cartId)
+// ownOrder - заказ, принадлежащий покупателю customerId. Чужой заказ неотличим от отсутствующего
+func ownOrder(ctx context.Context, repo repository.StoreRepository, customerId, orderId int64) (*models.Order, error) {
+	order, err := repo.OrderGet(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if order.CustomerId == nil || *order.CustomerId != customerId {
+		return nil, errs.New(errs.NotFound, "failed to get order with id %d", orderId)
+	}
+	return order, nil
+}
+
+func (s *Store) CartCreate(ctx context.Context, customerId int64) (*models.Cart, error) {
+	cart, err := s.rep.CartCreate(ctx, customerId)
 	if err != nil {
-		return fmt.Errorf("[CartDelete]: %s", err)
+		return nil, fmt.Errorf("[CartCreate]: %w", err)
+	}
+	return cart, nil
+}
+
+func (s *Store) CartAddGoods(ctx context.Context, customerId, cartId, version int64, goods *dto.GoodsAdd) (int64, error) {
+	var next int64
+	// Проверка наличия корзины и товара и добавление в корзину должны видеть одно и то же состояние БД
+	err := s.rep.WithTx(ctx, func(repo repository.StoreRepository) error {
+		err := checkCartOwner(ctx, repo, customerId, cartId)
+		if err != nil {
+			return err
+		}
+		stored, err := repo.GoodsGet(ctx, goods.GoodsId)
+		if err != nil {
+			return err
+		}
+		variant, err := pickVariant(stored, goods.VariantId)
+		if err != nil {
+			return err
+		}
+		// Корзина должна пересчитываться в валюту добавляемого варианта, иначе её нельзя будет оформить
+		_, err = repo.CartGetGoods(ctx, cartId, variant.Price.Currency)
+		if err != nil {
+			return err
+		}
+		line := *goods
+		line.VariantId = variant.VariantId
+		next, err = repo.CartAddGoods(ctx, cartId, version, &line)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("[CartAddGoods]: %w", err)
+	}
+	return next, nil
+}
+
+// pickVariant - вариант товара goods с идентификатором variantId или единственный вариант, если variantId не указан
+func pickVariant(goods *models.Goods, variantId int64) (*models.Variant, error) {
+	if variantId == 0 {
+		if len(goods.Variants) != 1 {
+			return nil, errs.New(
+				errs.Validation,
+				"goods with id %d has %d variants, variant_id is required",
+				goods.GoodsId,
+				len(goods.Variants),
+			)
+		}
+		return &goods.Variants[0], nil
+	}
+	for i := range goods.Variants {
+		if goods.Variants[i].VariantId == variantId {
+			return &goods.Variants[i], nil
+		}
+	}
+	return nil, errs.New(errs.NotFound, "variant with id %d of goods with id %d not found", variantId, goods.GoodsId)
+}
+
+func (s *Store) CartGetGoods(ctx context.Context, customerId, cartId int64, currency string) (*models.Cart, error) {
+	err := checkCartOwner(ctx, s.rep, customerId, cartId)
+	if err != nil {
+		return nil, fmt.Errorf("[CartGetGoods]: %w", err)
+	}
+	cart, err := s.rep.CartGetGoods(ctx, cartId, currencyOrDefault(currency))
+	if err != nil {
+		return nil, fmt.Errorf("[CartGetGoods]: %w", err)
+	}
+	return cart, nil
+}
+
+func (s *Store) CartGoodsUpdate(ctx context.Context, customerId, cartId, variantId, version, quantity int64) (int64, error) {
+	err := checkCartOwner(ctx, s.rep, customerId, cartId)
+	if err != nil {
+		return 0, fmt.Errorf("[CartGoodsUpdate]: %w", err)
+	}
+	next, err := s.rep.CartGoodsUpdate(ctx, cartId, variantId, version, quantity)
+	if err != nil {
+		return 0, fmt.Errorf("[CartGoodsUpdate]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) CartDeleteGoods(ctx context.Context, customerId, cartId, variantId, version int64) (int64, error) {
+	err := checkCartOwner(ctx, s.rep, customerId, cartId)
+	if err != nil {
+		return 0, fmt.Errorf("[CartDeleteGoods]: %w", err)
+	}
+	next, err := s.rep.CartDeleteGoods(ctx, cartId, variantId, version)
+	if err != nil {
+		return 0, fmt.Errorf("[CartDeleteGoods]: %w", err)
+	}
+	return next, nil
+}
+
+func (s *Store) CartDelete(ctx context.Context, customerId, cartId, version int64) error {
+	err := checkCartOwner(ctx, s.rep, customerId, cartId)
+	if err != nil {
+		return fmt.Errorf("[CartDelete]: %w", err)
+	}
+	err = s.rep.CartDelete(ctx, cartId, version)
+	if err != nil {
+		return fmt.Errorf("[CartDelete]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) OrderCreate(cartId int64) (*models.Order, error) {
-	order, err := s.rep.OrderCreate(cartId)
+func (s *Store) OrderCreate(ctx context.Context, customerId, cartId int64, currency string) (*models.Order, error) {
+	err := checkCartOwner(ctx, s.rep, customerId, cartId)
+	if err != nil {
+		return nil, fmt.Errorf("[OrderCreate]: %w", err)
+	}
+	order, err := s.rep.OrderCreate(ctx, cartId, customerId, currencyOrDefault(currency))
 	if err != nil {
-		return nil, fmt.Errorf("[OrderCreate]: %s", err)
+		return nil, fmt.Errorf("[OrderCreate]: %w", err)
 	}
 	return order, nil
 }
 
-func (s *Store) OrderGet(orderId int64) (*models.Order, error) {
-	order, err := s.rep.OrderGet(orderId)
+func (s *Store) OrderGet(ctx context.Context, customerId, orderId int64) (*models.Order, error) {
+	order, err := ownOrder(ctx, s.rep, customerId, orderId)
 	if err != nil {
-		return nil, fmt.Errorf("[OrderGet]: %s", err)
+		return nil, fmt.Errorf("[OrderGet]: %w", err)
 	}
 	return order, nil
 }
 
-func (s *Store) OrderUpdate(orderId int64, order *dto.OrderUpdate) error {
-	err := s.rep.OrderUpdate(orderId, order)
+func (s *Store) OrderList(ctx context.Context, customerId int64, filter *dto.OrderFilter) (*models.OrderPage, error) {
+	// Заказов другого покупателя для покупателя не существует
+	if filter.CustomerId != 0 && filter.CustomerId != customerId {
+		return nil, fmt.Errorf("[OrderList]: %w", errs.New(
+			errs.NotFound,
+			"orders of customer with id %d not found",
+			filter.CustomerId,
+		))
+	}
+	filter.CustomerId = customerId
+	page, err := orderList(ctx, s.rep, filter)
+	if err != nil {
+		return nil, fmt.Errorf("[OrderList]: %w", err)
+	}
+	return page, nil
+}
+
+func (s *Store) OrderListAll(ctx context.Context, filter *dto.OrderFilter) (*models.OrderPage, error) {
+	page, err := orderList(ctx, s.rep, filter)
+	if err != nil {
+		return nil, fmt.Errorf("[OrderListAll]: %w", err)
+	}
+	return page, nil
+}
+
+// orderList - проверка фильтров и получение страницы заказов
+func orderList(ctx context.Context, repo repository.StoreRepository, filter *dto.OrderFilter) (*models.OrderPage, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, errs.New(errs.Validation, "from must be earlier than to")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = dto.OrderListDefaultLimit
+	}
+	return repo.OrderList(ctx, filter)
+}
+
+func (s *Store) OrderTransition(
+	ctx context.Context,
+	orderId int64,
+	actor string,
+	transition *dto.OrderTransition,
+) (*models.OrderStatusChange, error) {
+	var change *models.OrderStatusChange
+	err := s.rep.WithTx(ctx, func(repo repository.StoreRepository) error {
+		order, err := repo.OrderGet(ctx, orderId)
+		if err != nil {
+			return err
+		}
+		change, err = orderTransition(ctx, repo, order, transition.Status, actor, transition.Reason)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[OrderTransition]: %w", err)
+	}
+	return change, nil
+}
+
+// orderTransition - перевод заказа order в статус to внутри транзакции repo по таблице переходов.
+// При отмене и возврате денег до отправки позиции заказа возвращаются на склад
+func orderTransition(
+	ctx context.Context,
+	repo repository.StoreRepository,
+	order *models.Order,
+	to models.OrderStatus,
+	actor, reason string,
+) (*models.OrderStatusChange, error) {
+	if !order.Status.CanTransitionTo(to) {
+		return nil, errs.New(
+			errs.Conflict,
+			"order with id %d cannot change status from %s to %s",
+			order.OrderId,
+			order.Status,
+			to,
+		)
+	}
+	change := &models.OrderStatusChange{
+		OrderId:   order.OrderId,
+		From:      order.Status,
+		To:        to,
+		Actor:     actor,
+		Reason:    reason,
+		ChangedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	var finishTime *time.Time
+	if change.To.Final() {
+		finishTime = &change.ChangedAt
+	}
+	err := repo.OrderStatusUpdate(ctx, change, finishTime)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status.Restocks(to) {
+		err = repo.OrderRestock(ctx, order.OrderId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+func (s *Store) OrderCancel(ctx context.Context, customerId, orderId int64, actor, reason string) error {
+	err := s.rep.WithTx(ctx, func(repo repository.StoreRepository) error {
+		order, err := ownOrder(ctx, repo, customerId, orderId)
+		if err != nil {
+			return err
+		}
+		// Повторная отмена ничего не меняет, позиции заказа уже вернулись на склад
+		if order.Status == models.OrderCancelled {
+			return nil
+		}
+		_, err = orderTransition(ctx, repo, order, models.OrderCancelled, actor, reason)
+		if !errors.Is(err, errs.ErrConflict) {
+			return err
+		}
+		// Заказ мог отменить параллельный запрос, пока этот читал его статус
+		order, getErr := repo.OrderGet(ctx, orderId)
+		if getErr == nil && order.Status == models.OrderCancelled {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("[OrderCancel]: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) OrderStatusHistory(ctx context.Context, customerId, orderId int64) ([]models.OrderStatusChange, error) {
+	_, err := ownOrder(ctx, s.rep, customerId, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("[OrderStatusHistory]: %w", err)
+	}
+	history, err := s.rep.OrderStatusHistory(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("[OrderStatusHistory]: %w", err)
+	}
+	return history, nil
+}
+
+func (s *Store) OrderStatusHistoryAll(ctx context.Context, orderId int64) ([]models.OrderStatusChange, error) {
+	history, err := s.rep.OrderStatusHistory(ctx, orderId)
 	if err != nil {
-		return fmt.Errorf("[OrderUpdate]: %s", err)
+		return nil, fmt.Errorf("[OrderStatusHistoryAll]: %w", err)
+	}
+	return history, nil
+}
+
+func (s *Store) OrderPurge(ctx context.Context, orderId int64) error {
+	err := s.rep.WithTx(ctx, func(repo repository.StoreRepository) error {
+		order, err := repo.OrderGet(ctx, orderId)
+		if err != nil {
+			return err
+		}
+		// Незавершённый заказ держит товары со склада, удалить можно только завершённый
+		if !order.Status.Final() {
+			return errs.New(
+				errs.Conflict,
+				"order with id %d in status %s cannot be purged, cancel it first",
+				orderId,
+				order.Status,
+			)
+		}
+		return repo.OrderDelete(ctx, orderId)
+	})
+	if err != nil {
+		return fmt.Errorf("[OrderPurge]: %w", err)
 	}
 	return nil
 }
 
-func (s *Store) OrderDelete(orderId int64) error {
-	err := s.rep.OrderDelete(orderId)
+func (s *Store) ExchangeRatesGet(ctx context.Context) ([]models.ExchangeRate, error) {
+	rates, err := s.rep.ExchangeRatesGet(ctx)
 	if err != nil {
-		return fmt.Errorf("[OrderDelete]: %s", err)
+		return nil, fmt.Errorf("[ExchangeRatesGet]: %w", err)
+	}
+	return rates, nil
+}
+
+func (s *Store) ExchangeRateSet(ctx context.Context, rate *models.ExchangeRate) error {
+	if rate.Currency == models.DefaultCurrency() {
+		return fmt.Errorf("[ExchangeRateSet]: %w", errs.New(
+			errs.Validation,
+			"rate of base currency %s is always 1",
+			rate.Currency,
+		))
+	}
+	err := s.rep.ExchangeRateSet(ctx, rate)
+	if err != nil {
+		return fmt.Errorf("[ExchangeRateSet]: %w", err)
 	}
 	return nil
 }
+
+// exchangeRates - текущие курсы валют относительно базовой валюты
+func (s *Store) exchangeRates(ctx context.Context) (*models.ExchangeRates, error) {
+	rates, err := s.rep.ExchangeRatesGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewExchangeRates(models.DefaultCurrency(), rates)
+}
+
+// currencyOrDefault - валюта из запроса или базовая валюта, если она не указана
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return models.DefaultCurrency()
+	}
+	return currency
+}
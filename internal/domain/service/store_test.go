@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"store_api/internal/repository/memory"
+	"testing"
+)
+
+// newTestStore - сервис поверх пустого репозитория в памяти
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(memory.NewStoreRepository())
+}
+
+// addCustomer - новый покупатель с адресом email
+func addCustomer(t *testing.T, store *Store, email string) int64 {
+	t.Helper()
+	customer, err := store.CustomerAdd(context.Background(), &dto.CustomerCreate{Email: email, Name: "Покупатель"})
+	if err != nil {
+		t.Fatalf("CustomerAdd: %v", err)
+	}
+	return customer.CustomerId
+}
+
+// addGoods - новый товар с одним вариантом и остатком quantity
+func addGoods(t *testing.T, store *Store, name string, quantity int64) *models.Goods {
+	t.Helper()
+	goods, err := store.GoodsAdd(context.Background(), &dto.GoodsCreate{
+		Name:     name,
+		Price:    models.NewMoney(5000000, "RUB"),
+		Quantity: quantity,
+	})
+	if err != nil {
+		t.Fatalf("GoodsAdd: %v", err)
+	}
+	return goods
+}
+
+// createCart - корзина покупателя customerId с товарами goodsId и их количеством
+func createCart(t *testing.T, store *Store, customerId int64, lines map[int64]int64) *models.Cart {
+	t.Helper()
+	ctx := context.Background()
+	cart, err := store.CartCreate(ctx, customerId)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	for goodsId, quantity := range lines {
+		cart.Version, err = store.CartAddGoods(ctx, customerId, cart.CartId, cart.Version, &dto.GoodsAdd{
+			GoodsId:  goodsId,
+			Quantity: quantity,
+		})
+		if err != nil {
+			t.Fatalf("CartAddGoods: %v", err)
+		}
+	}
+	return cart
+}
+
+// createOrder - заказ покупателя customerId на товары goodsId и их количество
+func createOrder(t *testing.T, store *Store, customerId int64, lines map[int64]int64) *models.Order {
+	t.Helper()
+	cart := createCart(t, store, customerId, lines)
+	order, err := store.OrderCreate(context.Background(), customerId, cart.CartId, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	return order
+}
+
+// transition - перевод заказа по цепочке статусов от имени админа
+func transition(t *testing.T, store *Store, orderId int64, statuses ...models.OrderStatus) {
+	t.Helper()
+	for _, status := range statuses {
+		_, err := store.OrderTransition(context.Background(), orderId, "admin", &dto.OrderTransition{Status: status})
+		if err != nil {
+			t.Fatalf("OrderTransition to %s: %v", status, err)
+		}
+	}
+}
+
+func expectKind(t *testing.T, op string, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: expected %v error, got %v", op, target.(*errs.Error).Kind, err)
+	}
+}
+
+func TestStoreOrderTransition(t *testing.T) {
+	// paths - разрешённые переходы, которыми заказ доводится до статуса
+	paths := map[models.OrderStatus][]models.OrderStatus{
+		models.OrderCreated:   nil,
+		models.OrderPaid:      {models.OrderPaid},
+		models.OrderShipped:   {models.OrderPaid, models.OrderShipped},
+		models.OrderDelivered: {models.OrderPaid, models.OrderShipped, models.OrderDelivered},
+		models.OrderCancelled: {models.OrderCancelled},
+		models.OrderRefunded:  {models.OrderPaid, models.OrderRefunded},
+	}
+	allowed := map[models.OrderStatus][]models.OrderStatus{
+		models.OrderCreated:   {models.OrderPaid, models.OrderCancelled},
+		models.OrderPaid:      {models.OrderShipped, models.OrderRefunded},
+		models.OrderShipped:   {models.OrderDelivered},
+		models.OrderDelivered: {models.OrderRefunded},
+	}
+	for from, path := range paths {
+		for to := range paths {
+			from, path, to := from, path, to
+			legal := false
+			for _, status := range allowed[from] {
+				legal = legal || status == to
+			}
+			t.Run(string(from)+"->"+string(to), func(t *testing.T) {
+				ctx := context.Background()
+				store := newTestStore(t)
+				customerId := addCustomer(t, store, "buyer@example.com")
+				goods := addGoods(t, store, "Ноутбук", 10)
+				order := createOrder(t, store, customerId, map[int64]int64{goods.GoodsId: 1})
+				transition(t, store, order.OrderId, path...)
+
+				change, err := store.OrderTransition(ctx, order.OrderId, "admin", &dto.OrderTransition{
+					Status: to,
+					Reason: "test",
+				})
+				history, historyErr := store.OrderStatusHistory(ctx, customerId, order.OrderId)
+				if historyErr != nil {
+					t.Fatalf("OrderStatusHistory: %v", historyErr)
+				}
+				got, getErr := store.OrderGet(ctx, customerId, order.OrderId)
+				if getErr != nil {
+					t.Fatalf("OrderGet: %v", getErr)
+				}
+				if !legal {
+					expectKind(t, "OrderTransition", err, errs.ErrConflict)
+					if got.Status != from || len(history) != len(path) {
+						t.Fatalf("OrderTransition: expected order to stay %s with %d changes, got %s with %+v",
+							from, len(path), got.Status, history)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("OrderTransition: %v", err)
+				}
+				if change.From != from || change.To != to || change.Actor != "admin" || change.Reason != "test" {
+					t.Fatalf("OrderTransition: unexpected change %+v", change)
+				}
+				if got.Status != to || (got.FinishTime != nil) != to.Final() {
+					t.Fatalf("OrderTransition: expected order in status %s, got %+v", to, got)
+				}
+				if len(history) != len(path)+1 || history[len(path)].From != from || history[len(path)].To != to {
+					t.Fatalf("OrderTransition: expected %s -> %s at the end of history, got %+v", from, to, history)
+				}
+				want := int64(9)
+				if from.Restocks(to) {
+					want = 10
+				}
+				if stock, _ := goodsStock(t, store, goods.GoodsId); stock != want {
+					t.Fatalf("OrderTransition: expected stock %d after %s -> %s, got %d", want, from, to, stock)
+				}
+			})
+		}
+	}
+
+	store := newTestStore(t)
+	_, err := store.OrderTransition(context.Background(), 1<<30, "admin", &dto.OrderTransition{Status: models.OrderPaid})
+	expectKind(t, "OrderTransition of missing order", err, errs.ErrNotFound)
+}
+
+// goodsStock - остаток товара и его единственного варианта
+func goodsStock(t *testing.T, store *Store, goodsId int64) (int64, int64) {
+	t.Helper()
+	goods, err := store.GoodsGet(context.Background(), goodsId, "")
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	return goods.Quantity, goods.Variants[0].Quantity
+}
+
+func TestStoreOrderCancel(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	customerId := addCustomer(t, store, "buyer@example.com")
+	laptop := addGoods(t, store, "Ноутбук", 10)
+	tablet := addGoods(t, store, "Планшет", 5)
+	mouse := addGoods(t, store, "Мышь", 7)
+	order := createOrder(t, store, customerId, map[int64]int64{laptop.GoodsId: 3, tablet.GoodsId: 1})
+
+	expectStock := func(op string, goodsId, want int64) {
+		t.Helper()
+		goods, variant := goodsStock(t, store, goodsId)
+		if goods != want || variant != want {
+			t.Fatalf("%s: expected goods with id %d and its variant to have %d in stock, got %d and %d",
+				op, goodsId, want, goods, variant)
+		}
+	}
+	expectStock("OrderCreate", laptop.GoodsId, 7)
+	expectStock("OrderCreate", tablet.GoodsId, 4)
+
+	err := store.OrderCancel(ctx, customerId, order.OrderId, "customer", "changed mind")
+	if err != nil {
+		t.Fatalf("OrderCancel: %v", err)
+	}
+	expectStock("OrderCancel", laptop.GoodsId, 10)
+	expectStock("OrderCancel", tablet.GoodsId, 5)
+	expectStock("OrderCancel", mouse.GoodsId, 7)
+
+	// Повторная отмена ничего не меняет и не возвращает товары на склад второй раз
+	err = store.OrderCancel(ctx, customerId, order.OrderId, "customer", "changed mind")
+	if err != nil {
+		t.Fatalf("OrderCancel of cancelled order: %v", err)
+	}
+	expectStock("second OrderCancel", laptop.GoodsId, 10)
+	expectStock("second OrderCancel", tablet.GoodsId, 5)
+	history, err := store.OrderStatusHistory(ctx, customerId, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderStatusHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].To != models.OrderCancelled {
+		t.Fatalf("OrderCancel: expected one cancellation in history, got %+v", history)
+	}
+	_, err = store.OrderTransition(ctx, order.OrderId, "admin", &dto.OrderTransition{Status: models.OrderCancelled})
+	expectKind(t, "OrderTransition of cancelled order", err, errs.ErrConflict)
+	expectStock("OrderTransition of cancelled order", laptop.GoodsId, 10)
+
+	// Оплаченный заказ отменить нельзя, только вернуть деньги
+	paid := createOrder(t, store, customerId, map[int64]int64{laptop.GoodsId: 2})
+	transition(t, store, paid.OrderId, models.OrderPaid)
+	err = store.OrderCancel(ctx, customerId, paid.OrderId, "customer", "")
+	expectKind(t, "OrderCancel of paid order", err, errs.ErrConflict)
+	expectStock("OrderCancel of paid order", laptop.GoodsId, 8)
+
+	// Возврат денег до отправки возвращает товары на склад, после доставки - нет
+	transition(t, store, paid.OrderId, models.OrderRefunded)
+	expectStock("OrderTransition from paid to refunded", laptop.GoodsId, 10)
+	delivered := createOrder(t, store, customerId, map[int64]int64{laptop.GoodsId: 4})
+	transition(t, store, delivered.OrderId, models.OrderPaid, models.OrderShipped, models.OrderDelivered)
+	transition(t, store, delivered.OrderId, models.OrderRefunded)
+	expectStock("OrderTransition from delivered to refunded", laptop.GoodsId, 6)
+}
+
+func TestStoreOrderPurge(t *testing.T) {
+	paths := []struct {
+		status models.OrderStatus
+		path   []models.OrderStatus
+	}{
+		{models.OrderCreated, nil},
+		{models.OrderPaid, []models.OrderStatus{models.OrderPaid}},
+		{models.OrderShipped, []models.OrderStatus{models.OrderPaid, models.OrderShipped}},
+		{models.OrderDelivered, []models.OrderStatus{models.OrderPaid, models.OrderShipped, models.OrderDelivered}},
+		{models.OrderCancelled, []models.OrderStatus{models.OrderCancelled}},
+		{models.OrderRefunded, []models.OrderStatus{models.OrderPaid, models.OrderRefunded}},
+	}
+	for _, tt := range paths {
+		tt := tt
+		t.Run(string(tt.status), func(t *testing.T) {
+			ctx := context.Background()
+			store := newTestStore(t)
+			customerId := addCustomer(t, store, "buyer@example.com")
+			goods := addGoods(t, store, "Ноутбук", 10)
+			order := createOrder(t, store, customerId, map[int64]int64{goods.GoodsId: 3})
+			transition(t, store, order.OrderId, tt.path...)
+			before, _ := goodsStock(t, store, goods.GoodsId)
+
+			err := store.OrderPurge(ctx, order.OrderId)
+			if !tt.status.Final() {
+				// Незавершённый заказ держит товары со склада и остаётся на месте
+				expectKind(t, "OrderPurge", err, errs.ErrConflict)
+				got, getErr := store.OrderGet(ctx, customerId, order.OrderId)
+				if getErr != nil || got.Status != tt.status {
+					t.Fatalf("OrderPurge: expected order to stay %s, got %+v, %v", tt.status, got, getErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("OrderPurge: %v", err)
+				}
+				_, err = store.OrderGet(ctx, customerId, order.OrderId)
+				expectKind(t, "OrderGet after purge", err, errs.ErrNotFound)
+			}
+			if after, _ := goodsStock(t, store, goods.GoodsId); after != before {
+				t.Fatalf("OrderPurge: expected stock to stay %d, got %d", before, after)
+			}
+		})
+	}
+
+	store := newTestStore(t)
+	err := store.OrderPurge(context.Background(), 1<<30)
+	expectKind(t, "OrderPurge of missing order", err, errs.ErrNotFound)
+}
+
+func TestStoreOwnership(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	alice := addCustomer(t, store, "alice@example.com")
+	bob := addCustomer(t, store, "bob@example.com")
+	laptop := addGoods(t, store, "Ноутбук", 10)
+	tablet := addGoods(t, store, "Планшет", 10)
+	cart := createCart(t, store, bob, map[int64]int64{laptop.GoodsId: 1})
+	order := createOrder(t, store, bob, map[int64]int64{laptop.GoodsId: 2})
+	variantId := laptop.Variants[0].VariantId
+
+	// Чужие корзины и заказы для покупателя не существуют, ни одна операция их не меняет
+	_, err := store.CartGetGoods(ctx, alice, cart.CartId, "")
+	expectKind(t, "CartGetGoods of foreign cart", err, errs.ErrNotFound)
+	_, err = store.CartAddGoods(ctx, alice, cart.CartId, cart.Version, &dto.GoodsAdd{GoodsId: tablet.GoodsId, Quantity: 1})
+	expectKind(t, "CartAddGoods to foreign cart", err, errs.ErrNotFound)
+	_, err = store.CartGoodsUpdate(ctx, alice, cart.CartId, variantId, cart.Version, 5)
+	expectKind(t, "CartGoodsUpdate of foreign cart", err, errs.ErrNotFound)
+	_, err = store.CartDeleteGoods(ctx, alice, cart.CartId, variantId, cart.Version)
+	expectKind(t, "CartDeleteGoods of foreign cart", err, errs.ErrNotFound)
+	_, err = store.OrderCreate(ctx, alice, cart.CartId, "RUB")
+	expectKind(t, "OrderCreate from foreign cart", err, errs.ErrNotFound)
+	err = store.CartDelete(ctx, alice, cart.CartId, cart.Version)
+	expectKind(t, "CartDelete of foreign cart", err, errs.ErrNotFound)
+
+	_, err = store.OrderGet(ctx, alice, order.OrderId)
+	expectKind(t, "OrderGet of foreign order", err, errs.ErrNotFound)
+	err = store.OrderCancel(ctx, alice, order.OrderId, "alice", "")
+	expectKind(t, "OrderCancel of foreign order", err, errs.ErrNotFound)
+	_, err = store.OrderStatusHistory(ctx, alice, order.OrderId)
+	expectKind(t, "OrderStatusHistory of foreign order", err, errs.ErrNotFound)
+	_, err = store.OrderList(ctx, alice, &dto.OrderFilter{CustomerId: bob})
+	expectKind(t, "OrderList of foreign customer", err, errs.ErrNotFound)
+
+	own, err := store.CartGetGoods(ctx, bob, cart.CartId, "")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if own.Version != cart.Version || len(own.Goods) != 1 || own.Goods[0].Quantity != 1 {
+		t.Fatalf("CartGetGoods: expected cart to stay unchanged, got %+v", own)
+	}
+	got, err := store.OrderGet(ctx, bob, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	if got.Status != models.OrderCreated {
+		t.Fatalf("OrderGet: expected order to stay %s, got %s", models.OrderCreated, got.Status)
+	}
+	if stock, _ := goodsStock(t, store, laptop.GoodsId); stock != 8 {
+		t.Fatalf("GoodsGet: expected stock 8, got %d", stock)
+	}
+
+	page, err := store.OrderList(ctx, alice, &dto.OrderFilter{})
+	if err != nil {
+		t.Fatalf("OrderList: %v", err)
+	}
+	if len(page.Orders) != 0 {
+		t.Fatalf("OrderList: expected no orders of alice, got %+v", page.Orders)
+	}
+	page, err = store.OrderList(ctx, bob, &dto.OrderFilter{CustomerId: bob})
+	if err != nil {
+		t.Fatalf("OrderList: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].OrderId != order.OrderId {
+		t.Fatalf("OrderList: expected the order of bob, got %+v", page.Orders)
+	}
+
+	// Админ видит заказы любого покупателя
+	createOrder(t, store, alice, map[int64]int64{tablet.GoodsId: 1})
+	page, err = store.OrderListAll(ctx, &dto.OrderFilter{CustomerId: bob})
+	if err != nil {
+		t.Fatalf("OrderListAll: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].OrderId != order.OrderId {
+		t.Fatalf("OrderListAll: expected the order of bob, got %+v", page.Orders)
+	}
+	page, err = store.OrderListAll(ctx, &dto.OrderFilter{})
+	if err != nil {
+		t.Fatalf("OrderListAll: %v", err)
+	}
+	if len(page.Orders) != 2 {
+		t.Fatalf("OrderListAll: expected orders of both customers, got %+v", page.Orders)
+	}
+	transition(t, store, order.OrderId, models.OrderPaid)
+	history, err := store.OrderStatusHistoryAll(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderStatusHistoryAll: %v", err)
+	}
+	if len(history) != 1 || history[0].To != models.OrderPaid {
+		t.Fatalf("OrderStatusHistoryAll: expected the paid transition of bob's order, got %+v", history)
+	}
+	_, err = store.OrderStatusHistoryAll(ctx, order.OrderId+100)
+	expectKind(t, "OrderStatusHistoryAll of missing order", err, errs.ErrNotFound)
+}
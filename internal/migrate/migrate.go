@@ -0,0 +1,259 @@
+// Package migrate - применение SQL миграций к postgres с учётом версии в таблице schema_migrations
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// lockId - ключ advisory блокировки, под которой реплики по очереди применяют миграции
+const lockId int64 = 4_271_300_511
+
+// fileName - формат имени файла миграции: 000001_db_schema.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration - одна версия схемы с SQL для применения и отката
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - состояние схемы БД: текущая версия и список всех миграций
+type Status struct {
+	Version    uint64
+	Dirty      bool
+	Migrations []Migration
+}
+
+// Migrator - применяет миграции из файловой системы к БД.
+// Таблица schema_migrations совместима с golang-migrate: одна строка с версией и признаком dirty
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New - создание Migrator для миграций из каталога dir в fsys
+func New(db *sql.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("[New]: failed to read migrations dir. Error: %v", err)
+	}
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("[New]: bad migration version in %s. Error: %v", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("[New]: failed to read %s. Error: %v", entry.Name(), err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("[New]: migrations %s and %s share version %d", m.Name, match[2], version)
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up - применение всех ещё не применённых миграций
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := m.checkedVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			err = m.apply(ctx, conn, migration.Up, migration.Version)
+			if err != nil {
+				return fmt.Errorf("[Up]: migration %d_%s failed. Error: %v", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down - откат n последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := m.checkedVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			var previous uint64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			err = m.apply(ctx, conn, migration.Down, previous)
+			if err != nil {
+				return fmt.Errorf("[Down]: migration %d_%s failed. Error: %v", migration.Version, migration.Name, err)
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Force - установка версии схемы без выполнения миграций, снимает признак dirty
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("[Force]: %v", err)
+		}
+		err = setVersion(ctx, tx, version, false)
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("[Force]: %v", err)
+		}
+		return tx.Commit()
+	})
+}
+
+// Status - текущая версия схемы и список известных миграций
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	status := &Status{Migrations: m.migrations}
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		status.Version, status.Dirty, err = currentVersion(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// locked - выполняет fn на отдельном соединении под advisory блокировкой,
+// чтобы одновременно стартующие реплики не применяли миграции параллельно
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get db connection. Error: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockId)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock. Error: %v", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockId)
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations. Error: %v", err)
+	}
+	return fn(conn)
+}
+
+// checkedVersion - текущая версия схемы, ошибка если предыдущая миграция не завершилась
+func (m *Migrator) checkedVersion(ctx context.Context, conn *sql.Conn) (uint64, error) {
+	version, dirty, err := currentVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty, fix the schema and run migrate force", version)
+	}
+	return version, nil
+}
+
+// apply - выполнение SQL миграции с записью версии version, как в golang-migrate: до выполнения версия
+// записывается с признаком dirty, а признак снимается в транзакции миграции. Упавшая миграция оставляет
+// схему dirty, это видно в migrate status, и до migrate force другие миграции не применяются
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, query string, version uint64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = setVersion(ctx, tx, version, true)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	tx, err = conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	err = setVersion(ctx, tx, version, false)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (uint64, bool, error) {
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version. Error: %v", err)
+	}
+	return version, dirty, nil
+}
+
+// setVersion - версия 0 без признака dirty означает пустую схему и хранится как отсутствие строки
+func setVersion(ctx context.Context, tx *sql.Tx, version uint64, dirty bool) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	if version == 0 && !dirty {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty)
+	return err
+}
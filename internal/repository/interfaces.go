@@ -1,38 +1,100 @@
 package repository
 
 import (
+	"context"
 	"store_api/internal/domain/models"
 	"store_api/internal/domain/models/dto"
+	"time"
 )
 
 // StoreRepository - интерфейс репозитория БД логики онлайн магазина
 type StoreRepository interface {
-	// GoodsAdd - добавление товара
-	GoodsAdd(goods *models.Goods) error
-	// GoodsGet - получение информации о товаре, возвращает товар
-	GoodsGet(goodsId int64) (*models.Goods, error)
-	// GoodsUpdate - обновление информации о товаре
-	GoodsUpdate(goodsId int64, goods *dto.GoodsUpdate) error
-	// GoodsDelete - удаление товара
-	GoodsDelete(goodsId int64) error
-	// CartCreate - создание корзины
-	CartCreate(cart *models.Cart) error
-	// CartAddGoods - добавление товара в корзину
-	CartAddGoods(goods *dto.GoodsAdd) error
-	// CartGetGoods - получение списка товаров в корзине
-	CartGetGoods() ([]models.Goods, error)
-	// CartGoodsUpdate - обновление информации о товаре в корзине
-	CartGoodsUpdate(cartId, goodsId, quantity int64) error
-	// CartDeleteGoods - удаление товара из корзины
-	CartDeleteGoods(cartId, goodsId int64) error
-	// CartDelete - удаление корзины
-	CartDelete(cartId int64) error
-	// OrderCreate - оформление заказа на основе корзины
-	OrderCreate(cartId int64) (*models.Order, error)
+	// WithTx - выполнение fn в одной транзакции, все вызовы repo внутри fn атомарны.
+	// При ошибке сериализации транзакция повторяется, поэтому fn должна быть идемпотентной
+	WithTx(ctx context.Context, fn func(repo StoreRepository) error) error
+	// GoodsAdd - добавление товара, возвращает созданный товар
+	GoodsAdd(ctx context.Context, goods *dto.GoodsCreate) (*models.Goods, error)
+	// GoodsGet - получение информации о товаре вместе с его вариантами, возвращает товар
+	GoodsGet(ctx context.Context, goodsId int64) (*models.Goods, error)
+	// GoodsList - страница каталога товаров, отобранных и отсортированных по filter
+	GoodsList(ctx context.Context, filter *dto.GoodsFilter) (*models.GoodsPage, error)
+	// GoodsSearch - полнотекстовый поиск товаров по названию в порядке релевантности
+	GoodsSearch(ctx context.Context, search *dto.GoodsSearch) ([]models.GoodsSearchResult, error)
+	// GoodsUpdate - обновление информации о товаре. Цена и остаток товара с одним вариантом
+	// переносятся в этот вариант, остаток товара с несколькими вариантами меняется только через варианты.
+	// version - ожидаемая версия товара, возвращает новую версию
+	GoodsUpdate(ctx context.Context, goodsId, version int64, goods *dto.GoodsUpdate) (int64, error)
+	// GoodsPatch - изменение переданных полей товара версии version, возвращает новую версию
+	GoodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch) (int64, error)
+	// GoodsDelete - удаление товара версии version
+	GoodsDelete(ctx context.Context, goodsId, version int64) error
+	// GoodsImport - добавление и обновление товаров из rows в одной транзакции через промежуточную таблицу.
+	// Строки, которые нельзя применить, пропускаются и возвращаются в Errors, версия обновлённых товаров увеличивается
+	GoodsImport(ctx context.Context, rows dto.GoodsImportReader) (*models.GoodsImportResult, error)
+	// GoodsExport - передача всех товаров в порядке goods_id в fn по одному, без загрузки каталога в память
+	GoodsExport(ctx context.Context, fn func(goods *models.Goods) error) error
+	// VariantAdd - добавление варианта к товару версии version, возвращает созданный вариант и новую версию товара
+	VariantAdd(ctx context.Context, goodsId, version int64, variant *dto.VariantCreate) (*models.Variant, int64, error)
+	// VariantGet - получение информации о варианте товара
+	VariantGet(ctx context.Context, variantId int64) (*models.Variant, error)
+	// VariantUpdate - обновление варианта товара версии version, остаток товара пересчитывается.
+	// Возвращает новую версию товара
+	VariantUpdate(ctx context.Context, variantId, version int64, variant *dto.VariantUpdate) (int64, error)
+	// VariantDelete - удаление варианта товара версии version, последний вариант удалить нельзя.
+	// Возвращает новую версию товара
+	VariantDelete(ctx context.Context, variantId, version int64) (int64, error)
+	// CategoryAdd - добавление категории, возвращает созданную категорию
+	CategoryAdd(ctx context.Context, category *dto.CategoryCreate) (*models.Category, error)
+	// CategoryGet - получение информации о категории
+	CategoryGet(ctx context.Context, categoryId int64) (*models.Category, error)
+	// CategoriesGet - получение всех категорий в порядке category_id
+	CategoriesGet(ctx context.Context) ([]models.Category, error)
+	// CategoryUpdate - обновление категории, категорию нельзя перенести в её же поддерево
+	CategoryUpdate(ctx context.Context, categoryId int64, category *dto.CategoryUpdate) error
+	// CategoryDelete - удаление категории без дочерних категорий
+	CategoryDelete(ctx context.Context, categoryId int64) error
+	// CategoryAddGoods - добавление товара в категорию
+	CategoryAddGoods(ctx context.Context, categoryId, goodsId int64) error
+	// CategoryDeleteGoods - удаление товара из категории
+	CategoryDeleteGoods(ctx context.Context, categoryId, goodsId int64) error
+	// CustomerAdd - регистрация покупателя, возвращает созданного покупателя
+	CustomerAdd(ctx context.Context, customer *dto.CustomerCreate) (*models.Customer, error)
+	// CustomerGet - получение информации о покупателе
+	CustomerGet(ctx context.Context, customerId int64) (*models.Customer, error)
+	// CartCreate - создание пустой корзины покупателя customerId, возвращает созданную корзину.
+	// customerId 0 - корзина без владельца
+	CartCreate(ctx context.Context, customerId int64) (*models.Cart, error)
+	// CartOwner - идентификатор покупателя, владеющего корзиной, 0 у корзины без владельца
+	CartOwner(ctx context.Context, cartId int64) (int64, error)
+	// CartAddGoods - добавление варианта товара в корзину версии version, goods.VariantId должен быть указан.
+	// Возвращает новую версию корзины
+	CartAddGoods(ctx context.Context, cartId, version int64, goods *dto.GoodsAdd) (int64, error)
+	// CartGetGoods - получение корзины с позициями и итоговой стоимостью в валюте currency
+	CartGetGoods(ctx context.Context, cartId int64, currency string) (*models.Cart, error)
+	// CartGoodsUpdate - обновление количества варианта товара в корзине версии version, возвращает новую версию корзины
+	CartGoodsUpdate(ctx context.Context, cartId, variantId, version, quantity int64) (int64, error)
+	// CartDeleteGoods - удаление варианта товара из корзины версии version, возвращает новую версию корзины
+	CartDeleteGoods(ctx context.Context, cartId, variantId, version int64) (int64, error)
+	// CartDelete - удаление корзины версии version
+	CartDelete(ctx context.Context, cartId, version int64) error
+	// OrderCreate - оформление заказа покупателя customerId на основе корзины в валюте currency по текущему курсу.
+	// customerId 0 - заказ без покупателя
+	OrderCreate(ctx context.Context, cartId, customerId int64, currency string) (*models.Order, error)
 	// OrderGet - получение информации о заказе
-	OrderGet(orderId int64) (*models.Order, error)
-	// OrderUpdate - обновление информации о заказе
-	OrderUpdate(orderId int64, order *dto.OrderUpdate) error
-	// OrderDelete - Получение списка товаров в корзине
-	OrderDelete(orderId int64) error
+	OrderGet(ctx context.Context, orderId int64) (*models.Order, error)
+	// OrderList - страница заголовков заказов по фильтрам, от новых к старым
+	OrderList(ctx context.Context, filter *dto.OrderFilter) (*models.OrderPage, error)
+	// OrderStatusUpdate - перевод заказа из статуса change.From в change.To с записью в историю статусов.
+	// Если статус заказа уже не change.From, возвращает Conflict. finishTime, если не nil, становится finish_time заказа
+	OrderStatusUpdate(ctx context.Context, change *models.OrderStatusChange, finishTime *time.Time) error
+	// OrderStatusHistory - история статусов заказа в порядке изменения
+	OrderStatusHistory(ctx context.Context, orderId int64) ([]models.OrderStatusChange, error)
+	// OrderRestock - возврат позиций заказа на склад: остатки их вариантов и товаров увеличиваются
+	OrderRestock(ctx context.Context, orderId int64) error
+	// OrderDelete - удаление заказа вместе с позициями и историей статусов
+	OrderDelete(ctx context.Context, orderId int64) error
+	// ExchangeRatesGet - получение курсов валют относительно базовой валюты
+	ExchangeRatesGet(ctx context.Context) ([]models.ExchangeRate, error)
+	// ExchangeRateSet - установка курса валюты относительно базовой валюты
+	ExchangeRateSet(ctx context.Context, rate *models.ExchangeRate) error
 }
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+func (row categoryRow) toModel() *models.Category {
+	return &models.Category{CategoryId: row.CategoryId, ParentId: row.ParentId, Name: row.Name}
+}
+
+// copyId - копия необязательного идентификатора, чтобы состояние не разделяло указатели с вызывающим кодом
+func copyId(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	value := *id
+	return &value
+}
+
+func (r *StoreRepository) CategoryAdd(ctx context.Context, category *dto.CategoryCreate) (*models.Category, error) {
+	var created *models.Category
+	err := r.run(ctx, func(st *state) error {
+		if category.ParentId != nil {
+			if _, ok := st.categories[*category.ParentId]; !ok {
+				return errs.New(errs.Conflict, "failed to add category: referenced by or references a missing record")
+			}
+		}
+		st.categoriesSeq++
+		row := categoryRow{CategoryId: st.categoriesSeq, ParentId: copyId(category.ParentId), Name: category.Name}
+		st.categories[row.CategoryId] = row
+		created = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *StoreRepository) CategoryGet(ctx context.Context, categoryId int64) (*models.Category, error) {
+	var category *models.Category
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.categories[categoryId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get category with id %d", categoryId)
+		}
+		category = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+func (r *StoreRepository) CategoriesGet(ctx context.Context) ([]models.Category, error) {
+	categories := make([]models.Category, 0)
+	err := r.run(ctx, func(st *state) error {
+		for _, row := range st.categories {
+			categories = append(categories, *row.toModel())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].CategoryId < categories[j].CategoryId })
+	return categories, nil
+}
+
+func (r *StoreRepository) CategoryUpdate(ctx context.Context, categoryId int64, category *dto.CategoryUpdate) error {
+	return r.run(ctx, func(st *state) error {
+		if category.ParentId != nil && st.categorySubtree(categoryId)[*category.ParentId] {
+			return errs.New(
+				errs.Validation,
+				"failed to update category with id %d: category %d is in its subtree",
+				categoryId,
+				*category.ParentId,
+			)
+		}
+		row, ok := st.categories[categoryId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to update category with id %d: not found", categoryId)
+		}
+		if category.ParentId != nil {
+			if _, ok := st.categories[*category.ParentId]; !ok {
+				return errs.New(
+					errs.Conflict,
+					"failed to update category with id %d: referenced by or references a missing record",
+					categoryId,
+				)
+			}
+		}
+		row.ParentId = copyId(category.ParentId)
+		row.Name = category.Name
+		st.categories[categoryId] = row
+		return nil
+	})
+}
+
+func (r *StoreRepository) CategoryDelete(ctx context.Context, categoryId int64) error {
+	return r.run(ctx, func(st *state) error {
+		if _, ok := st.categories[categoryId]; !ok {
+			return errs.New(errs.NotFound, "failed to delete category with id %d: not found", categoryId)
+		}
+		for _, row := range st.categories {
+			if row.ParentId != nil && *row.ParentId == categoryId {
+				return errs.New(
+					errs.Conflict,
+					"failed to delete category with id %d: referenced by or references a missing record",
+					categoryId,
+				)
+			}
+		}
+		delete(st.categories, categoryId)
+		delete(st.goodsCategories, categoryId)
+		return nil
+	})
+}
+
+func (r *StoreRepository) CategoryAddGoods(ctx context.Context, categoryId, goodsId int64) error {
+	return r.run(ctx, func(st *state) error {
+		_, categoryOk := st.categories[categoryId]
+		_, goodsOk := st.goods[goodsId]
+		if !categoryOk || !goodsOk {
+			return errs.New(
+				errs.Conflict,
+				"failed to add goods with id %d to category with id %d: referenced by or references a missing record",
+				goodsId,
+				categoryId,
+			)
+		}
+		if st.goodsCategories[categoryId] == nil {
+			st.goodsCategories[categoryId] = make(map[int64]bool)
+		}
+		st.goodsCategories[categoryId][goodsId] = true
+		return nil
+	})
+}
+
+func (r *StoreRepository) CategoryDeleteGoods(ctx context.Context, categoryId, goodsId int64) error {
+	return r.run(ctx, func(st *state) error {
+		if !st.goodsCategories[categoryId][goodsId] {
+			return errs.New(
+				errs.NotFound,
+				"failed to delete goods with id %d from category with id %d: not found",
+				goodsId,
+				categoryId,
+			)
+		}
+		delete(st.goodsCategories[categoryId], goodsId)
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"time"
+)
+
+func (row customerRow) toModel() *models.Customer {
+	return &models.Customer{CustomerId: row.CustomerId, Email: row.Email, Name: row.Name, CreatedAt: row.CreatedAt}
+}
+
+func (r *StoreRepository) CustomerAdd(ctx context.Context, customer *dto.CustomerCreate) (*models.Customer, error) {
+	var created *models.Customer
+	err := r.run(ctx, func(st *state) error {
+		for _, row := range st.customers {
+			if row.Email == customer.Email {
+				return errs.New(errs.Conflict, "failed to add customer: already exists")
+			}
+		}
+		st.customersSeq++
+		row := customerRow{
+			CustomerId: st.customersSeq,
+			Email:      customer.Email,
+			Name:       customer.Name,
+			CreatedAt:  time.Now(),
+		}
+		st.customers[row.CustomerId] = row
+		created = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *StoreRepository) CustomerGet(ctx context.Context, customerId int64) (*models.Customer, error) {
+	var customer *models.Customer
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.customers[customerId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get customer with id %d", customerId)
+		}
+		customer = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+func (r *StoreRepository) CartOwner(ctx context.Context, cartId int64) (int64, error) {
+	var customerId int64
+	err := r.run(ctx, func(st *state) error {
+		cart, ok := st.carts[cartId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get owner of cart with id %d", cartId)
+		}
+		customerId = cart.CustomerId
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return customerId, nil
+}
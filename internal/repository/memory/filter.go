@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"html"
+	"regexp"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"strings"
+	"time"
+)
+
+// goodsFilter - условия отбора товаров, аналог WHERE в postgres
+type goodsFilter dto.GoodsFilter
+
+// matches - подходит ли товар под все условия фильтра
+func (f *goodsFilter) matches(row goodsRow) bool {
+	if f.Name != "" && !strings.Contains(strings.ToLower(row.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if f.PriceMin != nil && (row.Price.Currency != f.PriceMin.Currency || row.Price.Amount < f.PriceMin.Amount) {
+		return false
+	}
+	if f.PriceMax != nil && (row.Price.Currency != f.PriceMax.Currency || row.Price.Amount > f.PriceMax.Amount) {
+		return false
+	}
+	if f.InStock && row.Quantity <= 0 {
+		return false
+	}
+	return true
+}
+
+// goodsLess - порядок товаров по ключу сортировки и goods_id, как ORDER BY в postgres
+func goodsLess(field dto.GoodsSort) (func(a, b *models.Goods) bool, error) {
+	switch field {
+	case dto.GoodsSortId:
+		return func(a, b *models.Goods) bool { return a.GoodsId < b.GoodsId }, nil
+	case dto.GoodsSortPrice:
+		return func(a, b *models.Goods) bool {
+			if a.Price.Amount != b.Price.Amount {
+				return a.Price.Amount < b.Price.Amount
+			}
+			return a.GoodsId < b.GoodsId
+		}, nil
+	case dto.GoodsSortName:
+		// Побайтовое сравнение строк совпадает с COLLATE "C"
+		return func(a, b *models.Goods) bool {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.GoodsId < b.GoodsId
+		}, nil
+	}
+	return nil, errs.New(errs.Validation, "unknown sort field %q", field)
+}
+
+// orderFilter - условия отбора заказов, аналог WHERE в postgres
+type orderFilter dto.OrderFilter
+
+// matches - подходит ли заказ под все условия фильтра, включая позицию курсора
+func (f *orderFilter) matches(row orderRow) bool {
+	if f.CustomerId != 0 && (row.CustomerId == nil || *row.CustomerId != f.CustomerId) {
+		return false
+	}
+	if f.From != nil && row.OrderTime.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !row.OrderTime.Before(*f.To) {
+		return false
+	}
+	if f.Status != "" && row.Status != f.Status {
+		return false
+	}
+	if f.After != nil && !orderNewer(f.After.OrderTime, f.After.OrderId, row.OrderTime, row.OrderId) {
+		return false
+	}
+	return true
+}
+
+// orderNewer - порядок заказов от новых к старым по order_time и order_id, как ORDER BY в postgres:
+// true, если заказ (aTime, aId) идёт раньше заказа (bTime, bId)
+func orderNewer(aTime time.Time, aId int64, bTime time.Time, bId int64) bool {
+	if !aTime.Equal(bTime) {
+		return aTime.After(bTime)
+	}
+	return aId > bId
+}
+
+// searchWord - слово названия товара, аналог токенов парсера полнотекстового поиска postgres
+var searchWord = regexp.MustCompile(`[\pL\pN]+`)
+
+// searchGoods - поиск товара по префиксам слов: все слова запроса должны совпасть с началом слов названия.
+// Релевантность - доля слов названия, совпавших с запросом, совпавшие слова выделяются в snippet,
+// остальной текст названия экранируется как HTML
+func searchGoods(row goodsRow, terms []string) (models.GoodsSearchResult, bool) {
+	name := row.Name
+	words := searchWord.FindAllStringIndex(name, -1)
+	matchedTerms := make(map[string]bool, len(terms))
+	var snippet strings.Builder
+	matched, last := 0, 0
+	for _, bounds := range words {
+		word := strings.ToLower(name[bounds[0]:bounds[1]])
+		hit := false
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) {
+				matchedTerms[term] = true
+				hit = true
+			}
+		}
+		if !hit {
+			continue
+		}
+		matched++
+		snippet.WriteString(html.EscapeString(name[last:bounds[0]]))
+		snippet.WriteString("<b>" + html.EscapeString(name[bounds[0]:bounds[1]]) + "</b>")
+		last = bounds[1]
+	}
+	if len(matchedTerms) != len(uniqueStrings(terms)) {
+		return models.GoodsSearchResult{}, false
+	}
+	snippet.WriteString(html.EscapeString(name[last:]))
+	return models.GoodsSearchResult{
+		Goods:   *row.toModel(),
+		Rank:    float64(matched) / float64(len(words)),
+		Snippet: snippet.String(),
+	}, true
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+func (r *StoreRepository) GoodsImport(ctx context.Context, rows dto.GoodsImportReader) (*models.GoodsImportResult, error) {
+	// Строки читаются до захвата блокировки, аналог COPY в промежуточную таблицу в postgres
+	staged := make([]dto.GoodsImportRow, 0)
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read goods import rows")
+		}
+		staged = append(staged, *row)
+	}
+
+	result := &models.GoodsImportResult{Errors: make([]models.GoodsImportError, 0)}
+	err := r.run(ctx, func(st *state) error {
+		seen := make(map[int64]int64)
+		for _, row := range staged {
+			if row.GoodsId == 0 {
+				continue
+			}
+			msg := st.goodsImportReject(row, seen)
+			if msg != "" {
+				result.Errors = append(result.Errors, models.GoodsImportError{Line: row.Line, Error: msg})
+			}
+			if _, ok := seen[row.GoodsId]; !ok {
+				seen[row.GoodsId] = row.Line
+			}
+		}
+		rejected := make(map[int64]bool, len(result.Errors))
+		for _, e := range result.Errors {
+			rejected[e.Line] = true
+		}
+
+		for _, row := range staged {
+			if rejected[row.Line] || row.GoodsId == 0 {
+				continue
+			}
+			variants := st.goodsVariants(row.GoodsId)
+			if len(variants) == 1 {
+				variant := st.variants[variants[0]]
+				variant.Price = row.Price
+				variant.Quantity = row.Quantity
+				st.variants[variant.VariantId] = variant
+			}
+			st.goods[row.GoodsId] = goodsRow{
+				GoodsId:  row.GoodsId,
+				Name:     row.Name,
+				Price:    row.Price,
+				Quantity: row.Quantity,
+				Version:  st.goods[row.GoodsId].Version + 1,
+			}
+			result.Updated++
+		}
+		for _, row := range staged {
+			if row.GoodsId != 0 {
+				continue
+			}
+			st.goodsSeq++
+			goods := goodsRow{GoodsId: st.goodsSeq, Name: row.Name, Price: row.Price, Version: 1}
+			st.goods[goods.GoodsId] = goods
+			st.addVariant(variantRow{
+				GoodsId:  goods.GoodsId,
+				Sku:      models.DefaultSku(goods.GoodsId),
+				Price:    row.Price,
+				Quantity: row.Quantity,
+			})
+			result.Inserted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// goodsImportReject - причина отклонения строки, обновляющей товар, пустая строка, если её можно применить.
+// seen - номера строк, в которых уже встречались goods_id
+func (s *state) goodsImportReject(row dto.GoodsImportRow, seen map[int64]int64) string {
+	if line, ok := seen[row.GoodsId]; ok {
+		return fmt.Sprintf("goods with id %d is already imported at line %d", row.GoodsId, line)
+	}
+	goods, ok := s.goods[row.GoodsId]
+	if !ok {
+		return fmt.Sprintf("goods with id %d not found", row.GoodsId)
+	}
+	variants := s.goodsVariants(row.GoodsId)
+	if len(variants) > 1 && goods.Quantity != row.Quantity {
+		return fmt.Sprintf(
+			"goods with id %d has %d variants, update stock of its variants instead",
+			row.GoodsId,
+			len(variants),
+		)
+	}
+	return ""
+}
+
+func (r *StoreRepository) GoodsExport(ctx context.Context, fn func(goods *models.Goods) error) error {
+	// Снимок каталога делается под блокировкой, а fn вызывается уже без неё
+	var goods []models.Goods
+	err := r.run(ctx, func(st *state) error {
+		goods = make([]models.Goods, 0, len(st.goods))
+		for _, row := range st.goods {
+			goods = append(goods, *row.toModel())
+		}
+		sort.Slice(goods, func(i, j int) bool { return goods[i].GoodsId < goods[j].GoodsId })
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range goods {
+		err = fn(&goods[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"time"
+)
+
+func (r *StoreRepository) OrderStatusUpdate(ctx context.Context, change *models.OrderStatusChange, finishTime *time.Time) error {
+	return r.run(ctx, func(st *state) error {
+		msg := fmt.Sprintf("failed to change status of order with id %d", change.OrderId)
+		row, ok := st.orders[change.OrderId]
+		if !ok {
+			return errs.New(errs.NotFound, "%s: not found", msg)
+		}
+		if row.Status != change.From {
+			return errs.New(errs.Conflict, "%s: status is already %s, not %s", msg, row.Status, change.From)
+		}
+		row.Status = change.To
+		if finishTime != nil {
+			finish := *finishTime
+			row.FinishTime = &finish
+		}
+		row.History = append(row.History, *change)
+		st.orders[change.OrderId] = row
+		return nil
+	})
+}
+
+func (r *StoreRepository) OrderStatusHistory(ctx context.Context, orderId int64) ([]models.OrderStatusChange, error) {
+	var history []models.OrderStatusChange
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.orders[orderId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get status history of order with id %d: not found", orderId)
+		}
+		history = append(make([]models.OrderStatusChange, 0, len(row.History)), row.History...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *StoreRepository) OrderRestock(ctx context.Context, orderId int64) error {
+	return r.run(ctx, func(st *state) error {
+		row, ok := st.orders[orderId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to restock goods of order with id %d: not found", orderId)
+		}
+		for _, line := range row.Lines {
+			variant := st.variants[line.VariantId]
+			variant.Quantity += line.Quantity
+			st.variants[line.VariantId] = variant
+			goods := st.goods[line.GoodsId]
+			goods.Quantity += line.Quantity
+			goods.Version++
+			st.goods[line.GoodsId] = goods
+		}
+		return nil
+	})
+}
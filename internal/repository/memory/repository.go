@@ -0,0 +1,669 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"store_api/internal/repository"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// storage - общее для репозитория и его транзакций хранилище, mu сериализует доступ к state
+type storage struct {
+	mu    sync.Mutex
+	state *state
+}
+
+// StoreRepository - реализация repository.StoreRepository в памяти процесса.
+// Транзакции выполняются под общей блокировкой на копии состояния, поэтому они сериализуемы
+// и не требуют повторов
+type StoreRepository struct {
+	storage *storage
+	// tx - состояние текущей транзакции, nil вне WithTx
+	tx *state
+}
+
+// NewStoreRepository - создание пустого репозитория в памяти
+func NewStoreRepository() *StoreRepository {
+	return &StoreRepository{storage: &storage{state: newState()}}
+}
+
+// run - выполняет fn над состоянием: внутри транзакции над её копией, иначе под блокировкой хранилища.
+// fn должна проверять все условия до изменения состояния, чтобы ошибка не оставляла его частично изменённым
+func (r *StoreRepository) run(ctx context.Context, fn func(st *state) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+	return fn(r.storage.state)
+}
+
+// WithTx - внутри fn можно обращаться только к переданному repo, вызовы исходного репозитория заблокируются
+func (r *StoreRepository) WithTx(ctx context.Context, fn func(repo repository.StoreRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	r.storage.mu.Lock()
+	defer r.storage.mu.Unlock()
+	txState := r.storage.state.clone()
+	err = fn(&StoreRepository{storage: r.storage, tx: txState})
+	if err != nil {
+		return err
+	}
+	r.storage.state = txState
+	return nil
+}
+
+func (row goodsRow) toModel() *models.Goods {
+	return &models.Goods{
+		GoodsId:  row.GoodsId,
+		Name:     row.Name,
+		Price:    row.Price,
+		Quantity: row.Quantity,
+		Version:  row.Version,
+	}
+}
+
+// goodsNameMax - длина колонки goods.name varchar(40) в символах
+const goodsNameMax = 40
+
+// checkGoodsName - аналог ошибки postgres 22001 для слишком длинного названия товара
+func checkGoodsName(name, msg string) error {
+	if utf8.RuneCountInString(name) > goodsNameMax {
+		return errs.New(errs.Validation, "%s: value too long", msg)
+	}
+	return nil
+}
+
+func (r *StoreRepository) GoodsAdd(ctx context.Context, goods *dto.GoodsCreate) (*models.Goods, error) {
+	var created *models.Goods
+	err := r.run(ctx, func(st *state) error {
+		err := checkGoodsName(goods.Name, "failed to add goods")
+		if err != nil {
+			return err
+		}
+		st.goodsSeq++
+		row := goodsRow{GoodsId: st.goodsSeq, Name: goods.Name, Price: goods.Price, Version: 1}
+		st.goods[row.GoodsId] = row
+		// Товар продаётся через варианты, поэтому вместе с ним создаётся вариант по умолчанию
+		st.addVariant(variantRow{
+			GoodsId:  row.GoodsId,
+			Sku:      models.DefaultSku(row.GoodsId),
+			Price:    goods.Price,
+			Quantity: goods.Quantity,
+		})
+		created = st.goodsWithVariants(row.GoodsId)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *StoreRepository) GoodsGet(ctx context.Context, goodsId int64) (*models.Goods, error) {
+	var goods *models.Goods
+	err := r.run(ctx, func(st *state) error {
+		if _, ok := st.goods[goodsId]; !ok {
+			return errs.New(errs.NotFound, "failed to get goods with id %d", goodsId)
+		}
+		goods = st.goodsWithVariants(goodsId)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return goods, nil
+}
+
+func (r *StoreRepository) GoodsList(ctx context.Context, filter *dto.GoodsFilter) (*models.GoodsPage, error) {
+	var goods []models.Goods
+	err := r.run(ctx, func(st *state) error {
+		goods = make([]models.Goods, 0, filter.Limit+1)
+		var inCategory map[int64]bool
+		if filter.CategoryId != 0 {
+			inCategory = st.categoryGoods(filter.CategoryId)
+		}
+		for _, row := range st.goods {
+			if inCategory != nil && !inCategory[row.GoodsId] {
+				continue
+			}
+			if (*goodsFilter)(filter).matches(row) {
+				goods = append(goods, *row.toModel())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	less, err := goodsLess(filter.Sort)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(goods, func(i, j int) bool {
+		if filter.Desc {
+			return less(&goods[j], &goods[i])
+		}
+		return less(&goods[i], &goods[j])
+	})
+	start := 0
+	if filter.After != nil {
+		// Аналог сравнения кортежей в postgres: первый товар строго после курсора
+		after := &models.Goods{
+			GoodsId: filter.After.GoodsId,
+			Name:    filter.After.Name,
+			Price:   models.Money{Amount: filter.After.Price},
+		}
+		start = sort.Search(len(goods), func(i int) bool {
+			if filter.Desc {
+				return less(&goods[i], after)
+			}
+			return less(after, &goods[i])
+		})
+	}
+	goods = goods[start:]
+	if len(goods) > filter.Limit+1 {
+		goods = goods[:filter.Limit+1]
+	}
+	return dto.NewGoodsPage(filter, goods), nil
+}
+
+func (r *StoreRepository) GoodsSearch(ctx context.Context, search *dto.GoodsSearch) ([]models.GoodsSearchResult, error) {
+	found := make([]models.GoodsSearchResult, 0)
+	err := r.run(ctx, func(st *state) error {
+		for _, row := range st.goods {
+			result, ok := searchGoods(row, search.Terms)
+			if ok {
+				found = append(found, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Rank != found[j].Rank {
+			return found[i].Rank > found[j].Rank
+		}
+		return found[i].GoodsId < found[j].GoodsId
+	})
+	if len(found) > search.Limit {
+		found = found[:search.Limit]
+	}
+	return found, nil
+}
+
+func (r *StoreRepository) GoodsUpdate(ctx context.Context, goodsId, version int64, goods *dto.GoodsUpdate) (int64, error) {
+	patch := &dto.GoodsPatch{Name: &goods.Name, Price: &goods.Price, Quantity: &goods.Quantity}
+	return r.goodsPatch(ctx, goodsId, version, patch, fmt.Sprintf("failed to update goods with id %d", goodsId))
+}
+
+func (r *StoreRepository) GoodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch) (int64, error) {
+	return r.goodsPatch(ctx, goodsId, version, patch, fmt.Sprintf("failed to patch goods with id %d", goodsId))
+}
+
+// goodsPatch - изменение переданных в patch полей товара версии version, msg описывает операцию
+func (r *StoreRepository) goodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch, msg string) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.goods[goodsId]
+		err := checkVersion(ok, row.Version, version, msg)
+		if err != nil {
+			return err
+		}
+		if patch.Name != nil {
+			err = checkGoodsName(*patch.Name, msg)
+			if err != nil {
+				return err
+			}
+		}
+		variants := st.goodsVariants(goodsId)
+		if len(variants) != 1 && patch.Quantity != nil && row.Quantity != *patch.Quantity {
+			return errs.New(
+				errs.Validation,
+				"goods with id %d has %d variants, update stock of its variants instead",
+				goodsId,
+				len(variants),
+			)
+		}
+		if len(variants) == 1 {
+			// Цена и остаток товара с одним вариантом переносятся в этот вариант
+			variant := st.variants[variants[0]]
+			if patch.Price != nil {
+				variant.Price = *patch.Price
+			}
+			if patch.Quantity != nil {
+				variant.Quantity = *patch.Quantity
+				row.Quantity = *patch.Quantity
+			}
+			st.variants[variant.VariantId] = variant
+		}
+		if patch.Name != nil {
+			row.Name = *patch.Name
+		}
+		if patch.Price != nil {
+			row.Price = *patch.Price
+		}
+		row.Version++
+		st.goods[goodsId] = row
+		next = row.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) GoodsDelete(ctx context.Context, goodsId, version int64) error {
+	return r.run(ctx, func(st *state) error {
+		row, ok := st.goods[goodsId]
+		err := checkVersion(ok, row.Version, version, fmt.Sprintf("failed to delete goods with id %d", goodsId))
+		if err != nil {
+			return err
+		}
+		if st.goodsReferenced(goodsId) {
+			return errs.New(
+				errs.Conflict,
+				"failed to delete goods with id %d: referenced by or references a missing record",
+				goodsId,
+			)
+		}
+		delete(st.goods, goodsId)
+		for _, variantId := range st.goodsVariants(goodsId) {
+			delete(st.variants, variantId)
+		}
+		for _, goods := range st.goodsCategories {
+			delete(goods, goodsId)
+		}
+		return nil
+	})
+}
+
+func (r *StoreRepository) CartCreate(ctx context.Context, customerId int64) (*models.Cart, error) {
+	cart := &models.Cart{Goods: make([]models.CartItem, 0), Version: 1}
+	err := r.run(ctx, func(st *state) error {
+		if _, ok := st.customers[customerId]; customerId != 0 && !ok {
+			return errs.New(errs.Conflict, "failed to create cart: referenced by or references a missing record")
+		}
+		st.cartsSeq++
+		st.carts[st.cartsSeq] = cartRow{CustomerId: customerId, Version: cart.Version, Lines: make(map[int64]int64)}
+		cart.CartId = st.cartsSeq
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (r *StoreRepository) CartAddGoods(ctx context.Context, cartId, version int64, goods *dto.GoodsAdd) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		cart, ok := st.carts[cartId]
+		err := checkVersion(ok, cart.Version, version, fmt.Sprintf(
+			"failed to add goods with id %d to cart with id %d",
+			goods.GoodsId,
+			cartId,
+		))
+		if err != nil {
+			return err
+		}
+		_, goodsOk := st.goods[goods.GoodsId]
+		_, variantOk := st.variants[goods.VariantId]
+		if !goodsOk || !variantOk {
+			return errs.New(
+				errs.Conflict,
+				"failed to add goods with id %d to cart with id %d: referenced by or references a missing record",
+				goods.GoodsId,
+				cartId,
+			)
+		}
+		cart.Lines[goods.VariantId] += goods.Quantity
+		cart.Version++
+		st.carts[cartId] = cart
+		next = cart.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) CartGetGoods(ctx context.Context, cartId int64, currency string) (*models.Cart, error) {
+	var cart *models.Cart
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.carts[cartId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get cart with id %d", cartId)
+		}
+		lines := row.Lines
+		cart = &models.Cart{CartId: cartId, Version: row.Version, Goods: make([]models.CartItem, 0, len(lines))}
+		for _, variantId := range sortedKeys(lines) {
+			variant := st.variants[variantId]
+			cart.Goods = append(cart.Goods, models.CartItem{
+				GoodsId:    variant.GoodsId,
+				VariantId:  variantId,
+				Sku:        variant.Sku,
+				Name:       st.goods[variant.GoodsId].Name,
+				Attributes: copyAttributes(variant.Attributes),
+				Price:      variant.Price,
+				Quantity:   lines[variantId],
+			})
+		}
+		rates, err := models.NewExchangeRates(models.DefaultCurrency(), st.exchangeRates())
+		if err != nil {
+			return errs.Wrap(errs.Internal, err, "failed to load exchange rates")
+		}
+		err = cart.Convert(rates, currency)
+		if err != nil {
+			return errs.New(errs.Validation, "failed to get cart with id %d in %s: %v", cartId, currency, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (r *StoreRepository) CartGoodsUpdate(ctx context.Context, cartId, variantId, version, quantity int64) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		msg := fmt.Sprintf("failed to update variant with id %d in cart with id %d", variantId, cartId)
+		cart, ok := st.carts[cartId]
+		err := checkVersion(ok, cart.Version, version, msg)
+		if err != nil {
+			return err
+		}
+		if _, ok := cart.Lines[variantId]; !ok {
+			return errs.New(errs.NotFound, "%s: not found", msg)
+		}
+		cart.Lines[variantId] = quantity
+		cart.Version++
+		st.carts[cartId] = cart
+		next = cart.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) CartDeleteGoods(ctx context.Context, cartId, variantId, version int64) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		msg := fmt.Sprintf("failed to delete variant with id %d from cart with id %d", variantId, cartId)
+		cart, ok := st.carts[cartId]
+		err := checkVersion(ok, cart.Version, version, msg)
+		if err != nil {
+			return err
+		}
+		if _, ok := cart.Lines[variantId]; !ok {
+			return errs.New(errs.NotFound, "%s: not found", msg)
+		}
+		delete(cart.Lines, variantId)
+		cart.Version++
+		st.carts[cartId] = cart
+		next = cart.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) CartDelete(ctx context.Context, cartId, version int64) error {
+	return r.run(ctx, func(st *state) error {
+		cart, ok := st.carts[cartId]
+		err := checkVersion(ok, cart.Version, version, fmt.Sprintf("failed to delete cart with id %d", cartId))
+		if err != nil {
+			return err
+		}
+		delete(st.carts, cartId)
+		return nil
+	})
+}
+
+func (r *StoreRepository) OrderCreate(ctx context.Context, cartId, customerId int64, currency string) (*models.Order, error) {
+	var order *models.Order
+	err := r.run(ctx, func(st *state) error {
+		cart, ok := st.carts[cartId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get cart with id %d", cartId)
+		}
+		lines := cart.Lines
+		if len(lines) == 0 {
+			return errs.New(errs.Validation, "cart with id %d is empty", cartId)
+		}
+		if _, ok := st.customers[customerId]; customerId != 0 && !ok {
+			return errs.New(errs.Conflict, "failed to create order: referenced by or references a missing record")
+		}
+
+		order = &models.Order{Goods: make([]models.OrderItem, 0, len(lines))}
+		for _, variantId := range sortedKeys(lines) {
+			variant := st.variants[variantId]
+			if variant.Quantity < lines[variantId] {
+				return errs.New(
+					errs.InsufficientStock,
+					"variant %s of goods with id %d is out of stock: requested %d, available %d",
+					variant.Sku,
+					variant.GoodsId,
+					lines[variantId],
+					variant.Quantity,
+				)
+			}
+			order.Goods = append(order.Goods, models.OrderItem{
+				GoodsId:   variant.GoodsId,
+				VariantId: variantId,
+				Sku:       variant.Sku,
+				Name:      st.goods[variant.GoodsId].Name,
+				Price:     variant.Price,
+				Quantity:  lines[variantId],
+			})
+		}
+		rates, err := models.NewExchangeRates(models.DefaultCurrency(), st.exchangeRates())
+		if err != nil {
+			return errs.Wrap(errs.Internal, err, "failed to load exchange rates")
+		}
+		err = order.Convert(rates, currency)
+		if err != nil {
+			return errs.New(errs.Validation, "failed to create order from cart with id %d in %s: %v", cartId, currency, err)
+		}
+
+		row := orderRow{
+			Total:        order.Total,
+			ExchangeRate: order.ExchangeRate,
+			Lines:        make([]orderLine, 0, len(order.Goods)),
+		}
+		for _, item := range order.Goods {
+			variant := st.variants[item.VariantId]
+			variant.Quantity -= item.Quantity
+			st.variants[item.VariantId] = variant
+			goods := st.goods[item.GoodsId]
+			goods.Quantity -= item.Quantity
+			goods.Version++
+			st.goods[item.GoodsId] = goods
+			row.Lines = append(row.Lines, orderLine{
+				GoodsId:   item.GoodsId,
+				VariantId: item.VariantId,
+				Quantity:  item.Quantity,
+				Price:     item.Price,
+			})
+		}
+		st.ordersSeq++
+		row.OrderId = st.ordersSeq
+		row.Status = models.OrderCreated
+		if customerId != 0 {
+			row.CustomerId = copyId(&customerId)
+		}
+		row.OrderTime = time.Now()
+		st.orders[row.OrderId] = row
+		cart.Lines = make(map[int64]int64)
+		cart.Version++
+		st.carts[cartId] = cart
+
+		order = row.toModel(st)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (row orderRow) toModel(st *state) *models.Order {
+	order := &models.Order{
+		OrderId:      row.OrderId,
+		CustomerId:   copyId(row.CustomerId),
+		Goods:        make([]models.OrderItem, 0, len(row.Lines)),
+		Total:        row.Total,
+		ExchangeRate: row.ExchangeRate,
+		Status:       row.Status,
+		OrderTime:    row.OrderTime,
+		FinishTime:   row.FinishTime,
+	}
+	for _, line := range row.Lines {
+		variant := st.variants[line.VariantId]
+		order.Goods = append(order.Goods, models.OrderItem{
+			GoodsId:    line.GoodsId,
+			VariantId:  line.VariantId,
+			Sku:        variant.Sku,
+			Name:       st.goods[line.GoodsId].Name,
+			Attributes: copyAttributes(variant.Attributes),
+			Price:      line.Price,
+			Quantity:   line.Quantity,
+		})
+	}
+	return order
+}
+
+func (r *StoreRepository) OrderGet(ctx context.Context, orderId int64) (*models.Order, error) {
+	var order *models.Order
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.orders[orderId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get order with id %d", orderId)
+		}
+		order = row.toModel(st)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *StoreRepository) OrderList(ctx context.Context, filter *dto.OrderFilter) (*models.OrderPage, error) {
+	var orders []models.OrderSummary
+	err := r.run(ctx, func(st *state) error {
+		orders = make([]models.OrderSummary, 0, filter.Limit+1)
+		for _, row := range st.orders {
+			if (*orderFilter)(filter).matches(row) {
+				orders = append(orders, row.toSummary())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orderNewer(orders[i].OrderTime, orders[i].OrderId, orders[j].OrderTime, orders[j].OrderId)
+	})
+	if len(orders) > filter.Limit+1 {
+		orders = orders[:filter.Limit+1]
+	}
+	return dto.NewOrderPage(filter, orders), nil
+}
+
+// toSummary - заголовок заказа для списка заказов
+func (row orderRow) toSummary() models.OrderSummary {
+	summary := models.OrderSummary{
+		OrderId:    row.OrderId,
+		CustomerId: copyId(row.CustomerId),
+		Total:      row.Total,
+		Status:     row.Status,
+		ItemCount:  int64(len(row.Lines)),
+		OrderTime:  row.OrderTime,
+		FinishTime: row.FinishTime,
+	}
+	for _, line := range row.Lines {
+		summary.Quantity += line.Quantity
+	}
+	return summary
+}
+
+func (r *StoreRepository) OrderDelete(ctx context.Context, orderId int64) error {
+	return r.run(ctx, func(st *state) error {
+		if _, ok := st.orders[orderId]; !ok {
+			return errs.New(errs.NotFound, "failed to delete order with id %d: not found", orderId)
+		}
+		delete(st.orders, orderId)
+		return nil
+	})
+}
+
+func (r *StoreRepository) ExchangeRatesGet(ctx context.Context) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := r.run(ctx, func(st *state) error {
+		rates = st.exchangeRates()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *StoreRepository) ExchangeRateSet(ctx context.Context, rate *models.ExchangeRate) error {
+	value, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok || value.Sign() <= 0 {
+		return errs.New(
+			errs.Validation,
+			"failed to set exchange rate for %s: constraint chk_exchange_rates__rate violated",
+			rate.Currency,
+		)
+	}
+	return r.run(ctx, func(st *state) error {
+		// Курс хранится с той же точностью, что и numeric(20, 10) в postgres
+		st.rates[rate.Currency] = models.ExchangeRate{
+			Currency:  rate.Currency,
+			Rate:      value.FloatString(10),
+			UpdatedAt: time.Now(),
+		}
+		return nil
+	})
+}
+
+// sortedKeys - идентификаторы вариантов в порядке возрастания, как ORDER BY variant_id в postgres
+func sortedKeys(lines map[int64]int64) []int64 {
+	keys := make([]int64, 0, len(lines))
+	for key := range lines {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
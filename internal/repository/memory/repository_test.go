@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"store_api/internal/repository"
+	"store_api/internal/repository/repotest"
+	"testing"
+)
+
+func TestStoreRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.StoreRepository {
+		return NewStoreRepository()
+	})
+}
@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"sort"
+	"store_api/internal/domain/models"
+	"time"
+)
+
+// goodsRow - строка таблицы goods
+type goodsRow struct {
+	GoodsId  int64
+	Name     string
+	Price    models.Money
+	Quantity int64
+	Version  int64
+}
+
+// cartRow - строка таблицы carts вместе с её позициями, CustomerId 0 у корзины без владельца
+type cartRow struct {
+	CustomerId int64
+	Version    int64
+	Lines      map[int64]int64 // variant_id -> quantity
+}
+
+// customerRow - строка таблицы customers
+type customerRow struct {
+	CustomerId int64
+	Email      string
+	Name       string
+	CreatedAt  time.Time
+}
+
+// variantRow - строка таблицы variants
+type variantRow struct {
+	VariantId  int64
+	GoodsId    int64
+	Sku        string
+	Attributes models.Attributes
+	Price      models.Money
+	Quantity   int64
+}
+
+// categoryRow - строка таблицы categories
+type categoryRow struct {
+	CategoryId int64
+	ParentId   *int64
+	Name       string
+}
+
+// orderLine - строка таблицы goods_to_orders, цена в валюте заказа
+type orderLine struct {
+	GoodsId   int64
+	VariantId int64
+	Quantity  int64
+	Price     models.Money
+}
+
+// orderRow - строка таблицы orders вместе с её позициями и историей статусов
+type orderRow struct {
+	OrderId      int64
+	CustomerId   *int64
+	Total        models.Money
+	ExchangeRate string
+	Status       models.OrderStatus
+	OrderTime    time.Time
+	FinishTime   *time.Time
+	Lines        []orderLine
+	History      []models.OrderStatusChange
+}
+
+// state - содержимое хранилища: таблицы и счётчики идентификаторов
+type state struct {
+	goods     map[int64]goodsRow
+	variants  map[int64]variantRow
+	carts     map[int64]cartRow
+	customers map[int64]customerRow
+	orders    map[int64]orderRow
+	rates     map[string]models.ExchangeRate
+
+	categories      map[int64]categoryRow
+	goodsCategories map[int64]map[int64]bool // category_id -> goods_id
+
+	goodsSeq      int64
+	variantsSeq   int64
+	cartsSeq      int64
+	customersSeq  int64
+	ordersSeq     int64
+	categoriesSeq int64
+}
+
+func newState() *state {
+	return &state{
+		goods:     make(map[int64]goodsRow),
+		variants:  make(map[int64]variantRow),
+		carts:     make(map[int64]cartRow),
+		customers: make(map[int64]customerRow),
+		orders:    make(map[int64]orderRow),
+		rates:     make(map[string]models.ExchangeRate),
+
+		categories:      make(map[int64]categoryRow),
+		goodsCategories: make(map[int64]map[int64]bool),
+	}
+}
+
+// clone - глубокая копия состояния, на которой выполняется транзакция
+func (s *state) clone() *state {
+	c := &state{
+		goods:           make(map[int64]goodsRow, len(s.goods)),
+		carts:           make(map[int64]cartRow, len(s.carts)),
+		customers:       make(map[int64]customerRow, len(s.customers)),
+		orders:          make(map[int64]orderRow, len(s.orders)),
+		rates:           make(map[string]models.ExchangeRate, len(s.rates)),
+		categories:      make(map[int64]categoryRow, len(s.categories)),
+		goodsCategories: make(map[int64]map[int64]bool, len(s.goodsCategories)),
+		variants:        make(map[int64]variantRow, len(s.variants)),
+		goodsSeq:        s.goodsSeq,
+		variantsSeq:     s.variantsSeq,
+		cartsSeq:        s.cartsSeq,
+		customersSeq:    s.customersSeq,
+		ordersSeq:       s.ordersSeq,
+		categoriesSeq:   s.categoriesSeq,
+	}
+	for id, row := range s.goods {
+		c.goods[id] = row
+	}
+	for id, row := range s.variants {
+		c.variants[id] = row
+	}
+	for id, row := range s.carts {
+		lines := make(map[int64]int64, len(row.Lines))
+		for variantId, quantity := range row.Lines {
+			lines[variantId] = quantity
+		}
+		row.Lines = lines
+		c.carts[id] = row
+	}
+	for id, row := range s.customers {
+		c.customers[id] = row
+	}
+	for id, row := range s.orders {
+		row.Lines = append([]orderLine(nil), row.Lines...)
+		row.History = append([]models.OrderStatusChange(nil), row.History...)
+		c.orders[id] = row
+	}
+	for currency, rate := range s.rates {
+		c.rates[currency] = rate
+	}
+	for id, row := range s.categories {
+		c.categories[id] = row
+	}
+	for id, goods := range s.goodsCategories {
+		goodsCopy := make(map[int64]bool, len(goods))
+		for goodsId := range goods {
+			goodsCopy[goodsId] = true
+		}
+		c.goodsCategories[id] = goodsCopy
+	}
+	return c
+}
+
+// goodsReferenced - есть ли ссылки на товар из корзин или заказов, аналог внешних ключей в postgres
+func (s *state) goodsReferenced(goodsId int64) bool {
+	for _, variantId := range s.goodsVariants(goodsId) {
+		if s.variantReferenced(variantId) {
+			return true
+		}
+	}
+	return false
+}
+
+// variantReferenced - есть ли ссылки на вариант из корзин или заказов
+func (s *state) variantReferenced(variantId int64) bool {
+	for _, cart := range s.carts {
+		if _, ok := cart.Lines[variantId]; ok {
+			return true
+		}
+	}
+	for _, order := range s.orders {
+		for _, line := range order.Lines {
+			if line.VariantId == variantId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// goodsVariants - идентификаторы вариантов товара в порядке возрастания
+func (s *state) goodsVariants(goodsId int64) []int64 {
+	ids := make([]int64, 0)
+	for id, row := range s.variants {
+		if row.GoodsId == goodsId {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// addVariant - добавление варианта товара с пересчётом остатка товара
+func (s *state) addVariant(row variantRow) variantRow {
+	s.variantsSeq++
+	row.VariantId = s.variantsSeq
+	if row.Attributes == nil {
+		row.Attributes = models.Attributes{}
+	}
+	s.variants[row.VariantId] = row
+	goods := s.goods[row.GoodsId]
+	goods.Quantity += row.Quantity
+	s.goods[row.GoodsId] = goods
+	return row
+}
+
+// categorySubtree - категория categoryId и все её потомки, аналог рекурсивного CTE в postgres
+func (s *state) categorySubtree(categoryId int64) map[int64]bool {
+	subtree := make(map[int64]bool)
+	if _, ok := s.categories[categoryId]; !ok {
+		return subtree
+	}
+	subtree[categoryId] = true
+	for grown := true; grown; {
+		grown = false
+		for id, row := range s.categories {
+			if row.ParentId != nil && subtree[*row.ParentId] && !subtree[id] {
+				subtree[id] = true
+				grown = true
+			}
+		}
+	}
+	return subtree
+}
+
+// categoryGoods - товары категории categoryId и всех её потомков
+func (s *state) categoryGoods(categoryId int64) map[int64]bool {
+	goods := make(map[int64]bool)
+	for id := range s.categorySubtree(categoryId) {
+		for goodsId := range s.goodsCategories[id] {
+			goods[goodsId] = true
+		}
+	}
+	return goods
+}
+
+// exchangeRates - курсы валют в порядке возрастания кода, как ORDER BY currency в postgres
+func (s *state) exchangeRates() []models.ExchangeRate {
+	rates := make([]models.ExchangeRate, 0, len(s.rates))
+	for _, rate := range s.rates {
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Currency < rates[j].Currency })
+	return rates
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+func (row variantRow) toModel() *models.Variant {
+	return &models.Variant{
+		VariantId:  row.VariantId,
+		GoodsId:    row.GoodsId,
+		Sku:        row.Sku,
+		Attributes: copyAttributes(row.Attributes),
+		Price:      row.Price,
+		Quantity:   row.Quantity,
+	}
+}
+
+// copyAttributes - копия характеристик, чтобы вызывающий код не изменял состояние хранилища
+func copyAttributes(attributes models.Attributes) models.Attributes {
+	c := make(models.Attributes, len(attributes))
+	for key, value := range attributes {
+		c[key] = value
+	}
+	return c
+}
+
+// goodsWithVariants - товар вместе с его вариантами, как GoodsGet в postgres
+func (s *state) goodsWithVariants(goodsId int64) *models.Goods {
+	goods := s.goods[goodsId].toModel()
+	variantIds := s.goodsVariants(goodsId)
+	goods.Variants = make([]models.Variant, 0, len(variantIds))
+	for _, variantId := range variantIds {
+		goods.Variants = append(goods.Variants, *s.variants[variantId].toModel())
+	}
+	return goods
+}
+
+// skuTaken - занят ли артикул другим вариантом, аналог uq_variants__sku в postgres
+func (s *state) skuTaken(sku string, variantId int64) bool {
+	for id, row := range s.variants {
+		if row.Sku == sku && id != variantId {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *StoreRepository) VariantAdd(ctx context.Context, goodsId, version int64, variant *dto.VariantCreate) (*models.Variant, int64, error) {
+	var (
+		created *models.Variant
+		next    int64
+	)
+	err := r.run(ctx, func(st *state) error {
+		goods, ok := st.goods[goodsId]
+		err := checkVersion(ok, goods.Version, version, fmt.Sprintf("failed to add variant to goods with id %d", goodsId))
+		if err != nil {
+			return err
+		}
+		if st.skuTaken(variant.Sku, 0) {
+			return errs.New(errs.Conflict, "failed to add variant to goods with id %d: already exists", goodsId)
+		}
+		row := st.addVariant(variantRow{
+			GoodsId:    goodsId,
+			Sku:        variant.Sku,
+			Attributes: copyAttributes(variant.Attributes),
+			Price:      variant.Price,
+			Quantity:   variant.Quantity,
+		})
+		next = st.bumpGoodsVersion(goodsId)
+		created = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return created, next, nil
+}
+
+func (r *StoreRepository) VariantGet(ctx context.Context, variantId int64) (*models.Variant, error) {
+	var variant *models.Variant
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.variants[variantId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to get variant with id %d", variantId)
+		}
+		variant = row.toModel()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (r *StoreRepository) VariantUpdate(ctx context.Context, variantId, version int64, variant *dto.VariantUpdate) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.variants[variantId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to update variant with id %d", variantId)
+		}
+		goods, ok := st.goods[row.GoodsId]
+		err := checkVersion(ok, goods.Version, version, fmt.Sprintf("failed to update variant with id %d", variantId))
+		if err != nil {
+			return err
+		}
+		if st.skuTaken(variant.Sku, variantId) {
+			return errs.New(errs.Conflict, "failed to update variant with id %d: already exists", variantId)
+		}
+		goods.Quantity += variant.Quantity - row.Quantity
+		goods.Version++
+		st.goods[row.GoodsId] = goods
+		next = goods.Version
+
+		row.Sku = variant.Sku
+		row.Attributes = copyAttributes(variant.Attributes)
+		row.Price = variant.Price
+		row.Quantity = variant.Quantity
+		st.variants[variantId] = row
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) VariantDelete(ctx context.Context, variantId, version int64) (int64, error) {
+	var next int64
+	err := r.run(ctx, func(st *state) error {
+		row, ok := st.variants[variantId]
+		if !ok {
+			return errs.New(errs.NotFound, "failed to delete variant with id %d", variantId)
+		}
+		goods, ok := st.goods[row.GoodsId]
+		err := checkVersion(ok, goods.Version, version, fmt.Sprintf("failed to delete variant with id %d", variantId))
+		if err != nil {
+			return err
+		}
+		if len(st.goodsVariants(row.GoodsId)) == 1 {
+			return errs.New(
+				errs.Validation,
+				"failed to delete variant with id %d: it is the last variant of goods with id %d",
+				variantId,
+				row.GoodsId,
+			)
+		}
+		if st.variantReferenced(variantId) {
+			return errs.New(
+				errs.Conflict,
+				"failed to delete variant with id %d: referenced by or references a missing record",
+				variantId,
+			)
+		}
+		delete(st.variants, variantId)
+		goods.Quantity -= row.Quantity
+		goods.Version++
+		st.goods[row.GoodsId] = goods
+		next = goods.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
@@ -0,0 +1,23 @@
+package memory
+
+import "store_api/internal/domain/errs"
+
+// checkVersion - аналог условия version = $n в postgres: NotFound, если строки нет,
+// и VersionConflict, если её версия current отличается от version. msg описывает операцию
+func checkVersion(ok bool, current, version int64, msg string) error {
+	if !ok {
+		return errs.New(errs.NotFound, "%s: not found", msg)
+	}
+	if current != version {
+		return errs.New(errs.VersionConflict, "%s: version %d does not match current version %d", msg, version, current)
+	}
+	return nil
+}
+
+// bumpGoodsVersion - увеличение версии товара после изменения его данных или вариантов, возвращает новую версию
+func (s *state) bumpGoodsVersion(goodsId int64) int64 {
+	goods := s.goods[goodsId]
+	goods.Version++
+	s.goods[goodsId] = goods
+	return goods.Version
+}
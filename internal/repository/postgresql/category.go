@@ -0,0 +1,114 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+// categorySubtree - CTE с идентификаторами категории из параметра subtreeParam и всех её потомков
+func categorySubtree(subtreeParam string) string {
+	return `WITH RECURSIVE subtree AS (
+			SELECT category_id FROM categories WHERE category_id = ` + subtreeParam + `
+			UNION ALL
+			SELECT c.category_id FROM categories c JOIN subtree s ON c.parent_id = s.category_id
+		) `
+}
+
+func (r *StoreRepository) CategoryAdd(ctx context.Context, category *dto.CategoryCreate) (*models.Category, error) {
+	created := &models.Category{}
+	err := r.ex.GetContext(ctx, created, `
+		INSERT INTO categories (parent_id, name) VALUES ($1, $2)
+		RETURNING category_id, parent_id, name
+	`, category.ParentId, category.Name)
+	if err != nil {
+		return nil, classifyErr(err, "failed to add category")
+	}
+	return created, nil
+}
+
+func (r *StoreRepository) CategoryGet(ctx context.Context, categoryId int64) (*models.Category, error) {
+	category := &models.Category{}
+	err := r.ex.GetContext(ctx, category, `SELECT category_id, parent_id, name FROM categories WHERE category_id = $1`, categoryId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get category with id %d", categoryId))
+	}
+	return category, nil
+}
+
+func (r *StoreRepository) CategoriesGet(ctx context.Context) ([]models.Category, error) {
+	categories := make([]models.Category, 0)
+	err := r.ex.SelectContext(ctx, &categories, `SELECT category_id, parent_id, name FROM categories ORDER BY category_id`)
+	if err != nil {
+		return nil, classifyErr(err, "failed to get categories")
+	}
+	return categories, nil
+}
+
+func (r *StoreRepository) CategoryUpdate(ctx context.Context, categoryId int64, category *dto.CategoryUpdate) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		if category.ParentId != nil {
+			// Перенос категории в собственное поддерево образовал бы цикл
+			var cycle bool
+			err := txRepo.ex.GetContext(ctx, &cycle,
+				categorySubtree("$1")+`SELECT EXISTS (SELECT 1 FROM subtree WHERE category_id = $2)`,
+				categoryId,
+				*category.ParentId,
+			)
+			if err != nil {
+				return classifyErr(err, fmt.Sprintf("failed to update category with id %d", categoryId))
+			}
+			if cycle {
+				return errs.New(
+					errs.Validation,
+					"failed to update category with id %d: category %d is in its subtree",
+					categoryId,
+					*category.ParentId,
+				)
+			}
+		}
+		res, err := txRepo.ex.ExecContext(ctx,
+			`UPDATE categories SET parent_id = $1, name = $2 WHERE category_id = $3`,
+			category.ParentId,
+			category.Name,
+			categoryId,
+		)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to update category with id %d", categoryId))
+		}
+		return checkAffected(res, fmt.Sprintf("failed to update category with id %d", categoryId))
+	})
+}
+
+func (r *StoreRepository) CategoryDelete(ctx context.Context, categoryId int64) error {
+	res, err := r.ex.ExecContext(ctx, `DELETE FROM categories WHERE category_id = $1`, categoryId)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to delete category with id %d", categoryId))
+	}
+	return checkAffected(res, fmt.Sprintf("failed to delete category with id %d", categoryId))
+}
+
+func (r *StoreRepository) CategoryAddGoods(ctx context.Context, categoryId, goodsId int64) error {
+	_, err := r.ex.ExecContext(ctx, `
+		INSERT INTO goods_to_categories (category_id, goods_id) VALUES ($1, $2)
+		ON CONFLICT (category_id, goods_id) DO NOTHING
+	`, categoryId, goodsId)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to add goods with id %d to category with id %d", goodsId, categoryId))
+	}
+	return nil
+}
+
+func (r *StoreRepository) CategoryDeleteGoods(ctx context.Context, categoryId, goodsId int64) error {
+	res, err := r.ex.ExecContext(ctx,
+		`DELETE FROM goods_to_categories WHERE category_id = $1 AND goods_id = $2`,
+		categoryId,
+		goodsId,
+	)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to delete goods with id %d from category with id %d", goodsId, categoryId))
+	}
+	return checkAffected(res, fmt.Sprintf("failed to delete goods with id %d from category with id %d", goodsId, categoryId))
+}
@@ -4,29 +4,17 @@ import (
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/spf13/viper"
-	"sync"
 )
 
-var instance *sqlx.DB
-var isConnExists bool
-var mutex sync.Mutex
-
-func getDB() (*sqlx.DB, error) {
-	if isConnExists {
-		return instance, nil
-	}
-	mutex.Lock()
-	db, err := sqlx.Connect("postgres", viper.GetString("db.connection"))
+// Connect - подключение к БД postgres по строке подключения dsn
+func Connect(dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("[GetDB]: failed to connect to db. Error: %s", err)
+		return nil, fmt.Errorf("[Connect]: failed to connect to db. Error: %s", err)
 	}
 	err = db.Ping()
 	if err != nil {
-		return nil, fmt.Errorf("[GetDB]: failed to ping db. Error: %s", err)
+		return nil, fmt.Errorf("[Connect]: failed to ping db. Error: %s", err)
 	}
-	isConnExists = true
-	instance = db
-	mutex.Unlock()
-	return instance, nil
+	return db, nil
 }
@@ -0,0 +1,43 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+// customerColumns - колонки таблицы customers в порядке полей models.Customer
+const customerColumns = `customer_id, email, name, created_at`
+
+func (r *StoreRepository) CustomerAdd(ctx context.Context, customer *dto.CustomerCreate) (*models.Customer, error) {
+	created := &models.Customer{}
+	err := r.ex.GetContext(ctx, created, `
+		INSERT INTO customers (email, name) VALUES ($1, $2)
+		RETURNING `+customerColumns,
+		customer.Email,
+		customer.Name,
+	)
+	if err != nil {
+		return nil, classifyErr(err, "failed to add customer")
+	}
+	return created, nil
+}
+
+func (r *StoreRepository) CustomerGet(ctx context.Context, customerId int64) (*models.Customer, error) {
+	customer := &models.Customer{}
+	err := r.ex.GetContext(ctx, customer, `SELECT `+customerColumns+` FROM customers WHERE customer_id = $1`, customerId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get customer with id %d", customerId))
+	}
+	return customer, nil
+}
+
+func (r *StoreRepository) CartOwner(ctx context.Context, cartId int64) (int64, error) {
+	var customerId int64
+	err := r.ex.GetContext(ctx, &customerId, `SELECT coalesce(customer_id, 0) FROM carts WHERE cart_id = $1`, cartId)
+	if err != nil {
+		return 0, classifyErr(err, fmt.Sprintf("failed to get owner of cart with id %d", cartId))
+	}
+	return customerId, nil
+}
@@ -0,0 +1,49 @@
+package postgresql
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"store_api/internal/domain/errs"
+)
+
+// SQLSTATE нарушений ограничений, которые являются ошибками клиента, а не БД
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+	stringTooLong       = "22001"
+)
+
+// classifyErr - приводит ошибку драйвера к доменной ошибке, msg описывает неудавшуюся операцию
+func classifyErr(err error, msg string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.Wrap(errs.NotFound, err, msg)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			return errs.Wrap(errs.Conflict, err, "%s: already exists", msg)
+		case foreignKeyViolation:
+			return errs.Wrap(errs.Conflict, err, "%s: referenced by or references a missing record", msg)
+		case checkViolation:
+			return errs.Wrap(errs.Validation, err, "%s: constraint %s violated", msg, pqErr.Constraint)
+		case stringTooLong:
+			return errs.Wrap(errs.Validation, err, "%s: value too long", msg)
+		}
+	}
+	return errors.Wrap(err, msg)
+}
+
+// checkAffected - возвращает NotFound, если запрос не затронул ни одной строки
+func checkAffected(res sql.Result, msg string) error {
+	count, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, msg)
+	}
+	if count == 0 {
+		return errs.New(errs.NotFound, "%s: not found", msg)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package postgresql
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"strconv"
+)
+
+// goodsImportColumns - колонки промежуточной таблицы импорта в порядке COPY
+var goodsImportColumns = []string{"line", "goods_id", "name", "price", "currency", "quantity"}
+
+func (r *StoreRepository) GoodsImport(ctx context.Context, rows dto.GoodsImportReader) (*models.GoodsImportResult, error) {
+	// Поток строк можно прочитать только один раз, поэтому строки сохраняются во временный файл до начала
+	// транзакции, чтобы inTx мог повторить её после ошибки сериализации, не держа весь файл в памяти
+	spool, err := newGoodsImportSpool(rows)
+	if err != nil {
+		return nil, err
+	}
+	defer spool.Close()
+
+	var result *models.GoodsImportResult
+	err = r.inTx(ctx, func(txRepo *StoreRepository) error {
+		result = &models.GoodsImportResult{Errors: make([]models.GoodsImportError, 0)}
+		return txRepo.goodsImport(ctx, spool, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// goodsImportSpool - строки импорта, сохранённые во временный CSV файл в порядке goodsImportColumns
+type goodsImportSpool struct {
+	file *os.File
+}
+
+func newGoodsImportSpool(rows dto.GoodsImportReader) (*goodsImportSpool, error) {
+	file, err := os.CreateTemp("", "goods-import-*.csv")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create goods import file")
+	}
+	spool := &goodsImportSpool{file: file}
+
+	writer := csv.NewWriter(file)
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = spool.Close()
+			return nil, errors.Wrap(err, "failed to read goods import rows")
+		}
+		goodsId := ""
+		if row.GoodsId != 0 {
+			goodsId = strconv.FormatInt(row.GoodsId, 10)
+		}
+		err = writer.Write([]string{
+			strconv.FormatInt(row.Line, 10),
+			goodsId,
+			row.Name,
+			strconv.FormatInt(row.Price.Amount, 10),
+			row.Price.Currency,
+			strconv.FormatInt(row.Quantity, 10),
+		})
+		if err != nil {
+			_ = spool.Close()
+			return nil, errors.Wrap(err, "failed to write goods import file")
+		}
+	}
+	writer.Flush()
+	err = writer.Error()
+	if err != nil {
+		_ = spool.Close()
+		return nil, errors.Wrap(err, "failed to write goods import file")
+	}
+	return spool, nil
+}
+
+// each - чтение сохранённых строк с начала файла, fn получает значения колонок goodsImportColumns
+func (s *goodsImportSpool) each(fn func(record []string) error) error {
+	_, err := s.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to read goods import file")
+	}
+	reader := csv.NewReader(bufio.NewReader(s.file))
+	reader.ReuseRecord = true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read goods import file")
+		}
+		err = fn(record)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close - удаление временного файла
+func (s *goodsImportSpool) Close() error {
+	err := s.file.Close()
+	removeErr := os.Remove(s.file.Name())
+	if err != nil {
+		return err
+	}
+	return removeErr
+}
+
+// goodsImport - импорт товаров, вызывается только внутри транзакции. Обновлённые товары получают новую версию
+func (r *StoreRepository) goodsImport(ctx context.Context, rows *goodsImportSpool, result *models.GoodsImportResult) error {
+	_, err := r.ex.ExecContext(ctx, `
+		CREATE TEMPORARY TABLE goods_import (
+			line     bigint NOT NULL,
+			goods_id integer,
+			name     text   NOT NULL,
+			price    bigint NOT NULL,
+			currency text   NOT NULL,
+			quantity bigint NOT NULL
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to create goods import table")
+	}
+	err = r.goodsImportCopy(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	// Блокируем обновляемые товары и их варианты, как GoodsUpdate
+	_, err = r.ex.ExecContext(ctx, `
+		SELECT 1 FROM goods g JOIN variants v ON v.goods_id = g.goods_id
+		WHERE g.goods_id IN (SELECT goods_id FROM goods_import)
+		ORDER BY g.goods_id, v.variant_id
+		FOR UPDATE
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to lock imported goods")
+	}
+
+	err = r.goodsImportReject(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.ex.ExecContext(ctx, `
+		UPDATE variants v SET price = i.price, currency = i.currency, quantity = i.quantity
+		FROM goods_import i
+		WHERE v.goods_id = i.goods_id AND (SELECT count(*) FROM variants w WHERE w.goods_id = v.goods_id) = 1
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to update variants of imported goods")
+	}
+	res, err := r.ex.ExecContext(ctx, `
+		UPDATE goods g SET name = i.name, price = i.price, currency = i.currency, quantity = i.quantity,
+			version = g.version + 1
+		FROM goods_import i
+		WHERE g.goods_id = i.goods_id
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to update imported goods")
+	}
+	result.Updated, err = res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to update imported goods")
+	}
+
+	// Каждый добавленный товар получает вариант по умолчанию, как при GoodsAdd
+	res, err = r.ex.ExecContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO goods (name, price, currency, quantity)
+			SELECT name, price, currency, quantity FROM goods_import WHERE goods_id IS NULL ORDER BY line
+			RETURNING goods_id, price, currency, quantity
+		)
+		INSERT INTO variants (goods_id, sku, price, currency, quantity)
+		SELECT goods_id, 'GOODS-' || goods_id, price, currency, quantity FROM inserted
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to insert imported goods")
+	}
+	result.Inserted, err = res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to insert imported goods")
+	}
+	return nil
+}
+
+// goodsImportCopy - загрузка строк импорта в промежуточную таблицу через COPY
+func (r *StoreRepository) goodsImportCopy(ctx context.Context, rows *goodsImportSpool) error {
+	stmt, err := r.tx.PrepareContext(ctx, pq.CopyIn("goods_import", goodsImportColumns...))
+	if err != nil {
+		return classifyErr(err, "failed to start goods import")
+	}
+	defer stmt.Close()
+
+	err = rows.each(func(record []string) error {
+		args := make([]interface{}, len(record))
+		for i, value := range record {
+			args[i] = value
+		}
+		// Пустой goods_id - новый товар
+		if record[1] == "" {
+			args[1] = nil
+		}
+		_, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to copy goods import line %s", record[0]))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		return classifyErr(err, "failed to copy goods import rows")
+	}
+	return nil
+}
+
+// goodsImportReject - удаление из промежуточной таблицы строк, обновляющих отсутствующие товары,
+// повторяющих goods_id более ранней строки или меняющих остаток товара с несколькими вариантами
+func (r *StoreRepository) goodsImportReject(ctx context.Context, result *models.GoodsImportResult) error {
+	rejected := make([]struct {
+		Line        int64  `db:"line"`
+		GoodsId     int64  `db:"goods_id"`
+		Found       bool   `db:"found"`
+		DuplicateOf *int64 `db:"duplicate_of"`
+		Variants    int64  `db:"variants"`
+	}, 0)
+	err := r.ex.SelectContext(ctx, &rejected, `
+		SELECT * FROM (
+			SELECT i.line, i.goods_id, g.goods_id IS NOT NULL AS found,
+				(SELECT min(d.line) FROM goods_import d WHERE d.goods_id = i.goods_id AND d.line < i.line) AS duplicate_of,
+				coalesce(v.variants, 0) AS variants,
+				coalesce(v.quantity, 0) <> i.quantity AS stock_changed
+			FROM goods_import i
+				LEFT JOIN goods g ON g.goods_id = i.goods_id
+				LEFT JOIN (
+					SELECT goods_id, count(*) AS variants, sum(quantity) AS quantity FROM variants GROUP BY goods_id
+				) v ON v.goods_id = i.goods_id
+			WHERE i.goods_id IS NOT NULL
+		) c
+		WHERE NOT c.found OR c.duplicate_of IS NOT NULL OR (c.variants > 1 AND c.stock_changed)
+		ORDER BY c.line
+	`)
+	if err != nil {
+		return classifyErr(err, "failed to check imported goods")
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	lines := make([]int64, 0, len(rejected))
+	for _, row := range rejected {
+		lines = append(lines, row.Line)
+		msg := ""
+		switch {
+		case row.DuplicateOf != nil:
+			msg = fmt.Sprintf("goods with id %d is already imported at line %d", row.GoodsId, *row.DuplicateOf)
+		case !row.Found:
+			msg = fmt.Sprintf("goods with id %d not found", row.GoodsId)
+		default:
+			msg = fmt.Sprintf(
+				"goods with id %d has %d variants, update stock of its variants instead",
+				row.GoodsId,
+				row.Variants,
+			)
+		}
+		result.Errors = append(result.Errors, models.GoodsImportError{Line: row.Line, Error: msg})
+	}
+	_, err = r.ex.ExecContext(ctx, `DELETE FROM goods_import WHERE line = ANY($1)`, pq.Array(lines))
+	if err != nil {
+		return classifyErr(err, "failed to reject imported goods")
+	}
+	return nil
+}
+
+func (r *StoreRepository) GoodsExport(ctx context.Context, fn func(goods *models.Goods) error) error {
+	rows, err := r.ex.QueryxContext(ctx, `SELECT `+goodsColumns+` FROM goods ORDER BY goods_id`)
+	if err != nil {
+		return classifyErr(err, "failed to export goods")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		goods := &models.Goods{}
+		err = rows.StructScan(goods)
+		if err != nil {
+			return classifyErr(err, "failed to export goods")
+		}
+		err = fn(goods)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return classifyErr(err, "failed to export goods")
+	}
+	return nil
+}
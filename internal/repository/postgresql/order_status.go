@@ -0,0 +1,123 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pkg/errors"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"time"
+)
+
+func (r *StoreRepository) OrderStatusUpdate(ctx context.Context, change *models.OrderStatusChange, finishTime *time.Time) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to change status of order with id %d", change.OrderId)
+		// Условие на текущий статус не даёт двум одновременным переходам из одного статуса примениться оба
+		res, err := txRepo.ex.ExecContext(ctx, `
+			UPDATE orders SET status = $1, finish_time = coalesce($2, finish_time)
+			WHERE order_id = $3 AND status = $4
+		`, change.To, finishTime, change.OrderId, change.From)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		if count == 0 {
+			var status models.OrderStatus
+			err = txRepo.ex.GetContext(ctx, &status, `SELECT status FROM orders WHERE order_id = $1`, change.OrderId)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errs.New(errs.NotFound, "%s: not found", msg)
+			}
+			if err != nil {
+				return classifyErr(err, msg)
+			}
+			return errs.New(errs.Conflict, "%s: status is already %s, not %s", msg, status, change.From)
+		}
+
+		_, err = txRepo.ex.ExecContext(ctx, `
+			INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, change.OrderId, change.From, change.To, change.Actor, change.Reason, change.ChangedAt)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to record status history of order with id %d", change.OrderId))
+		}
+		return nil
+	})
+}
+
+func (r *StoreRepository) OrderStatusHistory(ctx context.Context, orderId int64) ([]models.OrderStatusChange, error) {
+	var exists bool
+	err := r.ex.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`, orderId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get status history of order with id %d", orderId))
+	}
+	if !exists {
+		return nil, errs.New(errs.NotFound, "failed to get status history of order with id %d: not found", orderId)
+	}
+
+	history := make([]models.OrderStatusChange, 0)
+	err = r.ex.SelectContext(ctx, &history, `
+		SELECT order_id, from_status, to_status, actor, reason, changed_at
+		FROM order_status_history WHERE order_id = $1
+		ORDER BY changed_at, history_id
+	`, orderId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get status history of order with id %d", orderId))
+	}
+	return history, nil
+}
+
+func (r *StoreRepository) OrderRestock(ctx context.Context, orderId int64) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to restock goods of order with id %d", orderId)
+		var found int64
+		err := txRepo.ex.GetContext(ctx, &found, `SELECT order_id FROM orders WHERE order_id = $1`, orderId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errs.New(errs.NotFound, "%s: not found", msg)
+		}
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		// Блокируем товары, затем варианты заказа в том же порядке, что и OrderCreate
+		_, err = txRepo.ex.ExecContext(ctx, `
+			SELECT 1 FROM goods
+			WHERE goods_id IN (SELECT goods_id FROM goods_to_orders WHERE order_id = $1)
+			ORDER BY goods_id
+			FOR UPDATE
+		`, orderId)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `
+			SELECT 1 FROM variants v JOIN goods_to_orders gto ON gto.variant_id = v.variant_id
+			WHERE gto.order_id = $1
+			ORDER BY v.variant_id
+			FOR UPDATE OF v
+		`, orderId)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `
+			UPDATE variants v SET quantity = v.quantity + gto.quantity
+			FROM goods_to_orders gto
+			WHERE gto.order_id = $1 AND v.variant_id = gto.variant_id
+		`, orderId)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `
+			UPDATE goods g SET quantity = g.quantity + s.quantity, version = g.version + 1
+			FROM (
+				SELECT goods_id, sum(quantity) AS quantity FROM goods_to_orders WHERE order_id = $1 GROUP BY goods_id
+			) s
+			WHERE g.goods_id = s.goods_id
+		`, orderId)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package postgresql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// queryBuilder - сборка условий и присваиваний запроса из фиксированных фрагментов SQL,
+// все значения от клиента передаются только через нумерованные параметры
+type queryBuilder struct {
+	sets  []string
+	conds []string
+	args  []interface{}
+}
+
+// arg - добавляет значение в параметры запроса и возвращает его плейсхолдер
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// where - добавляет условие, объединяемое с остальными через AND
+func (b *queryBuilder) where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+// set - добавляет присваивание в SET запроса UPDATE
+func (b *queryBuilder) set(assignment string) {
+	b.sets = append(b.sets, assignment)
+}
+
+// setClause - SET со всеми присваиваниями
+func (b *queryBuilder) setClause() string {
+	return " SET " + strings.Join(b.sets, ", ")
+}
+
+// whereClause - WHERE со всеми условиями или пустая строка, если условий нет
+func (b *queryBuilder) whereClause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// escapeLike - экранирование спецсимволов LIKE в подстроке поиска
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
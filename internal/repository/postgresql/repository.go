@@ -1,162 +1,696 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	"github.com/pkg/errors"
+	"html"
+	"store_api/internal/config"
+	"store_api/internal/domain/errs"
 	"store_api/internal/domain/models"
 	"store_api/internal/domain/models/dto"
+	"strings"
 )
 
-func NewStoreRepository() (*StoreRepository, error) {
-	db, err := getDB()
+// NewStoreRepository - создание репозитория поверх подключения db с настройками транзакций из cfg
+func NewStoreRepository(db *sqlx.DB, cfg config.DB) (*StoreRepository, error) {
+	isolation, err := parseIsolationLevel(cfg.TxIsolation)
 	if err != nil {
 		return nil, fmt.Errorf("[NewStoreRepository]: %v", err)
 	}
-	return &StoreRepository{db: db}, nil
+	return &StoreRepository{
+		db:        db,
+		ex:        db,
+		isolation: isolation,
+		txRetries: cfg.TxMaxRetries,
+	}, nil
 }
 
+// goodsColumns - колонки товара в формате, пригодном для сканирования в models.Goods
+const goodsColumns = `goods_id, name, price AS "price.amount", currency AS "price.currency", quantity, version`
+
+// variantColumns - колонки варианта в формате, пригодном для сканирования в models.Variant
+const variantColumns = `variant_id, goods_id, sku, attributes, price AS "price.amount", currency AS "price.currency", quantity`
+
 type StoreRepository struct {
 	db *sqlx.DB
+	// ex - соединение, на котором выполняются запросы: db или текущая транзакция tx
+	ex        executor
+	tx        *sqlx.Tx
+	isolation sql.IsolationLevel
+	txRetries int
 }
 
-func (r *StoreRepository) GoodsAdd(goods *models.Goods) error {
-	_, err := r.db.NamedExec(`INSERT INTO goods (goods_id, name, price, quantity) VALUES (:goods_id, :name, :price, :quantity)`, goods)
+func (r *StoreRepository) GoodsAdd(ctx context.Context, goods *dto.GoodsCreate) (*models.Goods, error) {
+	var created *models.Goods
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		created = &models.Goods{}
+		err := txRepo.ex.GetContext(ctx, created, `
+			INSERT INTO goods (name, price, currency, quantity) VALUES ($1, $2, $3, $4)
+			RETURNING `+goodsColumns+`
+		`, goods.Name, goods.Price.Amount, goods.Price.Currency, goods.Quantity)
+		if err != nil {
+			return classifyErr(err, "failed to add goods")
+		}
+
+		// Товар продаётся через варианты, поэтому вместе с ним создаётся вариант по умолчанию
+		variant := models.Variant{}
+		err = txRepo.ex.GetContext(ctx, &variant, `
+			INSERT INTO variants (goods_id, sku, price, currency, quantity) VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+variantColumns+`
+		`, created.GoodsId, models.DefaultSku(created.GoodsId), goods.Price.Amount, goods.Price.Currency, goods.Quantity)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to add default variant of goods with id %d", created.GoodsId))
+		}
+		created.Variants = []models.Variant{variant}
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to add goods")
+		return nil, err
 	}
-	return nil
+	return created, nil
 }
 
-func (r *StoreRepository) GoodsGet(goodsId int64) (*models.Goods, error) {
+func (r *StoreRepository) GoodsGet(ctx context.Context, goodsId int64) (*models.Goods, error) {
 	goods := &models.Goods{}
-	err := r.db.Get(goods, `SELECT * FROM goods WHERE goods_id=$1`, goodsId)
+	err := r.ex.GetContext(ctx, goods, `SELECT `+goodsColumns+` FROM goods WHERE goods_id=$1`, goodsId)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.Wrapf(err, "failed to get goods with id %d", goodsId)
-		}
-		return nil, errors.Wrap(err, "failed to get goods")
+		return nil, classifyErr(err, fmt.Sprintf("failed to get goods with id %d", goodsId))
+	}
+
+	goods.Variants = make([]models.Variant, 0)
+	err = r.ex.SelectContext(ctx, &goods.Variants,
+		`SELECT `+variantColumns+` FROM variants WHERE goods_id = $1 ORDER BY variant_id`,
+		goodsId,
+	)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get variants of goods with id %d", goodsId))
 	}
 	return goods, nil
 }
 
-func (r *StoreRepository) GoodsUpdate(goodsId int64, goods *dto.GoodsUpdate) error {
-	_, err := r.db.Exec(
-		`UPDATE goods SET name=:$1, price=$2, quantity=$3 WHERE goods_id=$4`,
-		goods.Name,
-		goods.Price,
-		goods.Quantity,
+// goodsSortColumns - колонки ключа сортировки каталога, значения подставляются только из этого списка
+var goodsSortColumns = map[dto.GoodsSort]string{
+	dto.GoodsSortId:    "goods_id",
+	dto.GoodsSortPrice: "price",
+	dto.GoodsSortName:  `name COLLATE "C"`,
+}
+
+func (r *StoreRepository) GoodsList(ctx context.Context, filter *dto.GoodsFilter) (*models.GoodsPage, error) {
+	column, ok := goodsSortColumns[filter.Sort]
+	if !ok {
+		return nil, errs.New(errs.Validation, "unknown sort field %q", filter.Sort)
+	}
+	direction, cmp := "ASC", ">"
+	if filter.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	q := &queryBuilder{}
+	if filter.Name != "" {
+		q.where(`name ILIKE ` + q.arg("%"+escapeLike(filter.Name)+"%"))
+	}
+	if filter.PriceMin != nil {
+		q.where(`currency = ` + q.arg(filter.PriceMin.Currency) + ` AND price >= ` + q.arg(filter.PriceMin.Amount))
+	}
+	if filter.PriceMax != nil {
+		q.where(`currency = ` + q.arg(filter.PriceMax.Currency) + ` AND price <= ` + q.arg(filter.PriceMax.Amount))
+	}
+	if filter.InStock {
+		q.where(`quantity > 0`)
+	}
+	with := ""
+	if filter.CategoryId != 0 {
+		with = categorySubtree(q.arg(filter.CategoryId))
+		q.where(`goods_id IN (SELECT gc.goods_id FROM goods_to_categories gc JOIN subtree s ON s.category_id = gc.category_id)`)
+	}
+	if after := filter.After; after != nil {
+		// Сравнение кортежей (ключ, goods_id) продолжает выборку строго после последнего товара страницы
+		switch filter.Sort {
+		case dto.GoodsSortId:
+			q.where(`goods_id ` + cmp + ` ` + q.arg(after.GoodsId))
+		case dto.GoodsSortPrice:
+			q.where(`(price, goods_id) ` + cmp + ` (` + q.arg(after.Price) + `, ` + q.arg(after.GoodsId) + `)`)
+		case dto.GoodsSortName:
+			q.where(`(` + column + `, goods_id) ` + cmp + ` (` + q.arg(after.Name) + `, ` + q.arg(after.GoodsId) + `)`)
+		}
+	}
+	orderBy := column + " " + direction
+	if filter.Sort != dto.GoodsSortId {
+		orderBy += ", goods_id " + direction
+	}
+	// Лишняя строка показывает, есть ли следующая страница
+	query := with + `SELECT ` + goodsColumns + ` FROM goods` + q.whereClause() +
+		` ORDER BY ` + orderBy + ` LIMIT ` + q.arg(filter.Limit+1)
+
+	goods := make([]models.Goods, 0, filter.Limit+1)
+	err := r.ex.SelectContext(ctx, &goods, query, q.args...)
+	if err != nil {
+		return nil, classifyErr(err, "failed to list goods")
+	}
+	return dto.NewGoodsPage(filter, goods), nil
+}
+
+func (r *StoreRepository) GoodsSearch(ctx context.Context, search *dto.GoodsSearch) ([]models.GoodsSearchResult, error) {
+	// Слова запроса содержат только буквы и цифры, поэтому безопасны для синтаксиса to_tsquery
+	terms := make([]string, 0, len(search.Terms))
+	for _, term := range search.Terms {
+		terms = append(terms, term+":*")
+	}
+	found := make([]models.GoodsSearchResult, 0)
+	err := r.ex.SelectContext(ctx, &found, `
+		WITH q AS (SELECT to_tsquery('russian', $1) || to_tsquery('english', $1) AS query)
+		SELECT `+goodsColumns+`,
+			ts_rank_cd(search, q.query) AS rank,
+			ts_headline('russian', name, q.query, 'StartSel=' || $3 || ', StopSel=' || $4 || ', HighlightAll=true') AS snippet
+		FROM goods, q
+		WHERE search @@ q.query
+		ORDER BY rank DESC, goods_id
+		LIMIT $2
+	`, strings.Join(terms, " & "), search.Limit, snippetStart, snippetStop)
+	if err != nil {
+		return nil, classifyErr(err, "failed to search goods")
+	}
+	for i := range found {
+		found[i].Snippet = highlightSnippet(found[i].Snippet)
+	}
+	return found, nil
+}
+
+// Границы совпадений в выводе ts_headline - символы из области частного использования Unicode,
+// которые заменяются тегами только после экранирования названия
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+// highlightSnippet - экранирование HTML в выводе ts_headline и выделение совпадений тегами <b></b>
+func highlightSnippet(headline string) string {
+	snippet := html.EscapeString(headline)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<b>")
+	return strings.ReplaceAll(snippet, snippetStop, "</b>")
+}
+
+func (r *StoreRepository) GoodsUpdate(ctx context.Context, goodsId, version int64, goods *dto.GoodsUpdate) (int64, error) {
+	patch := &dto.GoodsPatch{Name: &goods.Name, Price: &goods.Price, Quantity: &goods.Quantity}
+	return r.goodsPatch(ctx, goodsId, version, patch, fmt.Sprintf("failed to update goods with id %d", goodsId))
+}
+
+func (r *StoreRepository) GoodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch) (int64, error) {
+	return r.goodsPatch(ctx, goodsId, version, patch, fmt.Sprintf("failed to patch goods with id %d", goodsId))
+}
+
+// goodsPatch - изменение переданных в patch полей товара версии version, msg описывает операцию
+func (r *StoreRepository) goodsPatch(ctx context.Context, goodsId, version int64, patch *dto.GoodsPatch, msg string) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		var err error
+		next, err = txRepo.bumpVersion(ctx, goodsVersion, goodsId, version, msg)
+		if err != nil {
+			return err
+		}
+
+		q := &queryBuilder{}
+		if patch.Name != nil {
+			q.set(`name = ` + q.arg(*patch.Name))
+		}
+		if patch.Price != nil {
+			q.set(`price = ` + q.arg(patch.Price.Amount))
+			q.set(`currency = ` + q.arg(patch.Price.Currency))
+		}
+		if len(q.sets) != 0 {
+			q.where(`goods_id = ` + q.arg(goodsId))
+			_, err = txRepo.ex.ExecContext(ctx, `UPDATE goods`+q.setClause()+q.whereClause(), q.args...)
+			if err != nil {
+				return classifyErr(err, msg)
+			}
+		}
+		if patch.Price == nil && patch.Quantity == nil {
+			return nil
+		}
+		return txRepo.goodsStockUpdate(ctx, goodsId, patch.Price, patch.Quantity)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// goodsStockUpdate - перенос цены и остатка товара в его единственный вариант, nil значения не меняются.
+// У товара с несколькими вариантами остаток - сумма остатков вариантов и напрямую не меняется
+func (r *StoreRepository) goodsStockUpdate(ctx context.Context, goodsId int64, price *models.Money, quantity *int64) error {
+	variants := make([]struct {
+		VariantId int64 `db:"variant_id"`
+		Quantity  int64 `db:"quantity"`
+	}, 0)
+	err := r.ex.SelectContext(ctx, &variants,
+		`SELECT variant_id, quantity FROM variants WHERE goods_id = $1 ORDER BY variant_id FOR UPDATE`,
 		goodsId,
 	)
 	if err != nil {
-		return errors.Wrap(err, "failed to update goods")
+		return classifyErr(err, fmt.Sprintf("failed to get variants of goods with id %d", goodsId))
+	}
+	if len(variants) != 1 {
+		var total int64
+		for _, variant := range variants {
+			total += variant.Quantity
+		}
+		if quantity != nil && total != *quantity {
+			return errs.New(
+				errs.Validation,
+				"goods with id %d has %d variants, update stock of its variants instead",
+				goodsId,
+				len(variants),
+			)
+		}
+		return nil
 	}
-	return nil
-}
 
-func (r *StoreRepository) GoodsDelete(goodsId int64) error {
-	_, err := r.db.Exec(`DELETE FROM goods WHERE goods_id=$1`, goodsId)
+	q := &queryBuilder{}
+	if price != nil {
+		q.set(`price = ` + q.arg(price.Amount))
+		q.set(`currency = ` + q.arg(price.Currency))
+	}
+	if quantity != nil {
+		q.set(`quantity = ` + q.arg(*quantity))
+	}
+	q.where(`variant_id = ` + q.arg(variants[0].VariantId))
+	_, err = r.ex.ExecContext(ctx, `UPDATE variants`+q.setClause()+q.whereClause(), q.args...)
 	if err != nil {
-		return errors.Wrapf(err, "failed to delete goods with id %d", goodsId)
+		return classifyErr(err, fmt.Sprintf("failed to update variant with id %d", variants[0].VariantId))
+	}
+	if quantity == nil {
+		return nil
+	}
+	_, err = r.ex.ExecContext(ctx, `UPDATE goods SET quantity = $1 WHERE goods_id = $2`, *quantity, goodsId)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to update goods with id %d", goodsId))
 	}
 	return nil
 }
 
-func (r *StoreRepository) CartCreate(cart *models.Cart) error {
-	_, err := r.db.NamedExec(`INSERT INTO carts (cart_id, goods_id, quantity, total) VALUES (:cart_id, :goods_id, :quantity, :total)`, cart)
+func (r *StoreRepository) GoodsDelete(ctx context.Context, goodsId, version int64) error {
+	res, err := r.ex.ExecContext(ctx, `DELETE FROM goods WHERE goods_id=$1 AND version=$2`, goodsId, version)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to delete goods with id %d", goodsId))
+	}
+	count, err := res.RowsAffected()
 	if err != nil {
-		return errors.Wrap(err, "failed to create cart")
+		return classifyErr(err, fmt.Sprintf("failed to delete goods with id %d", goodsId))
+	}
+	if count == 0 {
+		return r.versionConflict(ctx, goodsVersion, goodsId, version, fmt.Sprintf("failed to delete goods with id %d", goodsId))
 	}
 	return nil
 }
 
-func (r *StoreRepository) CartAddGoods(goods *dto.GoodsAdd) error {
-	_, err := r.db.NamedExec(`INSERT INTO carts (cart_id, goods_id, quantity, total) VALUES (:cart_id, :goods_id, :quantity, :total) ON CONFLICT ON CONSTRAINT carts_pkey DO UPDATE SET quantity = carts.quantity + :quantity, total = carts.total + :total`, goods)
+func (r *StoreRepository) CartCreate(ctx context.Context, customerId int64) (*models.Cart, error) {
+	cart := &models.Cart{Goods: make([]models.CartItem, 0)}
+	var owner *int64
+	if customerId != 0 {
+		owner = &customerId
+	}
+	err := r.ex.GetContext(ctx, cart, `INSERT INTO carts (customer_id) VALUES ($1) RETURNING cart_id, version`, owner)
 	if err != nil {
-		return errors.Wrap(err, "failed to add goods to cart")
+		return nil, classifyErr(err, "failed to create cart")
 	}
-	return nil
+	return cart, nil
 }
 
-func (r *StoreRepository) CartGetGoods() ([]models.Goods, error) {
-	goods := make([]models.Goods, 0)
-	err := r.db.Select(&goods, `SELECT g.* FROM goods g JOIN carts c ON g.goods_id=c.goods_id`)
+func (r *StoreRepository) CartAddGoods(ctx context.Context, cartId, version int64, goods *dto.GoodsAdd) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to add goods with id %d to cart with id %d", goods.GoodsId, cartId)
+		var err error
+		next, err = txRepo.bumpVersion(ctx, cartsVersion, cartId, version, msg)
+		if err != nil {
+			return err
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `
+			INSERT INTO goods_to_carts (cart_id, goods_id, variant_id, quantity) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (cart_id, variant_id) DO UPDATE SET quantity = goods_to_carts.quantity + EXCLUDED.quantity
+		`, cartId, goods.GoodsId, goods.VariantId, goods.Quantity)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get goods from cart")
+		return 0, err
 	}
-	return goods, nil
+	return next, nil
 }
 
-func (r *StoreRepository) CartGoodsUpdate(cartId, goodsId, quantity int64) error {
-	query := `UPDATE cart_goods SET quantity = $1 WHERE cart_id = $2 AND goods_id = $3`
-	_, err := r.db.Exec(query, quantity, cartId, goodsId)
+func (r *StoreRepository) CartGetGoods(ctx context.Context, cartId int64, currency string) (*models.Cart, error) {
+	cart := &models.Cart{}
+	err := r.ex.GetContext(ctx, cart, `SELECT cart_id, version FROM carts WHERE cart_id = $1`, cartId)
 	if err != nil {
-		return err
+		return nil, classifyErr(err, fmt.Sprintf("failed to get cart with id %d", cartId))
 	}
 
-	return nil
+	cart.Goods = make([]models.CartItem, 0)
+	err = r.ex.SelectContext(ctx, &cart.Goods, `
+		SELECT gc.goods_id, gc.variant_id, v.sku, g.name, v.attributes,
+			v.price AS "price.amount", v.currency AS "price.currency", gc.quantity
+		FROM goods_to_carts gc
+			JOIN variants v ON v.variant_id = gc.variant_id
+			JOIN goods g ON g.goods_id = gc.goods_id
+		WHERE gc.cart_id = $1
+		ORDER BY gc.variant_id
+	`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get goods from cart with id %d", cartId))
+	}
+	rates, err := r.exchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = cart.Convert(rates, currency)
+	if err != nil {
+		return nil, errs.New(errs.Validation, "failed to get cart with id %d in %s: %v", cartId, currency, err)
+	}
+	return cart, nil
 }
 
-func (r *StoreRepository) CartDeleteGoods(cartId, goodsId int64) error {
-	_, err := r.db.Exec(`DELETE FROM carts WHERE cart_id = $1 AND goods_id = $2`, cartId, goodsId)
+func (r *StoreRepository) CartGoodsUpdate(ctx context.Context, cartId, variantId, version, quantity int64) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to update variant with id %d in cart with id %d", variantId, cartId)
+		var err error
+		next, err = txRepo.bumpVersion(ctx, cartsVersion, cartId, version, msg)
+		if err != nil {
+			return err
+		}
+		query := `UPDATE goods_to_carts SET quantity = $1 WHERE cart_id = $2 AND variant_id = $3`
+		res, err := txRepo.ex.ExecContext(ctx, query, quantity, cartId, variantId)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		return checkAffected(res, msg)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete goods from cart: %w", err)
+		return 0, err
 	}
-
-	return nil
+	return next, nil
 }
 
-func (r *StoreRepository) CartDelete(cartId int64) error {
-	_, err := r.db.Exec(`DELETE FROM carts WHERE cart_id = $1`, cartId)
+func (r *StoreRepository) CartDeleteGoods(ctx context.Context, cartId, variantId, version int64) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to delete variant with id %d from cart with id %d", variantId, cartId)
+		var err error
+		next, err = txRepo.bumpVersion(ctx, cartsVersion, cartId, version, msg)
+		if err != nil {
+			return err
+		}
+		res, err := txRepo.ex.ExecContext(ctx,
+			`DELETE FROM goods_to_carts WHERE cart_id = $1 AND variant_id = $2`,
+			cartId,
+			variantId,
+		)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		return checkAffected(res, msg)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete cart: %w", err)
+		return 0, err
 	}
+	return next, nil
+}
 
-	return nil
+func (r *StoreRepository) CartDelete(ctx context.Context, cartId, version int64) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		_, err := txRepo.ex.ExecContext(ctx, `DELETE FROM goods_to_carts WHERE cart_id = $1`, cartId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete goods from cart with id %d", cartId))
+		}
+		res, err := txRepo.ex.ExecContext(ctx, `DELETE FROM carts WHERE cart_id = $1 AND version = $2`, cartId, version)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete cart with id %d", cartId))
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete cart with id %d", cartId))
+		}
+		if count == 0 {
+			return txRepo.versionConflict(ctx, cartsVersion, cartId, version, fmt.Sprintf("failed to delete cart with id %d", cartId))
+		}
+		return nil
+	})
 }
 
-func (r *StoreRepository) OrderCreate(cartId int64) (*models.Order, error) {
-	//TODO implement me
-	panic("implement me")
+func (r *StoreRepository) OrderCreate(ctx context.Context, cartId, customerId int64, currency string) (*models.Order, error) {
+	var order *models.Order
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		var err error
+		order, err = txRepo.orderCreate(ctx, cartId, customerId, currency)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
 }
 
-func (r *StoreRepository) OrderGet(orderId int64) (*models.Order, error) {
+// orderCreate - оформление заказа, вызывается только внутри транзакции
+func (r *StoreRepository) orderCreate(ctx context.Context, cartId, customerId int64, currency string) (*models.Order, error) {
+	// Блокируем корзину: повторное оформление той же корзины ждёт первый заказ и видит корзину уже пустой
+	var locked int64
+	err := r.ex.GetContext(ctx, &locked, `SELECT cart_id FROM carts WHERE cart_id = $1 FOR UPDATE`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get cart with id %d", cartId))
+	}
+
+	// Товары блокируются раньше вариантов, как в GoodsUpdate и операциях с вариантами, иначе встречные
+	// транзакции берут блокировки в разном порядке и взаимно блокируются
+	_, err = r.ex.ExecContext(ctx, `
+		SELECT 1 FROM goods
+		WHERE goods_id IN (SELECT goods_id FROM goods_to_carts WHERE cart_id = $1)
+		ORDER BY goods_id
+		FOR UPDATE
+	`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to lock goods of cart with id %d", cartId))
+	}
+
+	// Блокируем строки вариантов из корзины, чтобы параллельные заказы не продали один и тот же остаток
+	lines := make([]struct {
+		models.OrderItem
+		Stock int64 `db:"stock"`
+	}, 0)
+	err = r.ex.SelectContext(ctx, &lines, `
+		SELECT gc.goods_id, gc.variant_id, v.sku, g.name, v.attributes,
+			v.price AS "price.amount", v.currency AS "price.currency", gc.quantity, v.quantity AS stock
+		FROM goods_to_carts gc
+			JOIN variants v ON v.variant_id = gc.variant_id
+			JOIN goods g ON g.goods_id = gc.goods_id
+		WHERE gc.cart_id = $1
+		ORDER BY gc.variant_id
+		FOR UPDATE OF v
+	`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get goods of cart with id %d", cartId))
+	}
+	if len(lines) == 0 {
+		return nil, errs.New(errs.Validation, "cart with id %d is empty", cartId)
+	}
+
+	order := &models.Order{Goods: make([]models.OrderItem, 0, len(lines))}
+	for _, line := range lines {
+		if line.Stock < line.Quantity {
+			return nil, errs.New(
+				errs.InsufficientStock,
+				"variant %s of goods with id %d is out of stock: requested %d, available %d",
+				line.Sku,
+				line.GoodsId,
+				line.Quantity,
+				line.Stock,
+			)
+		}
+		order.Goods = append(order.Goods, line.OrderItem)
+	}
+	// Курс читается в той же транзакции, поэтому заказ фиксирует ровно тот курс, по которому посчитан итог
+	rates, err := r.exchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = order.Convert(rates, currency)
+	if err != nil {
+		return nil, errs.New(errs.Validation, "failed to create order from cart with id %d in %s: %v", cartId, currency, err)
+	}
+
+	for _, item := range order.Goods {
+		_, err = r.ex.ExecContext(ctx,
+			`UPDATE variants SET quantity = quantity - $1 WHERE variant_id = $2`,
+			item.Quantity,
+			item.VariantId,
+		)
+		if err != nil {
+			return nil, classifyErr(err, fmt.Sprintf("failed to reserve variant with id %d", item.VariantId))
+		}
+		_, err = r.ex.ExecContext(ctx,
+			`UPDATE goods SET quantity = quantity - $1, version = version + 1 WHERE goods_id = $2`,
+			item.Quantity,
+			item.GoodsId,
+		)
+		if err != nil {
+			return nil, classifyErr(err, fmt.Sprintf("failed to reserve goods with id %d", item.GoodsId))
+		}
+	}
+
+	if customerId != 0 {
+		order.CustomerId = &customerId
+	}
+	err = r.ex.QueryRowxContext(ctx, `
+		INSERT INTO orders (customer_id, total, currency, exchange_rate, order_time) VALUES ($1, $2, $3, $4, now())
+		RETURNING order_id, status, order_time
+	`, order.CustomerId, order.Total.Amount, order.Total.Currency, order.ExchangeRate).Scan(
+		&order.OrderId,
+		&order.Status,
+		&order.OrderTime,
+	)
+	if err != nil {
+		return nil, classifyErr(err, "failed to create order")
+	}
+
+	for _, item := range order.Goods {
+		_, err = r.ex.ExecContext(ctx,
+			`INSERT INTO goods_to_orders (order_id, goods_id, variant_id, quantity, price) VALUES ($1, $2, $3, $4, $5)`,
+			order.OrderId,
+			item.GoodsId,
+			item.VariantId,
+			item.Quantity,
+			item.Price.Amount,
+		)
+		if err != nil {
+			return nil, classifyErr(err, fmt.Sprintf("failed to add variant with id %d to order", item.VariantId))
+		}
+	}
+
+	_, err = r.ex.ExecContext(ctx, `DELETE FROM goods_to_carts WHERE cart_id = $1`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to clear cart with id %d", cartId))
+	}
+	_, err = r.ex.ExecContext(ctx, `UPDATE carts SET version = version + 1 WHERE cart_id = $1`, cartId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to clear cart with id %d", cartId))
+	}
+	return order, nil
+}
+
+func (r *StoreRepository) OrderGet(ctx context.Context, orderId int64) (*models.Order, error) {
 	order := models.Order{}
-	err := r.db.Get(&order, `
-		SELECT order_id, goods_id, quantity, total, order_time, finish_time
+	err := r.ex.GetContext(ctx, &order, `
+		SELECT order_id, customer_id, total AS "total.amount", currency AS "total.currency", exchange_rate, status,
+			order_time, finish_time
 		FROM orders WHERE order_id = $1
 	`, orderId)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return &models.Order{}, fmt.Errorf("order with ID %d not found in DB", orderId)
-		}
-		return &models.Order{}, fmt.Errorf("failed to get order: %w", err)
+		return nil, classifyErr(err, fmt.Sprintf("failed to get order with id %d", orderId))
+	}
+
+	order.Goods = make([]models.OrderItem, 0)
+	err = r.ex.SelectContext(ctx, &order.Goods, `
+		SELECT gto.goods_id, gto.variant_id, v.sku, g.name, v.attributes,
+			gto.price AS "price.amount", o.currency AS "price.currency", gto.quantity
+		FROM goods_to_orders gto
+			JOIN variants v ON v.variant_id = gto.variant_id
+			JOIN goods g ON g.goods_id = gto.goods_id
+			JOIN orders o ON o.order_id = gto.order_id
+		WHERE gto.order_id = $1
+		ORDER BY gto.variant_id
+	`, orderId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get goods of order with id %d", orderId))
 	}
 
 	return &order, nil
 }
 
-func (r *StoreRepository) OrderUpdate(orderId int64, order *dto.OrderUpdate) error {
-	//TODO implement me
-	panic("implement me")
-}
+func (r *StoreRepository) OrderList(ctx context.Context, filter *dto.OrderFilter) (*models.OrderPage, error) {
+	q := &queryBuilder{}
+	if filter.CustomerId != 0 {
+		q.where(`o.customer_id = ` + q.arg(filter.CustomerId))
+	}
+	if filter.From != nil {
+		q.where(`o.order_time >= ` + q.arg(*filter.From))
+	}
+	if filter.To != nil {
+		q.where(`o.order_time < ` + q.arg(*filter.To))
+	}
+	if filter.Status != "" {
+		q.where(`o.status = ` + q.arg(filter.Status))
+	}
+	if after := filter.After; after != nil {
+		// Сравнение кортежей продолжает выборку строго после последнего заказа страницы
+		q.where(`(o.order_time, o.order_id) < (` + q.arg(after.OrderTime) + `, ` + q.arg(after.OrderId) + `)`)
+	}
+	// Лишняя строка показывает, есть ли следующая страница
+	query := `
+		SELECT o.order_id, o.customer_id, o.total AS "total.amount", o.currency AS "total.currency", o.status,
+			coalesce(i.item_count, 0) AS item_count, coalesce(i.quantity, 0) AS quantity, o.order_time, o.finish_time
+		FROM orders o
+			LEFT JOIN LATERAL (
+				SELECT count(*) AS item_count, sum(gto.quantity) AS quantity
+				FROM goods_to_orders gto WHERE gto.order_id = o.order_id
+			) i ON true` + q.whereClause() + `
+		ORDER BY o.order_time DESC, o.order_id DESC
+		LIMIT ` + q.arg(filter.Limit+1)
 
-func (r *StoreRepository) OrderDelete(orderId int64) error {
-	// Check if order with provided ID exists
-	var count int64
-	err := r.db.Get(&count, `SELECT count(*) FROM orders WHERE order_id = $1`, orderId)
+	orders := make([]models.OrderSummary, 0, filter.Limit+1)
+	err := r.ex.SelectContext(ctx, &orders, query, q.args...)
 	if err != nil {
-		return fmt.Errorf("failed to check order in DB: %w", err)
-	}
-	if count == 0 {
-		return fmt.Errorf("order with ID %d not found in DB", orderId)
+		return nil, classifyErr(err, "failed to list orders")
 	}
+	return dto.NewOrderPage(filter, orders), nil
+}
+
+func (r *StoreRepository) OrderDelete(ctx context.Context, orderId int64) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		_, err := txRepo.ex.ExecContext(ctx, `DELETE FROM goods_to_orders WHERE order_id = $1`, orderId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete goods of order with id %d", orderId))
+		}
+		res, err := txRepo.ex.ExecContext(ctx, `DELETE FROM orders WHERE order_id = $1`, orderId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete order with id %d", orderId))
+		}
+
+		return checkAffected(res, fmt.Sprintf("failed to delete order with id %d", orderId))
+	})
+}
 
-	_, err = r.db.Exec(`DELETE FROM orders WHERE order_id = $1`, orderId)
+func (r *StoreRepository) ExchangeRatesGet(ctx context.Context) ([]models.ExchangeRate, error) {
+	rates := make([]models.ExchangeRate, 0)
+	err := r.ex.SelectContext(ctx, &rates, `SELECT currency, rate, updated_at FROM exchange_rates ORDER BY currency`)
 	if err != nil {
-		return fmt.Errorf("failed to delete order from DB: %w", err)
+		return nil, classifyErr(err, "failed to get exchange rates")
 	}
+	return rates, nil
+}
 
+func (r *StoreRepository) ExchangeRateSet(ctx context.Context, rate *models.ExchangeRate) error {
+	_, err := r.ex.ExecContext(ctx, `
+		INSERT INTO exchange_rates (currency, rate, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+	`, rate.Currency, rate.Rate)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to set exchange rate for %s", rate.Currency))
+	}
 	return nil
 }
+
+// exchangeRates - курсы валют относительно базовой валюты для пересчёта цен
+func (r *StoreRepository) exchangeRates(ctx context.Context) (*models.ExchangeRates, error) {
+	list, err := r.ExchangeRatesGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := models.NewExchangeRates(models.DefaultCurrency(), list)
+	if err != nil {
+		return nil, errs.Wrap(errs.Internal, err, "failed to load exchange rates")
+	}
+	return rates, nil
+}
@@ -0,0 +1,123 @@
+package postgresql
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"reflect"
+	"store_api/internal/config"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"store_api/internal/migrate"
+	"store_api/internal/repository"
+	"store_api/internal/repository/repotest"
+	"store_api/migrations"
+	"testing"
+)
+
+// TestStoreRepository - прогон тестов соответствия на БД из STORE_TEST_DB_DSN.
+// Тесты применяют миграции и очищают все таблицы, поэтому DSN должен указывать на отдельную тестовую БД
+func TestStoreRepository(t *testing.T) {
+	dsn := os.Getenv("STORE_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("STORE_TEST_DB_DSN is not set")
+	}
+	db, err := Connect(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	migrator, err := migrate.New(db.DB, migrations.Postgres, "postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repository.StoreRepository {
+		_, err := db.Exec(`TRUNCATE order_status_history, goods_to_orders, goods_to_carts, goods_to_categories, categories, orders, carts, customers, variants, goods, exchange_rates RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("failed to clean db: %v", err)
+		}
+		repo, err := NewStoreRepository(db, config.DB{TxIsolation: "serializable", TxMaxRetries: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestIsSerializationFailure(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pq.Error{Code: serializationFailure}, true},
+		{"deadlock", &pq.Error{Code: deadlockDetected}, true},
+		{"wrapped deadlock", errors.Wrap(&pq.Error{Code: deadlockDetected}, "failed to commit transaction"), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"not pq error", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+	for _, c := range cases {
+		if got := isSerializationFailure(c.err); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+// spoolRows - источник строк импорта из готового среза
+type spoolRows []dto.GoodsImportRow
+
+func (r *spoolRows) Next() (*dto.GoodsImportRow, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	row := (*r)[0]
+	*r = (*r)[1:]
+	return &row, nil
+}
+
+func TestGoodsImportSpool(t *testing.T) {
+	rows := spoolRows{
+		{Line: 2, Goods: models.Goods{Name: "Мышь, \"беспроводная\"\n", Price: models.Money{Amount: 49999, Currency: "RUB"}, Quantity: 3}},
+		{Line: 3, Goods: models.Goods{GoodsId: 7, Name: "Ноутбук", Price: models.Money{Amount: 5500000, Currency: "USD"}}},
+	}
+	spool, err := newGoodsImportSpool(&rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := spool.file.Name()
+
+	want := [][]string{
+		{"2", "", "Мышь, \"беспроводная\"\n", "49999", "RUB", "3"},
+		{"3", "7", "Ноутбук", "5500000", "USD", "0"},
+	}
+	// Строки перечитываются при каждом повторе транзакции
+	for attempt := 0; attempt < 2; attempt++ {
+		got := make([][]string, 0)
+		err = spool.each(func(record []string) error {
+			got = append(got, append([]string(nil), record...))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("attempt %d: expected %q, got %q", attempt, want, got)
+		}
+	}
+
+	err = spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected goods import file %s to be removed, got %v", name, err)
+	}
+}
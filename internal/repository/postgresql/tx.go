@@ -0,0 +1,95 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"store_api/internal/repository"
+	"strings"
+)
+
+const (
+	// serializationFailure - SQLSTATE ошибки сериализации, после которой транзакцию можно повторить
+	serializationFailure = "40001"
+	// deadlockDetected - SQLSTATE взаимной блокировки, транзакция-жертва тоже откатывается целиком и её можно повторить
+	deadlockDetected = "40P01"
+)
+
+// executor - общие методы *sqlx.DB и *sqlx.Tx, через которые репозиторий выполняет запросы
+type executor interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// parseIsolationLevel - преобразует уровень изоляции из конфига в sql.IsolationLevel
+func parseIsolationLevel(level string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unknown transaction isolation level %q", level)
+}
+
+// isSerializationFailure - проверяет, что транзакция откатилась из-за конфликта сериализации или взаимной блокировки
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected)
+}
+
+func (r *StoreRepository) WithTx(ctx context.Context, fn func(repo repository.StoreRepository) error) error {
+	return r.inTx(ctx, func(txRepo *StoreRepository) error {
+		return fn(txRepo)
+	})
+}
+
+// inTx - выполняет fn в транзакции с уровнем изоляции из конфига и повторяет её при ошибках сериализации
+// и взаимных блокировках.
+// Если репозиторий уже работает внутри транзакции, fn выполняется в ней же.
+func (r *StoreRepository) inTx(ctx context.Context, fn func(txRepo *StoreRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	var err error
+	for attempt := 0; attempt <= r.txRetries; attempt++ {
+		err = r.runTx(ctx, fn)
+		if !isSerializationFailure(err) {
+			return err
+		}
+	}
+	return errors.Wrapf(err, "transaction failed after %d retries", r.txRetries)
+}
+
+func (r *StoreRepository) runTx(ctx context.Context, fn func(txRepo *StoreRepository) error) error {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: r.isolation})
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	txRepo := &StoreRepository{
+		db:        r.db,
+		ex:        tx,
+		tx:        tx,
+		isolation: r.isolation,
+		txRetries: r.txRetries,
+	}
+	err = fn(txRepo)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+	return nil
+}
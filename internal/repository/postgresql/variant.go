@@ -0,0 +1,142 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+)
+
+func (r *StoreRepository) VariantAdd(ctx context.Context, goodsId, version int64, variant *dto.VariantCreate) (*models.Variant, int64, error) {
+	var (
+		created *models.Variant
+		next    int64
+	)
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		msg := fmt.Sprintf("failed to add variant to goods with id %d", goodsId)
+		var err error
+		next, err = txRepo.bumpVersion(ctx, goodsVersion, goodsId, version, msg)
+		if err != nil {
+			return err
+		}
+		created = &models.Variant{}
+		err = txRepo.ex.GetContext(ctx, created, `
+			INSERT INTO variants (goods_id, sku, attributes, price, currency, quantity) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+variantColumns+`
+		`, goodsId, variant.Sku, variant.Attributes, variant.Price.Amount, variant.Price.Currency, variant.Quantity)
+		if err != nil {
+			return classifyErr(err, msg)
+		}
+		// Остаток товара - сумма остатков его вариантов
+		return txRepo.goodsStockAdd(ctx, goodsId, variant.Quantity)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return created, next, nil
+}
+
+func (r *StoreRepository) VariantGet(ctx context.Context, variantId int64) (*models.Variant, error) {
+	variant := &models.Variant{}
+	err := r.ex.GetContext(ctx, variant, `SELECT `+variantColumns+` FROM variants WHERE variant_id = $1`, variantId)
+	if err != nil {
+		return nil, classifyErr(err, fmt.Sprintf("failed to get variant with id %d", variantId))
+	}
+	return variant, nil
+}
+
+func (r *StoreRepository) VariantUpdate(ctx context.Context, variantId, version int64, variant *dto.VariantUpdate) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		var (
+			stored *models.Variant
+			err    error
+		)
+		stored, next, err = txRepo.variantLock(ctx, variantId, version, fmt.Sprintf("failed to update variant with id %d", variantId))
+		if err != nil {
+			return err
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `
+			UPDATE variants SET sku = $1, attributes = $2, price = $3, currency = $4, quantity = $5
+			WHERE variant_id = $6
+		`, variant.Sku, variant.Attributes, variant.Price.Amount, variant.Price.Currency, variant.Quantity, variantId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to update variant with id %d", variantId))
+		}
+		return txRepo.goodsStockAdd(ctx, stored.GoodsId, variant.Quantity-stored.Quantity)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+func (r *StoreRepository) VariantDelete(ctx context.Context, variantId, version int64) (int64, error) {
+	var next int64
+	err := r.inTx(ctx, func(txRepo *StoreRepository) error {
+		var (
+			stored *models.Variant
+			err    error
+		)
+		stored, next, err = txRepo.variantLock(ctx, variantId, version, fmt.Sprintf("failed to delete variant with id %d", variantId))
+		if err != nil {
+			return err
+		}
+		var count int64
+		err = txRepo.ex.GetContext(ctx, &count, `SELECT count(*) FROM variants WHERE goods_id = $1`, stored.GoodsId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to count variants of goods with id %d", stored.GoodsId))
+		}
+		if count == 1 {
+			return errs.New(
+				errs.Validation,
+				"failed to delete variant with id %d: it is the last variant of goods with id %d",
+				variantId,
+				stored.GoodsId,
+			)
+		}
+		_, err = txRepo.ex.ExecContext(ctx, `DELETE FROM variants WHERE variant_id = $1`, variantId)
+		if err != nil {
+			return classifyErr(err, fmt.Sprintf("failed to delete variant with id %d", variantId))
+		}
+		return txRepo.goodsStockAdd(ctx, stored.GoodsId, -stored.Quantity)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// variantLock - чтение варианта с блокировкой строки до конца транзакции. Версия товара варианта
+// должна быть равна version, возвращает вариант и новую версию товара, msg описывает операцию
+func (r *StoreRepository) variantLock(ctx context.Context, variantId, version int64, msg string) (*models.Variant, int64, error) {
+	var goodsId int64
+	err := r.ex.GetContext(ctx, &goodsId, `SELECT goods_id FROM variants WHERE variant_id = $1`, variantId)
+	if err != nil {
+		return nil, 0, classifyErr(err, msg)
+	}
+	// Сначала блокируется строка товара, как в GoodsUpdate, затем строка варианта
+	next, err := r.bumpVersion(ctx, goodsVersion, goodsId, version, msg)
+	if err != nil {
+		return nil, 0, err
+	}
+	variant := &models.Variant{}
+	err = r.ex.GetContext(ctx, variant,
+		`SELECT `+variantColumns+` FROM variants WHERE variant_id = $1 FOR UPDATE`,
+		variantId,
+	)
+	if err != nil {
+		return nil, 0, classifyErr(err, msg)
+	}
+	return variant, next, nil
+}
+
+// goodsStockAdd - изменение остатка товара на delta после изменения остатков его вариантов
+func (r *StoreRepository) goodsStockAdd(ctx context.Context, goodsId, delta int64) error {
+	_, err := r.ex.ExecContext(ctx, `UPDATE goods SET quantity = quantity + $1 WHERE goods_id = $2`, delta, goodsId)
+	if err != nil {
+		return classifyErr(err, fmt.Sprintf("failed to update stock of goods with id %d", goodsId))
+	}
+	return nil
+}
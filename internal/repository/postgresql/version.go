@@ -0,0 +1,51 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+	"store_api/internal/domain/errs"
+)
+
+// versioned - таблица с колонкой version для оптимистичных блокировок
+type versioned struct {
+	table string
+	key   string
+}
+
+var (
+	goodsVersion = versioned{table: "goods", key: "goods_id"}
+	cartsVersion = versioned{table: "carts", key: "cart_id"}
+)
+
+// bumpVersion - увеличение версии строки с ключом id, если её текущая версия равна version.
+// Возвращает новую версию, msg описывает операцию
+func (r *StoreRepository) bumpVersion(ctx context.Context, v versioned, id, version int64, msg string) (int64, error) {
+	var next int64
+	err := r.ex.GetContext(ctx, &next,
+		`UPDATE `+v.table+` SET version = version + 1 WHERE `+v.key+` = $1 AND version = $2 RETURNING version`,
+		id,
+		version,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, r.versionConflict(ctx, v, id, version, msg)
+	}
+	if err != nil {
+		return 0, classifyErr(err, msg)
+	}
+	return next, nil
+}
+
+// versionConflict - причина, по которой запрос с условием version = $n не нашёл строку:
+// строки нет (NotFound) или её версия уже другая (VersionConflict)
+func (r *StoreRepository) versionConflict(ctx context.Context, v versioned, id, version int64, msg string) error {
+	var current int64
+	err := r.ex.GetContext(ctx, &current, `SELECT version FROM `+v.table+` WHERE `+v.key+` = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errs.New(errs.NotFound, "%s: not found", msg)
+	}
+	if err != nil {
+		return classifyErr(err, msg)
+	}
+	return errs.New(errs.VersionConflict, "%s: version %d does not match current version %d", msg, version, current)
+}
@@ -0,0 +1,1621 @@
+// Package repotest - набор тестов соответствия для любой реализации repository.StoreRepository
+package repotest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"store_api/internal/domain/errs"
+	"store_api/internal/domain/models"
+	"store_api/internal/domain/models/dto"
+	"store_api/internal/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory - создаёт пустой репозиторий для одного теста
+type Factory func(t *testing.T) repository.StoreRepository
+
+// Run - прогон всех тестов соответствия на репозиториях, созданных newRepo
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.StoreRepository)
+	}{
+		{"GoodsCRUD", testGoodsCRUD},
+		{"GoodsNotFound", testGoodsNotFound},
+		{"GoodsLimits", testGoodsLimits},
+		{"GoodsPatch", testGoodsPatch},
+		{"GoodsDeleteReferenced", testGoodsDeleteReferenced},
+		{"GoodsList", testGoodsList},
+		{"GoodsSearch", testGoodsSearch},
+		{"GoodsImportExport", testGoodsImportExport},
+		{"Categories", testCategories},
+		{"Money", testMoney},
+		{"ExchangeRates", testExchangeRates},
+		{"CartLines", testCartLines},
+		{"CartNotFound", testCartNotFound},
+		{"Customers", testCustomers},
+		{"Versions", testVersions},
+		{"Variants", testVariants},
+		{"OrderCreate", testOrderCreate},
+		{"OrderCreateInsufficientStock", testOrderCreateInsufficientStock},
+		{"OrderCreateEmptyCart", testOrderCreateEmptyCart},
+		{"OrderCreateConcurrent", testOrderCreateConcurrent},
+		{"OrderStatusDelete", testOrderStatusDelete},
+		{"OrderStatusRace", testOrderStatusRace},
+		{"OrderRestock", testOrderRestock},
+		{"OrderList", testOrderList},
+		{"WithTx", testWithTx},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+func addGoods(t *testing.T, repo repository.StoreRepository, name string, price models.Money, quantity int64) *models.Goods {
+	t.Helper()
+	goods, err := repo.GoodsAdd(context.Background(), &dto.GoodsCreate{Name: name, Price: price, Quantity: quantity})
+	if err != nil {
+		t.Fatalf("GoodsAdd: %v", err)
+	}
+	if goods.GoodsId <= 0 {
+		t.Fatalf("GoodsAdd: expected generated goods_id, got %d", goods.GoodsId)
+	}
+	return goods
+}
+
+func createCart(t *testing.T, repo repository.StoreRepository, lines map[int64]int64) int64 {
+	t.Helper()
+	ctx := context.Background()
+	cart, err := repo.CartCreate(ctx, 0)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	for goodsId, quantity := range lines {
+		cartAddGoods(t, repo, cart.CartId, goodsId, quantity)
+	}
+	return cart.CartId
+}
+
+func addCustomer(t *testing.T, repo repository.StoreRepository, email string) *models.Customer {
+	t.Helper()
+	customer, err := repo.CustomerAdd(context.Background(), &dto.CustomerCreate{Email: email, Name: "Покупатель"})
+	if err != nil {
+		t.Fatalf("CustomerAdd: %v", err)
+	}
+	if customer.CustomerId <= 0 {
+		t.Fatalf("CustomerAdd: expected generated customer_id, got %d", customer.CustomerId)
+	}
+	return customer
+}
+
+// cartAddGoods - добавление в корзину единственного варианта товара
+func cartAddGoods(t *testing.T, repo repository.StoreRepository, cartId, goodsId, quantity int64) {
+	t.Helper()
+	variantId := defaultVariant(t, repo, goodsId)
+	_, err := repo.CartAddGoods(
+		context.Background(),
+		cartId,
+		cartVersion(t, repo, cartId),
+		&dto.GoodsAdd{GoodsId: goodsId, VariantId: variantId, Quantity: quantity},
+	)
+	if err != nil {
+		t.Fatalf("CartAddGoods: %v", err)
+	}
+}
+
+// goodsVersion - текущая версия товара
+func goodsVersion(t *testing.T, repo repository.StoreRepository, goodsId int64) int64 {
+	t.Helper()
+	goods, err := repo.GoodsGet(context.Background(), goodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	return goods.Version
+}
+
+// cartVersion - текущая версия корзины
+func cartVersion(t *testing.T, repo repository.StoreRepository, cartId int64) int64 {
+	t.Helper()
+	cart, err := repo.CartGetGoods(context.Background(), cartId, models.DefaultCurrency())
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	return cart.Version
+}
+
+// defaultVariant - идентификатор варианта, созданного вместе с товаром
+func defaultVariant(t *testing.T, repo repository.StoreRepository, goodsId int64) int64 {
+	t.Helper()
+	goods, err := repo.GoodsGet(context.Background(), goodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if len(goods.Variants) == 0 {
+		t.Fatalf("GoodsGet: expected goods with id %d to have variants", goodsId)
+	}
+	return goods.Variants[0].VariantId
+}
+
+// rub - сумма в копейках
+func rub(amount int64) models.Money {
+	return models.NewMoney(amount, "RUB")
+}
+
+func expectKind(t *testing.T, op string, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: expected %v error, got %v", op, target.(*errs.Error).Kind, err)
+	}
+}
+
+func testGoodsCRUD(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	created := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	if created.Name != "Ноутбук" || created.Price != rub(5000000) || created.Quantity != 10 {
+		t.Fatalf("GoodsAdd: unexpected goods %+v", created)
+	}
+
+	got, err := repo.GoodsGet(ctx, created.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if !reflect.DeepEqual(got, created) {
+		t.Fatalf("GoodsGet: expected %+v, got %+v", created, got)
+	}
+
+	version, err := repo.GoodsUpdate(
+		ctx,
+		created.GoodsId,
+		created.Version,
+		&dto.GoodsUpdate{Name: "Ноутбук Asus", Price: rub(5500000), Quantity: 15},
+	)
+	if err != nil {
+		t.Fatalf("GoodsUpdate: %v", err)
+	}
+	got, err = repo.GoodsGet(ctx, created.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Name != "Ноутбук Asus" || got.Price != rub(5500000) || got.Quantity != 15 {
+		t.Fatalf("GoodsUpdate: goods not updated, got %+v", got)
+	}
+
+	err = repo.GoodsDelete(ctx, created.GoodsId, version)
+	if err != nil {
+		t.Fatalf("GoodsDelete: %v", err)
+	}
+	_, err = repo.GoodsGet(ctx, created.GoodsId)
+	expectKind(t, "GoodsGet after delete", err, errs.ErrNotFound)
+}
+
+// testGoodsLimits - название товара ограничено 40 символами, нулевой остаток допустим
+func testGoodsLimits(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	name := strings.Repeat("ю", 40)
+	goods := addGoods(t, repo, name, rub(100), 0)
+	if goods.Name != name || goods.Quantity != 0 {
+		t.Fatalf("GoodsAdd: unexpected goods %+v", goods)
+	}
+
+	_, err := repo.GoodsAdd(ctx, &dto.GoodsCreate{Name: name + "ю", Price: rub(100), Quantity: 1})
+	expectKind(t, "GoodsAdd", err, errs.ErrValidation)
+	_, err = repo.GoodsUpdate(ctx, goods.GoodsId, goods.Version, &dto.GoodsUpdate{
+		Name:     name + "ю",
+		Price:    rub(100),
+		Quantity: 1,
+	})
+	expectKind(t, "GoodsUpdate", err, errs.ErrValidation)
+
+	version, err := repo.GoodsUpdate(ctx, goods.GoodsId, goods.Version, &dto.GoodsUpdate{
+		Name:     "Ноутбук",
+		Price:    rub(100),
+		Quantity: 5,
+	})
+	if err != nil {
+		t.Fatalf("GoodsUpdate: %v", err)
+	}
+	_, err = repo.GoodsUpdate(ctx, goods.GoodsId, version, &dto.GoodsUpdate{Name: "Ноутбук", Price: rub(100)})
+	if err != nil {
+		t.Fatalf("GoodsUpdate: stock must be settable to zero, got %v", err)
+	}
+	got, err := repo.GoodsGet(ctx, goods.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Name != "Ноутбук" || got.Quantity != 0 || got.Variants[0].Quantity != 0 {
+		t.Fatalf("GoodsUpdate: expected goods out of stock, got %+v", got)
+	}
+}
+
+func testGoodsNotFound(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	_, err := repo.GoodsGet(ctx, missing)
+	expectKind(t, "GoodsGet", err, errs.ErrNotFound)
+	_, err = repo.GoodsUpdate(ctx, missing, 1, &dto.GoodsUpdate{Name: "x", Price: rub(100), Quantity: 1})
+	expectKind(t, "GoodsUpdate", err, errs.ErrNotFound)
+	err = repo.GoodsDelete(ctx, missing, 1)
+	expectKind(t, "GoodsDelete", err, errs.ErrNotFound)
+}
+
+func testGoodsPatch(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+
+	quantity := int64(7)
+	version, err := repo.GoodsPatch(ctx, goods.GoodsId, goods.Version, &dto.GoodsPatch{Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("GoodsPatch: %v", err)
+	}
+	got, err := repo.GoodsGet(ctx, goods.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Name != goods.Name || got.Price != goods.Price || got.Quantity != 7 || got.Version != version {
+		t.Fatalf("GoodsPatch: expected only quantity to change, got %+v", got)
+	}
+	if got.Variants[0].Quantity != 7 || got.Variants[0].Price != goods.Price {
+		t.Fatalf("GoodsPatch: expected stock of the only variant to change, got %+v", got.Variants[0])
+	}
+
+	price := rub(5500000)
+	version, err = repo.GoodsPatch(ctx, goods.GoodsId, version, &dto.GoodsPatch{Price: &price})
+	if err != nil {
+		t.Fatalf("GoodsPatch: %v", err)
+	}
+	got, err = repo.GoodsGet(ctx, goods.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Price != price || got.Quantity != 7 || got.Variants[0].Price != price || got.Variants[0].Quantity != 7 {
+		t.Fatalf("GoodsPatch: expected only price to change, got %+v", got)
+	}
+
+	_, err = repo.GoodsPatch(ctx, goods.GoodsId, goods.Version, &dto.GoodsPatch{Quantity: &quantity})
+	expectKind(t, "GoodsPatch with stale version", err, errs.ErrVersionConflict)
+	_, err = repo.GoodsPatch(ctx, missing, 1, &dto.GoodsPatch{Quantity: &quantity})
+	expectKind(t, "GoodsPatch", err, errs.ErrNotFound)
+
+	// Название товара с несколькими вариантами меняется, а остаток - только через варианты
+	_, version, err = repo.VariantAdd(ctx, goods.GoodsId, goodsVersion(t, repo, goods.GoodsId), &dto.VariantCreate{
+		Sku:      "LAPTOP-32GB",
+		Price:    price,
+		Quantity: 1,
+	})
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+	quantity = 10
+	_, err = repo.GoodsPatch(ctx, goods.GoodsId, version, &dto.GoodsPatch{Quantity: &quantity})
+	expectKind(t, "GoodsPatch stock of goods with several variants", err, errs.ErrValidation)
+	name := "Ноутбук Asus"
+	_, err = repo.GoodsPatch(ctx, goods.GoodsId, version, &dto.GoodsPatch{Name: &name})
+	if err != nil {
+		t.Fatalf("GoodsPatch: %v", err)
+	}
+	got, err = repo.GoodsGet(ctx, goods.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Name != name || got.Quantity != 8 || got.Variants[0].Quantity != 7 {
+		t.Fatalf("GoodsPatch: expected only name to change, got %+v", got)
+	}
+}
+
+func testGoodsDeleteReferenced(t *testing.T, repo repository.StoreRepository) {
+	goods := addGoods(t, repo, "Планшет", rub(800000), 5)
+	createCart(t, repo, map[int64]int64{goods.GoodsId: 1})
+	err := repo.GoodsDelete(context.Background(), goods.GoodsId, goods.Version)
+	expectKind(t, "GoodsDelete", err, errs.ErrConflict)
+}
+
+// listAll - все страницы каталога по filter, проходя по next_cursor
+func listAll(t *testing.T, repo repository.StoreRepository, filter dto.GoodsFilter) []int64 {
+	t.Helper()
+	ids := make([]int64, 0)
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("GoodsList: pagination does not terminate")
+		}
+		page, err := repo.GoodsList(context.Background(), &filter)
+		if err != nil {
+			t.Fatalf("GoodsList: %v", err)
+		}
+		if len(page.Goods) > filter.Limit {
+			t.Fatalf("GoodsList: page exceeds limit %d: %d goods", filter.Limit, len(page.Goods))
+		}
+		for _, goods := range page.Goods {
+			ids = append(ids, goods.GoodsId)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		filter.After, err = dto.DecodeGoodsCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeGoodsCursor: %v", err)
+		}
+	}
+}
+
+func expectIds(t *testing.T, op string, got []int64, want ...int64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected goods %v, got %v", op, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: expected goods %v, got %v", op, want, got)
+		}
+	}
+}
+
+func testGoodsList(t *testing.T, repo repository.StoreRepository) {
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10).GoodsId
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 0).GoodsId
+	mouse := addGoods(t, repo, "Мышь 100%", rub(49999), 3).GoodsId
+	pad := addGoods(t, repo, "Коврик", rub(49999), 7).GoodsId
+	keyboard := addGoods(t, repo, "Клавиатура", models.NewMoney(2500, "USD"), 1).GoodsId
+
+	expectIds(t, "GoodsList by id", listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, Limit: 2}),
+		laptop, tablet, mouse, pad, keyboard)
+	expectIds(t, "GoodsList by id desc", listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, Desc: true, Limit: 3}),
+		keyboard, pad, mouse, tablet, laptop)
+	expectIds(t, "GoodsList by name", listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortName, Limit: 2}),
+		keyboard, pad, mouse, laptop, tablet)
+
+	minPrice, maxPrice := rub(49999), rub(800000)
+	expectIds(t, "GoodsList by price range", listAll(t, repo, dto.GoodsFilter{
+		Sort:     dto.GoodsSortPrice,
+		Desc:     true,
+		PriceMin: &minPrice,
+		PriceMax: &maxPrice,
+		Limit:    1,
+	}), tablet, pad, mouse)
+	expectIds(t, "GoodsList in stock", listAll(t, repo, dto.GoodsFilter{
+		Sort:     dto.GoodsSortPrice,
+		PriceMin: &minPrice,
+		InStock:  true,
+		Limit:    10,
+	}), mouse, pad, laptop)
+	// Спецсимволы LIKE в подстроке ищутся буквально
+	expectIds(t, "GoodsList by name substring", listAll(t, repo, dto.GoodsFilter{
+		Sort:  dto.GoodsSortId,
+		Name:  "%",
+		Limit: 10,
+	}), mouse)
+	expectIds(t, "GoodsList by name substring", listAll(t, repo, dto.GoodsFilter{
+		Sort:  dto.GoodsSortId,
+		Name:  "утбук",
+		Limit: 10,
+	}), laptop)
+}
+
+func testGoodsSearch(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	asus := addGoods(t, repo, "Ноутбук Asus", rub(5000000), 10).GoodsId
+	lenovo := addGoods(t, repo, "Ноутбук Lenovo", rub(6000000), 10).GoodsId
+	addGoods(t, repo, "Планшет Lenovo", rub(800000), 10)
+
+	found, err := repo.GoodsSearch(ctx, dto.NewGoodsSearch("ноут", 10))
+	if err != nil {
+		t.Fatalf("GoodsSearch: %v", err)
+	}
+	ids := make([]int64, 0, len(found))
+	for _, result := range found {
+		ids = append(ids, result.GoodsId)
+	}
+	expectIds(t, "GoodsSearch by prefix", ids, asus, lenovo)
+
+	found, err = repo.GoodsSearch(ctx, dto.NewGoodsSearch("Ноутбук, LEN", 10))
+	if err != nil {
+		t.Fatalf("GoodsSearch: %v", err)
+	}
+	if len(found) != 1 || found[0].GoodsId != lenovo || found[0].Rank <= 0 {
+		t.Fatalf("GoodsSearch: expected only goods %d, got %+v", lenovo, found)
+	}
+	if found[0].Snippet != "<b>Ноутбук</b> <b>Lenovo</b>" {
+		t.Fatalf("GoodsSearch: unexpected snippet %q", found[0].Snippet)
+	}
+
+	// Название экранируется, в snippet нет других тегов, кроме выделения
+	quoted := addGoods(t, repo, `Смартфон "Mi" & Co`, rub(2000000), 10).GoodsId
+	tagged := addGoods(t, repo, `<img src=x onerror=alert(1)>Планшет`, rub(2000000), 10).GoodsId
+	found, err = repo.GoodsSearch(ctx, dto.NewGoodsSearch("смартфон", 10))
+	if err != nil {
+		t.Fatalf("GoodsSearch: %v", err)
+	}
+	if len(found) != 1 || found[0].GoodsId != quoted {
+		t.Fatalf("GoodsSearch: expected only goods %d, got %+v", quoted, found)
+	}
+	if found[0].Snippet != "<b>Смартфон</b> &#34;Mi&#34; &amp; Co" {
+		t.Fatalf("GoodsSearch: unexpected snippet %q", found[0].Snippet)
+	}
+	found, err = repo.GoodsSearch(ctx, dto.NewGoodsSearch("планшет", 10))
+	if err != nil {
+		t.Fatalf("GoodsSearch: %v", err)
+	}
+	snippets := make(map[int64]string, len(found))
+	for _, result := range found {
+		snippets[result.GoodsId] = result.Snippet
+	}
+	snippet, ok := snippets[tagged]
+	if !ok || strings.Contains(snippet, "<img") || !strings.Contains(snippet, "<b>Планшет</b>") {
+		t.Fatalf("GoodsSearch: expected goods %d with escaped snippet, got %+v", tagged, found)
+	}
+
+	found, err = repo.GoodsSearch(ctx, dto.NewGoodsSearch("холодильник", 10))
+	if err != nil {
+		t.Fatalf("GoodsSearch: %v", err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("GoodsSearch: expected no goods, got %+v", found)
+	}
+}
+
+// importRows - источник строк импорта из готового среза
+type importRows []dto.GoodsImportRow
+
+func (r *importRows) Next() (*dto.GoodsImportRow, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	row := (*r)[0]
+	*r = (*r)[1:]
+	return &row, nil
+}
+
+func testGoodsImportExport(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 2)
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 1)
+	_, _, err := repo.VariantAdd(ctx, tablet.GoodsId, tablet.Version, &dto.VariantCreate{
+		Sku:      "TABLET-LTE",
+		Price:    rub(900000),
+		Quantity: 2,
+	})
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+
+	rows := importRows{
+		{Line: 2, Goods: models.Goods{GoodsId: laptop.GoodsId, Name: "Ноутбук Asus", Price: rub(5500000), Quantity: 4}},
+		{Line: 3, Goods: models.Goods{Name: "Мышь", Price: rub(49999), Quantity: 3}},
+		{Line: 4, Goods: models.Goods{GoodsId: missing, Name: "Нет", Price: rub(100), Quantity: 1}},
+		{Line: 5, Goods: models.Goods{GoodsId: laptop.GoodsId, Name: "Ноутбук", Price: rub(100), Quantity: 1}},
+		{Line: 6, Goods: models.Goods{GoodsId: tablet.GoodsId, Name: "Планшет", Price: rub(800000), Quantity: 10}},
+	}
+	result, err := repo.GoodsImport(ctx, &rows)
+	if err != nil {
+		t.Fatalf("GoodsImport: %v", err)
+	}
+	if result.Inserted != 1 || result.Updated != 1 {
+		t.Fatalf("GoodsImport: expected 1 inserted and 1 updated goods, got %+v", result)
+	}
+	lines := make([]int64, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		lines = append(lines, e.Line)
+	}
+	expectIds(t, "GoodsImport rejected lines", lines, 4, 5, 6)
+
+	got, err := repo.GoodsGet(ctx, laptop.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Name != "Ноутбук Asus" || got.Quantity != 4 || got.Variants[0].Price != rub(5500000) || got.Variants[0].Quantity != 4 {
+		t.Fatalf("GoodsImport: expected goods and its variant to be updated, got %+v", got)
+	}
+	if got.Version != laptop.Version+1 {
+		t.Fatalf("GoodsImport: expected updated goods version %d, got %d", laptop.Version+1, got.Version)
+	}
+	if version := goodsVersion(t, repo, tablet.GoodsId); version != tablet.Version+1 {
+		t.Fatalf("GoodsImport: expected rejected goods to keep version %d, got %d", tablet.Version+1, version)
+	}
+
+	exported := make([]models.Goods, 0)
+	err = repo.GoodsExport(ctx, func(goods *models.Goods) error {
+		exported = append(exported, *goods)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GoodsExport: %v", err)
+	}
+	if len(exported) != 3 || exported[2].Name != "Мышь" || exported[2].Price != rub(49999) {
+		t.Fatalf("GoodsExport: unexpected goods %+v", exported)
+	}
+	mouse, err := repo.GoodsGet(ctx, exported[2].GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if len(mouse.Variants) != 1 || mouse.Variants[0].Quantity != 3 {
+		t.Fatalf("GoodsImport: expected imported goods to get a default variant, got %+v", mouse.Variants)
+	}
+	if mouse.Version != 1 {
+		t.Fatalf("GoodsImport: expected inserted goods version 1, got %d", mouse.Version)
+	}
+
+	stop := errors.New("stop")
+	err = repo.GoodsExport(ctx, func(goods *models.Goods) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("GoodsExport: expected callback error, got %v", err)
+	}
+}
+
+func addCategory(t *testing.T, repo repository.StoreRepository, name string, parentId *int64) int64 {
+	t.Helper()
+	category, err := repo.CategoryAdd(context.Background(), &dto.CategoryCreate{ParentId: parentId, Name: name})
+	if err != nil {
+		t.Fatalf("CategoryAdd: %v", err)
+	}
+	if category.CategoryId <= 0 || category.Name != name {
+		t.Fatalf("CategoryAdd: unexpected category %+v", category)
+	}
+	return category.CategoryId
+}
+
+func testCategories(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	electronics := addCategory(t, repo, "Электроника", nil)
+	computers := addCategory(t, repo, "Компьютеры", &electronics)
+	laptops := addCategory(t, repo, "Ноутбуки", &computers)
+	garden := addCategory(t, repo, "Сад", nil)
+
+	got, err := repo.CategoryGet(ctx, laptops)
+	if err != nil {
+		t.Fatalf("CategoryGet: %v", err)
+	}
+	if got.ParentId == nil || *got.ParentId != computers {
+		t.Fatalf("CategoryGet: expected parent %d, got %+v", computers, got)
+	}
+	categories, err := repo.CategoriesGet(ctx)
+	if err != nil {
+		t.Fatalf("CategoriesGet: %v", err)
+	}
+	if len(categories) != 4 || categories[0].CategoryId != electronics {
+		t.Fatalf("CategoriesGet: unexpected categories %+v", categories)
+	}
+	_, err = repo.CategoryGet(ctx, missing)
+	expectKind(t, "CategoryGet", err, errs.ErrNotFound)
+	missingParent := int64(missing)
+	_, err = repo.CategoryAdd(ctx, &dto.CategoryCreate{ParentId: &missingParent, Name: "Сирота"})
+	expectKind(t, "CategoryAdd with missing parent", err, errs.ErrConflict)
+
+	err = repo.CategoryUpdate(ctx, electronics, &dto.CategoryUpdate{ParentId: &laptops, Name: "Электроника"})
+	expectKind(t, "CategoryUpdate into own subtree", err, errs.ErrValidation)
+	err = repo.CategoryUpdate(ctx, electronics, &dto.CategoryUpdate{ParentId: &electronics, Name: "Электроника"})
+	expectKind(t, "CategoryUpdate into itself", err, errs.ErrValidation)
+	err = repo.CategoryUpdate(ctx, missing, &dto.CategoryUpdate{Name: "Нет"})
+	expectKind(t, "CategoryUpdate", err, errs.ErrNotFound)
+	err = repo.CategoryDelete(ctx, computers)
+	expectKind(t, "CategoryDelete with children", err, errs.ErrConflict)
+
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10).GoodsId
+	monitor := addGoods(t, repo, "Монитор", rub(1500000), 10).GoodsId
+	shovel := addGoods(t, repo, "Лопата", rub(100000), 10).GoodsId
+	for _, link := range []struct{ categoryId, goodsId int64 }{
+		{laptops, laptop},
+		{computers, monitor},
+		{electronics, monitor},
+		{garden, shovel},
+	} {
+		err = repo.CategoryAddGoods(ctx, link.categoryId, link.goodsId)
+		if err != nil {
+			t.Fatalf("CategoryAddGoods: %v", err)
+		}
+	}
+	err = repo.CategoryAddGoods(ctx, laptops, laptop)
+	if err != nil {
+		t.Fatalf("CategoryAddGoods must be idempotent: %v", err)
+	}
+	err = repo.CategoryAddGoods(ctx, laptops, missing)
+	expectKind(t, "CategoryAddGoods with missing goods", err, errs.ErrConflict)
+
+	expectIds(t, "GoodsList by category subtree",
+		listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, CategoryId: electronics, Limit: 10}), laptop, monitor)
+	expectIds(t, "GoodsList by leaf category",
+		listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, CategoryId: laptops, Limit: 10}), laptop)
+
+	// Перенос ноутбуков в сад переносит и их товары
+	err = repo.CategoryUpdate(ctx, laptops, &dto.CategoryUpdate{ParentId: &garden, Name: "Ноутбуки"})
+	if err != nil {
+		t.Fatalf("CategoryUpdate: %v", err)
+	}
+	expectIds(t, "GoodsList after moving category",
+		listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, CategoryId: garden, Limit: 10}), laptop, shovel)
+
+	err = repo.CategoryDeleteGoods(ctx, laptops, laptop)
+	if err != nil {
+		t.Fatalf("CategoryDeleteGoods: %v", err)
+	}
+	err = repo.CategoryDeleteGoods(ctx, laptops, laptop)
+	expectKind(t, "CategoryDeleteGoods", err, errs.ErrNotFound)
+
+	// Связи с категориями не мешают удалению товара и категории
+	err = repo.GoodsDelete(ctx, shovel, goodsVersion(t, repo, shovel))
+	if err != nil {
+		t.Fatalf("GoodsDelete of categorized goods: %v", err)
+	}
+	err = repo.CategoryDelete(ctx, laptops)
+	if err != nil {
+		t.Fatalf("CategoryDelete: %v", err)
+	}
+	expectIds(t, "GoodsList after deletes",
+		listAll(t, repo, dto.GoodsFilter{Sort: dto.GoodsSortId, CategoryId: garden, Limit: 10}))
+}
+
+func testMoney(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	mouse := addGoods(t, repo, "Мышь", rub(49999), 3)
+	got, err := repo.GoodsGet(ctx, mouse.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if got.Price != rub(49999) {
+		t.Fatalf("GoodsGet: expected price 499.99 RUB, got %v", got.Price)
+	}
+
+	cartId := createCart(t, repo, map[int64]int64{mouse.GoodsId: 3})
+	cart, err := repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if cart.Total != rub(149997) {
+		t.Fatalf("CartGetGoods: expected total 1499.97 RUB, got %v", cart.Total)
+	}
+
+	keyboard := addGoods(t, repo, "Клавиатура", models.NewMoney(2500, "USD"), 1)
+	cartAddGoods(t, repo, cartId, keyboard.GoodsId, 1)
+	_, err = repo.CartGetGoods(ctx, cartId, "RUB")
+	expectKind(t, "CartGetGoods without exchange rate", err, errs.ErrValidation)
+	_, err = repo.OrderCreate(ctx, cartId, 0, "RUB")
+	expectKind(t, "OrderCreate without exchange rate", err, errs.ErrValidation)
+}
+
+func testExchangeRates(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	// 1 RUB = 0.0125 USD, то есть 1 USD = 80 RUB
+	err := repo.ExchangeRateSet(ctx, &models.ExchangeRate{Currency: "USD", Rate: "0.0125"})
+	if err != nil {
+		t.Fatalf("ExchangeRateSet: %v", err)
+	}
+	rates, err := repo.ExchangeRatesGet(ctx)
+	if err != nil {
+		t.Fatalf("ExchangeRatesGet: %v", err)
+	}
+	if len(rates) != 1 || rates[0].Currency != "USD" || rates[0].UpdatedAt.IsZero() {
+		t.Fatalf("ExchangeRatesGet: unexpected rates %+v", rates)
+	}
+	err = repo.ExchangeRateSet(ctx, &models.ExchangeRate{Currency: "EUR", Rate: "-1"})
+	expectKind(t, "ExchangeRateSet with negative rate", err, errs.ErrValidation)
+
+	mouse := addGoods(t, repo, "Мышь", rub(49999), 3)
+	keyboard := addGoods(t, repo, "Клавиатура", models.NewMoney(2500, "USD"), 1)
+	cartId := createCart(t, repo, map[int64]int64{mouse.GoodsId: 3, keyboard.GoodsId: 1})
+
+	cart, err := repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if cart.Total != rub(349997) {
+		t.Fatalf("CartGetGoods: expected total 3499.97 RUB, got %v", cart.Total)
+	}
+	cart, err = repo.CartGetGoods(ctx, cartId, "USD")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	// 499.99 RUB = 6.249875 USD, цена позиции округляется до центов до умножения на количество
+	if cart.Total != models.NewMoney(4375, "USD") {
+		t.Fatalf("CartGetGoods: expected total 43.75 USD, got %v", cart.Total)
+	}
+	_, err = repo.CartGetGoods(ctx, cartId, "EUR")
+	expectKind(t, "CartGetGoods without exchange rate", err, errs.ErrValidation)
+
+	order, err := repo.OrderCreate(ctx, cartId, 0, "USD")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	if order.Total != models.NewMoney(4375, "USD") || order.ExchangeRate != "0.0125000000" {
+		t.Fatalf("OrderCreate: expected 43.75 USD at rate 0.0125, got %v at %s", order.Total, order.ExchangeRate)
+	}
+
+	// Заказ хранит курс на момент оформления и не зависит от его изменений
+	err = repo.ExchangeRateSet(ctx, &models.ExchangeRate{Currency: "USD", Rate: "0.01"})
+	if err != nil {
+		t.Fatalf("ExchangeRateSet: %v", err)
+	}
+	got, err := repo.OrderGet(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	if got.Total != order.Total || got.ExchangeRate != order.ExchangeRate {
+		t.Fatalf("OrderGet: expected %v at %s, got %v at %s", order.Total, order.ExchangeRate, got.Total, got.ExchangeRate)
+	}
+	for _, item := range got.Goods {
+		if item.Price.Currency != "USD" {
+			t.Fatalf("OrderGet: expected line prices in USD, got %v", item.Price)
+		}
+	}
+}
+
+func testCartLines(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 10)
+	cartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 1, tablet.GoodsId: 1})
+	otherCartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 3})
+
+	cartAddGoods(t, repo, cartId, tablet.GoodsId, 1)
+	cart, err := repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(cart.Goods) != 2 || cart.Total != rub(6600000) {
+		t.Fatalf("CartGetGoods: expected 2 lines with total 66000, got %+v", cart)
+	}
+	for _, item := range cart.Goods {
+		if item.GoodsId == tablet.GoodsId && (item.Quantity != 2 || item.Total != rub(1600000)) {
+			t.Fatalf("CartAddGoods: expected quantities to be summed, got %+v", item)
+		}
+	}
+
+	version, err := repo.CartGoodsUpdate(ctx, cartId, defaultVariant(t, repo, laptop.GoodsId), cart.Version, 2)
+	if err != nil {
+		t.Fatalf("CartGoodsUpdate: %v", err)
+	}
+	version, err = repo.CartDeleteGoods(ctx, cartId, defaultVariant(t, repo, tablet.GoodsId), version)
+	if err != nil {
+		t.Fatalf("CartDeleteGoods: %v", err)
+	}
+	cart, err = repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(cart.Goods) != 1 || cart.Goods[0].Quantity != 2 || cart.Total != rub(10000000) {
+		t.Fatalf("CartGetGoods: unexpected cart after update %+v", cart)
+	}
+	if cart.Version != version {
+		t.Fatalf("CartGetGoods: expected version %d, got %d", version, cart.Version)
+	}
+
+	other, err := repo.CartGetGoods(ctx, otherCartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(other.Goods) != 1 || other.Goods[0].Quantity != 3 {
+		t.Fatalf("CartGetGoods: carts must not share lines, got %+v", other)
+	}
+
+	err = repo.CartDelete(ctx, cartId, version)
+	if err != nil {
+		t.Fatalf("CartDelete: %v", err)
+	}
+	_, err = repo.CartGetGoods(ctx, cartId, "RUB")
+	expectKind(t, "CartGetGoods after delete", err, errs.ErrNotFound)
+}
+
+func testCartNotFound(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	cartId := createCart(t, repo, nil)
+	_, err := repo.CartGetGoods(ctx, missing, "RUB")
+	expectKind(t, "CartGetGoods", err, errs.ErrNotFound)
+	_, err = repo.CartGoodsUpdate(ctx, cartId, goods.Variants[0].VariantId, 1, 1)
+	expectKind(t, "CartGoodsUpdate", err, errs.ErrNotFound)
+	_, err = repo.CartDeleteGoods(ctx, cartId, goods.Variants[0].VariantId, 1)
+	expectKind(t, "CartDeleteGoods", err, errs.ErrNotFound)
+	_, err = repo.CartAddGoods(ctx, missing, 1, &dto.GoodsAdd{
+		GoodsId:   goods.GoodsId,
+		VariantId: goods.Variants[0].VariantId,
+		Quantity:  1,
+	})
+	expectKind(t, "CartAddGoods", err, errs.ErrNotFound)
+	err = repo.CartDelete(ctx, missing, 1)
+	expectKind(t, "CartDelete", err, errs.ErrNotFound)
+	_, err = repo.OrderCreate(ctx, missing, 0, "RUB")
+	expectKind(t, "OrderCreate", err, errs.ErrNotFound)
+}
+
+func testCustomers(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	customer := addCustomer(t, repo, "buyer@example.com")
+	got, err := repo.CustomerGet(ctx, customer.CustomerId)
+	if err != nil {
+		t.Fatalf("CustomerGet: %v", err)
+	}
+	if got.Email != "buyer@example.com" || got.Name != "Покупатель" || !got.CreatedAt.Equal(customer.CreatedAt) {
+		t.Fatalf("CustomerGet: expected %+v, got %+v", *customer, *got)
+	}
+	_, err = repo.CustomerAdd(ctx, &dto.CustomerCreate{Email: "buyer@example.com", Name: "Другой"})
+	expectKind(t, "CustomerAdd with duplicate email", err, errs.ErrConflict)
+	_, err = repo.CustomerGet(ctx, customer.CustomerId+1000)
+	expectKind(t, "CustomerGet of missing customer", err, errs.ErrNotFound)
+
+	cart, err := repo.CartCreate(ctx, customer.CustomerId)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	owner, err := repo.CartOwner(ctx, cart.CartId)
+	if err != nil {
+		t.Fatalf("CartOwner: %v", err)
+	}
+	if owner != customer.CustomerId {
+		t.Fatalf("CartOwner: expected customer %d, got %d", customer.CustomerId, owner)
+	}
+	anonymous := createCart(t, repo, nil)
+	owner, err = repo.CartOwner(ctx, anonymous)
+	if err != nil {
+		t.Fatalf("CartOwner: %v", err)
+	}
+	if owner != 0 {
+		t.Fatalf("CartOwner: expected cart without owner, got customer %d", owner)
+	}
+	_, err = repo.CartOwner(ctx, cart.CartId+1000)
+	expectKind(t, "CartOwner of missing cart", err, errs.ErrNotFound)
+	_, err = repo.CartCreate(ctx, customer.CustomerId+1000)
+	expectKind(t, "CartCreate for missing customer", err, errs.ErrConflict)
+}
+
+func testVersions(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	if goods.Version != 1 {
+		t.Fatalf("GoodsAdd: expected version 1, got %d", goods.Version)
+	}
+	update := &dto.GoodsUpdate{Name: goods.Name, Price: rub(5500000), Quantity: 10}
+	version, err := repo.GoodsUpdate(ctx, goods.GoodsId, goods.Version, update)
+	if err != nil {
+		t.Fatalf("GoodsUpdate: %v", err)
+	}
+	if version != goods.Version+1 || goodsVersion(t, repo, goods.GoodsId) != version {
+		t.Fatalf("GoodsUpdate: expected version %d, got %d", goods.Version+1, version)
+	}
+	_, err = repo.GoodsUpdate(ctx, goods.GoodsId, goods.Version, update)
+	expectKind(t, "GoodsUpdate with stale version", err, errs.ErrVersionConflict)
+	err = repo.GoodsDelete(ctx, goods.GoodsId, goods.Version)
+	expectKind(t, "GoodsDelete with stale version", err, errs.ErrVersionConflict)
+
+	// Изменение вариантов проверяет и меняет версию товара
+	variant := &dto.VariantCreate{Sku: "LAPTOP-32GB", Price: rub(6000000), Quantity: 1}
+	_, _, err = repo.VariantAdd(ctx, goods.GoodsId, goods.Version, variant)
+	expectKind(t, "VariantAdd with stale version", err, errs.ErrVersionConflict)
+	created, next, err := repo.VariantAdd(ctx, goods.GoodsId, version, variant)
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+	if next != version+1 || goodsVersion(t, repo, goods.GoodsId) != next {
+		t.Fatalf("VariantAdd: expected goods version %d, got %d", version+1, next)
+	}
+	version = next
+	variantUpdate := &dto.VariantUpdate{Sku: "LAPTOP-32GB", Price: rub(6000000), Quantity: 2}
+	_, err = repo.VariantUpdate(ctx, created.VariantId, version-1, variantUpdate)
+	expectKind(t, "VariantUpdate with stale version", err, errs.ErrVersionConflict)
+	next, err = repo.VariantUpdate(ctx, created.VariantId, version, variantUpdate)
+	if err != nil {
+		t.Fatalf("VariantUpdate: %v", err)
+	}
+	if next != version+1 || goodsVersion(t, repo, goods.GoodsId) != next {
+		t.Fatalf("VariantUpdate: expected goods version %d, got %d", version+1, next)
+	}
+	version = next
+	_, err = repo.VariantDelete(ctx, created.VariantId, version-1)
+	expectKind(t, "VariantDelete with stale version", err, errs.ErrVersionConflict)
+	if _, err = repo.VariantGet(ctx, created.VariantId); err != nil {
+		t.Fatalf("VariantDelete with stale version: expected variant to stay, got %v", err)
+	}
+	next, err = repo.VariantDelete(ctx, created.VariantId, version)
+	if err != nil {
+		t.Fatalf("VariantDelete: %v", err)
+	}
+	if next != version+1 || goodsVersion(t, repo, goods.GoodsId) != next {
+		t.Fatalf("VariantDelete: expected goods version %d, got %d", version+1, next)
+	}
+
+	cart, err := repo.CartCreate(ctx, 0)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	if cart.Version != 1 {
+		t.Fatalf("CartCreate: expected version 1, got %d", cart.Version)
+	}
+	line := &dto.GoodsAdd{GoodsId: goods.GoodsId, VariantId: goods.Variants[0].VariantId, Quantity: 1}
+	version, err = repo.CartAddGoods(ctx, cart.CartId, cart.Version, line)
+	if err != nil {
+		t.Fatalf("CartAddGoods: %v", err)
+	}
+	_, err = repo.CartAddGoods(ctx, cart.CartId, cart.Version, line)
+	expectKind(t, "CartAddGoods with stale version", err, errs.ErrVersionConflict)
+	_, err = repo.CartGoodsUpdate(ctx, cart.CartId, line.VariantId, cart.Version, 2)
+	expectKind(t, "CartGoodsUpdate with stale version", err, errs.ErrVersionConflict)
+	_, err = repo.CartDeleteGoods(ctx, cart.CartId, line.VariantId, cart.Version)
+	expectKind(t, "CartDeleteGoods with stale version", err, errs.ErrVersionConflict)
+	err = repo.CartDelete(ctx, cart.CartId, cart.Version)
+	expectKind(t, "CartDelete with stale version", err, errs.ErrVersionConflict)
+
+	// Отклонённые изменения не применяются
+	got, err := repo.CartGetGoods(ctx, cart.CartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if got.Version != version || len(got.Goods) != 1 || got.Goods[0].Quantity != 1 {
+		t.Fatalf("CartGetGoods: expected version %d with one line, got %+v", version, got)
+	}
+
+	// Оформление заказа очищает корзину и меняет её версию
+	_, err = repo.OrderCreate(ctx, cart.CartId, 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	if got := cartVersion(t, repo, cart.CartId); got != version+1 {
+		t.Fatalf("OrderCreate: expected cart version %d, got %d", version+1, got)
+	}
+}
+
+func testVariants(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	const missing = 1 << 30
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 2)
+	if len(laptop.Variants) != 1 || laptop.Variants[0].Sku != models.DefaultSku(laptop.GoodsId) ||
+		laptop.Variants[0].Quantity != 2 || laptop.Variants[0].Price != rub(5000000) {
+		t.Fatalf("GoodsAdd: expected default variant, got %+v", laptop.Variants)
+	}
+	base := laptop.Variants[0].VariantId
+
+	pro, _, err := repo.VariantAdd(ctx, laptop.GoodsId, laptop.Version, &dto.VariantCreate{
+		Sku:        "LAPTOP-32GB",
+		Attributes: models.Attributes{"memory": "32GB"},
+		Price:      rub(7000000),
+		Quantity:   3,
+	})
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+	if pro.VariantId <= 0 || pro.GoodsId != laptop.GoodsId || pro.Attributes["memory"] != "32GB" {
+		t.Fatalf("VariantAdd: unexpected variant %+v", pro)
+	}
+	_, _, err = repo.VariantAdd(ctx, laptop.GoodsId, goodsVersion(t, repo, laptop.GoodsId), &dto.VariantCreate{
+		Sku:   "LAPTOP-32GB",
+		Price: rub(100),
+	})
+	expectKind(t, "VariantAdd duplicate sku", err, errs.ErrConflict)
+	_, _, err = repo.VariantAdd(ctx, missing, 1, &dto.VariantCreate{Sku: "MISSING", Price: rub(100)})
+	expectKind(t, "VariantAdd missing goods", err, errs.ErrNotFound)
+
+	goods, err := repo.GoodsGet(ctx, laptop.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if len(goods.Variants) != 2 || goods.Quantity != 5 {
+		t.Fatalf("GoodsGet: expected 2 variants with total stock 5, got %+v", goods)
+	}
+	_, err = repo.GoodsUpdate(
+		ctx,
+		laptop.GoodsId,
+		goods.Version,
+		&dto.GoodsUpdate{Name: laptop.Name, Price: laptop.Price, Quantity: 10},
+	)
+	expectKind(t, "GoodsUpdate stock of goods with several variants", err, errs.ErrValidation)
+
+	cart, err := repo.CartCreate(ctx, 0)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	for _, line := range []dto.GoodsAdd{
+		{GoodsId: laptop.GoodsId, VariantId: base, Quantity: 1},
+		{GoodsId: laptop.GoodsId, VariantId: pro.VariantId, Quantity: 3},
+	} {
+		line := line
+		cart.Version, err = repo.CartAddGoods(ctx, cart.CartId, cart.Version, &line)
+		if err != nil {
+			t.Fatalf("CartAddGoods: %v", err)
+		}
+	}
+	lines, err := repo.CartGetGoods(ctx, cart.CartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(lines.Goods) != 2 || lines.Goods[1].Sku != "LAPTOP-32GB" || lines.Total != rub(26000000) {
+		t.Fatalf("CartGetGoods: expected a line per variant, got %+v", lines)
+	}
+
+	order, err := repo.OrderCreate(ctx, cart.CartId, 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	if len(order.Goods) != 2 || order.Goods[1].VariantId != pro.VariantId || order.Goods[1].Price != rub(7000000) {
+		t.Fatalf("OrderCreate: expected variant lines, got %+v", order.Goods)
+	}
+	stock, err := repo.VariantGet(ctx, pro.VariantId)
+	if err != nil {
+		t.Fatalf("VariantGet: %v", err)
+	}
+	if stock.Quantity != 0 {
+		t.Fatalf("OrderCreate: expected variant stock to be decremented to 0, got %d", stock.Quantity)
+	}
+	goods, err = repo.GoodsGet(ctx, laptop.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if goods.Quantity != 1 {
+		t.Fatalf("OrderCreate: expected goods stock to be decremented to 1, got %d", goods.Quantity)
+	}
+
+	_, err = repo.VariantUpdate(ctx, pro.VariantId, goods.Version, &dto.VariantUpdate{
+		Sku:        "LAPTOP-32GB",
+		Attributes: models.Attributes{"memory": "32GB", "color": "black"},
+		Price:      rub(6500000),
+		Quantity:   4,
+	})
+	if err != nil {
+		t.Fatalf("VariantUpdate: %v", err)
+	}
+	goods, err = repo.GoodsGet(ctx, laptop.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if goods.Quantity != 5 || goods.Variants[1].Price != rub(6500000) || goods.Variants[1].Attributes["color"] != "black" {
+		t.Fatalf("VariantUpdate: unexpected goods %+v", goods)
+	}
+	_, err = repo.VariantUpdate(ctx, missing, 1, &dto.VariantUpdate{Sku: "MISSING", Price: rub(100)})
+	expectKind(t, "VariantUpdate", err, errs.ErrNotFound)
+
+	// Вариант из оформленного заказа удалить нельзя, как и единственный вариант товара
+	_, err = repo.VariantDelete(ctx, pro.VariantId, goods.Version)
+	expectKind(t, "VariantDelete ordered", err, errs.ErrConflict)
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 1)
+	_, err = repo.VariantDelete(ctx, tablet.Variants[0].VariantId, tablet.Version)
+	expectKind(t, "VariantDelete last", err, errs.ErrValidation)
+	spare, version, err := repo.VariantAdd(ctx, tablet.GoodsId, tablet.Version, &dto.VariantCreate{
+		Sku:      "TABLET-LTE",
+		Price:    rub(900000),
+		Quantity: 2,
+	})
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+	_, err = repo.VariantDelete(ctx, spare.VariantId, version)
+	if err != nil {
+		t.Fatalf("VariantDelete: %v", err)
+	}
+	_, err = repo.VariantGet(ctx, spare.VariantId)
+	expectKind(t, "VariantGet after delete", err, errs.ErrNotFound)
+	goods, err = repo.GoodsGet(ctx, tablet.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if goods.Quantity != 1 || len(goods.Variants) != 1 {
+		t.Fatalf("VariantDelete: unexpected goods %+v", goods)
+	}
+}
+
+func testOrderCreate(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 2)
+	cartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 1, tablet.GoodsId: 2})
+
+	order, err := repo.OrderCreate(ctx, cartId, 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	if order.OrderId <= 0 || order.Total != rub(6600000) || len(order.Goods) != 2 || order.OrderTime.IsZero() {
+		t.Fatalf("OrderCreate: unexpected order %+v", order)
+	}
+
+	got, err := repo.OrderGet(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	if got.Total != order.Total || len(got.Goods) != 2 || got.FinishTime != nil {
+		t.Fatalf("OrderGet: expected %+v, got %+v", order, got)
+	}
+
+	// Цена в заказе фиксируется на момент оформления
+	_, err = repo.GoodsUpdate(
+		ctx,
+		laptop.GoodsId,
+		goodsVersion(t, repo, laptop.GoodsId),
+		&dto.GoodsUpdate{Name: laptop.Name, Price: rub(100), Quantity: 9},
+	)
+	if err != nil {
+		t.Fatalf("GoodsUpdate: %v", err)
+	}
+	got, err = repo.OrderGet(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	for _, item := range got.Goods {
+		if item.GoodsId == laptop.GoodsId && item.Price != rub(5000000) {
+			t.Fatalf("OrderGet: expected price snapshot 50000.00 RUB, got %v", item.Price)
+		}
+	}
+
+	stock, err := repo.GoodsGet(ctx, tablet.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if stock.Quantity != 0 {
+		t.Fatalf("OrderCreate: expected stock to be decremented to 0, got %d", stock.Quantity)
+	}
+	cart, err := repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(cart.Goods) != 0 {
+		t.Fatalf("OrderCreate: expected cart to be cleared, got %+v", cart)
+	}
+}
+
+func testOrderCreateInsufficientStock(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 1)
+	cartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 1, tablet.GoodsId: 2})
+
+	_, err := repo.OrderCreate(ctx, cartId, 0, "RUB")
+	expectKind(t, "OrderCreate", err, errs.ErrInsufficientStock)
+
+	stock, err := repo.GoodsGet(ctx, laptop.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if stock.Quantity != 10 {
+		t.Fatalf("OrderCreate: failed checkout must not change stock, got %d", stock.Quantity)
+	}
+	cart, err := repo.CartGetGoods(ctx, cartId, "RUB")
+	if err != nil {
+		t.Fatalf("CartGetGoods: %v", err)
+	}
+	if len(cart.Goods) != 2 {
+		t.Fatalf("OrderCreate: failed checkout must not clear cart, got %+v", cart)
+	}
+}
+
+func testOrderCreateEmptyCart(t *testing.T, repo repository.StoreRepository) {
+	cartId := createCart(t, repo, nil)
+	_, err := repo.OrderCreate(context.Background(), cartId, 0, "RUB")
+	expectKind(t, "OrderCreate", err, errs.ErrValidation)
+}
+
+// testOrderCreateConcurrent - повторная отправка одной корзины оформляет один заказ и списывает остаток один раз
+func testOrderCreateConcurrent(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	cartId := createCart(t, repo, map[int64]int64{goods.GoodsId: 3})
+
+	const submits = 4
+	results := make([]error, submits)
+	var wg sync.WaitGroup
+	for i := 0; i < submits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, results[i] = repo.OrderCreate(ctx, cartId, 0, "RUB")
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range results {
+		if err == nil {
+			created++
+			continue
+		}
+		expectKind(t, "OrderCreate", err, errs.ErrValidation)
+	}
+	if created != 1 {
+		t.Fatalf("OrderCreate: expected exactly one order from the same cart, got %d", created)
+	}
+	stock, err := repo.GoodsGet(ctx, goods.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet: %v", err)
+	}
+	if stock.Quantity != 7 || stock.Variants[0].Quantity != 7 {
+		t.Fatalf("OrderCreate: expected stock to be decremented once to 7, got %+v", stock)
+	}
+}
+
+func testOrderStatusDelete(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	order, err := repo.OrderCreate(ctx, createCart(t, repo, map[int64]int64{goods.GoodsId: 1}), 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	if order.Status != models.OrderCreated {
+		t.Fatalf("OrderCreate: expected status %s, got %s", models.OrderCreated, order.Status)
+	}
+
+	paid := &models.OrderStatusChange{
+		OrderId:   order.OrderId,
+		From:      models.OrderCreated,
+		To:        models.OrderPaid,
+		Actor:     "cashier",
+		Reason:    "paid by card",
+		ChangedAt: time.Date(2023, 3, 20, 10, 0, 0, 0, time.UTC),
+	}
+	err = repo.OrderStatusUpdate(ctx, paid, nil)
+	if err != nil {
+		t.Fatalf("OrderStatusUpdate: %v", err)
+	}
+	// Заказ уже не в статусе created
+	err = repo.OrderStatusUpdate(ctx, paid, nil)
+	expectKind(t, "OrderStatusUpdate from stale status", err, errs.ErrConflict)
+
+	refunded := &models.OrderStatusChange{
+		OrderId:   order.OrderId,
+		From:      models.OrderPaid,
+		To:        models.OrderRefunded,
+		Actor:     "support",
+		ChangedAt: time.Date(2023, 3, 20, 12, 0, 0, 0, time.UTC),
+	}
+	err = repo.OrderStatusUpdate(ctx, refunded, &refunded.ChangedAt)
+	if err != nil {
+		t.Fatalf("OrderStatusUpdate: %v", err)
+	}
+	got, err := repo.OrderGet(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	if got.Status != models.OrderRefunded || got.FinishTime == nil || !got.FinishTime.Equal(refunded.ChangedAt) {
+		t.Fatalf("OrderStatusUpdate: expected refunded order finished at %v, got %+v", refunded.ChangedAt, got)
+	}
+
+	history, err := repo.OrderStatusHistory(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderStatusHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("OrderStatusHistory: expected 2 changes, got %+v", history)
+	}
+	for i, want := range []*models.OrderStatusChange{paid, refunded} {
+		change := history[i]
+		if change.OrderId != want.OrderId || change.From != want.From || change.To != want.To ||
+			change.Actor != want.Actor || change.Reason != want.Reason || !change.ChangedAt.Equal(want.ChangedAt) {
+			t.Fatalf("OrderStatusHistory: expected %+v, got %+v", *want, change)
+		}
+	}
+
+	err = repo.OrderDelete(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderDelete: %v", err)
+	}
+	_, err = repo.OrderGet(ctx, order.OrderId)
+	expectKind(t, "OrderGet after delete", err, errs.ErrNotFound)
+	err = repo.OrderStatusUpdate(ctx, refunded, nil)
+	expectKind(t, "OrderStatusUpdate after delete", err, errs.ErrNotFound)
+	_, err = repo.OrderStatusHistory(ctx, order.OrderId)
+	expectKind(t, "OrderStatusHistory after delete", err, errs.ErrNotFound)
+	err = repo.OrderDelete(ctx, order.OrderId)
+	expectKind(t, "OrderDelete after delete", err, errs.ErrNotFound)
+}
+
+// testOrderStatusRace - из одного статуса одновременно применяется только один переход,
+// проигравший получает Conflict и не попадает в историю
+func testOrderStatusRace(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	goods := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	order, err := repo.OrderCreate(ctx, createCart(t, repo, map[int64]int64{goods.GoodsId: 1}), 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+
+	changes := []*models.OrderStatusChange{
+		{OrderId: order.OrderId, From: models.OrderCreated, To: models.OrderPaid, Actor: "cashier"},
+		{OrderId: order.OrderId, From: models.OrderCreated, To: models.OrderCancelled, Actor: "support"},
+	}
+	results := make([]error, len(changes))
+	var wg sync.WaitGroup
+	for i, change := range changes {
+		change.ChangedAt = time.Date(2023, 3, 20, 10, i, 0, 0, time.UTC)
+		wg.Add(1)
+		go func(i int, change *models.OrderStatusChange) {
+			defer wg.Done()
+			results[i] = repo.OrderStatusUpdate(ctx, change, nil)
+		}(i, change)
+	}
+	wg.Wait()
+
+	var winner *models.OrderStatusChange
+	for i, err := range results {
+		if err == nil {
+			if winner != nil {
+				t.Fatalf("OrderStatusUpdate: expected one transition from %s, both applied", models.OrderCreated)
+			}
+			winner = changes[i]
+			continue
+		}
+		expectKind(t, "OrderStatusUpdate losing the race", err, errs.ErrConflict)
+	}
+	if winner == nil {
+		t.Fatalf("OrderStatusUpdate: expected one transition to apply, got %v", results)
+	}
+	got, err := repo.OrderGet(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderGet: %v", err)
+	}
+	if got.Status != winner.To {
+		t.Fatalf("OrderStatusUpdate: expected status %s, got %s", winner.To, got.Status)
+	}
+	history, err := repo.OrderStatusHistory(ctx, order.OrderId)
+	if err != nil {
+		t.Fatalf("OrderStatusHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].To != winner.To || history[0].Actor != winner.Actor ||
+		!history[0].ChangedAt.Equal(winner.ChangedAt) {
+		t.Fatalf("OrderStatusHistory: expected only %+v, got %+v", *winner, history)
+	}
+}
+
+func testOrderRestock(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	pro, _, err := repo.VariantAdd(ctx, laptop.GoodsId, laptop.Version, &dto.VariantCreate{
+		Sku:      "LAPTOP-32GB",
+		Price:    rub(7000000),
+		Quantity: 5,
+	})
+	if err != nil {
+		t.Fatalf("VariantAdd: %v", err)
+	}
+	tablet := addGoods(t, repo, "Планшет", rub(800000), 4)
+	mouse := addGoods(t, repo, "Мышь", rub(49999), 7)
+
+	cart, err := repo.CartCreate(ctx, 0)
+	if err != nil {
+		t.Fatalf("CartCreate: %v", err)
+	}
+	for _, line := range []dto.GoodsAdd{
+		{GoodsId: laptop.GoodsId, VariantId: pro.VariantId, Quantity: 3},
+		{GoodsId: tablet.GoodsId, VariantId: tablet.Variants[0].VariantId, Quantity: 2},
+	} {
+		line := line
+		cart.Version, err = repo.CartAddGoods(ctx, cart.CartId, cart.Version, &line)
+		if err != nil {
+			t.Fatalf("CartAddGoods: %v", err)
+		}
+	}
+	order, err := repo.OrderCreate(ctx, cart.CartId, 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	version := goodsVersion(t, repo, laptop.GoodsId)
+
+	// stock - остатки товара и его вариантов по variant_id
+	stock := func(goodsId int64) (int64, map[int64]int64) {
+		t.Helper()
+		goods, err := repo.GoodsGet(ctx, goodsId)
+		if err != nil {
+			t.Fatalf("GoodsGet: %v", err)
+		}
+		variants := make(map[int64]int64, len(goods.Variants))
+		for _, variant := range goods.Variants {
+			variants[variant.VariantId] = variant.Quantity
+		}
+		return goods.Quantity, variants
+	}
+	expectStock := func(op string, goodsId, want int64, wantVariants map[int64]int64) {
+		t.Helper()
+		got, variants := stock(goodsId)
+		if got != want || !reflect.DeepEqual(variants, wantVariants) {
+			t.Fatalf("%s: expected goods with id %d to have %d in stock with variants %v, got %d with %v",
+				op, goodsId, want, wantVariants, got, variants)
+		}
+	}
+	base := laptop.Variants[0].VariantId
+	expectStock("OrderCreate", laptop.GoodsId, 12, map[int64]int64{base: 10, pro.VariantId: 2})
+	expectStock("OrderCreate", tablet.GoodsId, 2, map[int64]int64{tablet.Variants[0].VariantId: 2})
+
+	cancel := &models.OrderStatusChange{
+		OrderId:   order.OrderId,
+		From:      models.OrderCreated,
+		To:        models.OrderCancelled,
+		Actor:     "support",
+		Reason:    "customer changed mind",
+		ChangedAt: time.Date(2023, 3, 20, 10, 0, 0, 0, time.UTC),
+	}
+	err = repo.WithTx(ctx, func(txRepo repository.StoreRepository) error {
+		err := txRepo.OrderStatusUpdate(ctx, cancel, nil)
+		if err != nil {
+			return err
+		}
+		return txRepo.OrderRestock(ctx, order.OrderId)
+	})
+	if err != nil {
+		t.Fatalf("OrderRestock: %v", err)
+	}
+	// Возвращаются только заказанные варианты, остальные варианты и товары не меняются
+	expectStock("OrderRestock", laptop.GoodsId, 15, map[int64]int64{base: 10, pro.VariantId: 5})
+	expectStock("OrderRestock", tablet.GoodsId, 4, map[int64]int64{tablet.Variants[0].VariantId: 4})
+	expectStock("OrderRestock", mouse.GoodsId, 7, map[int64]int64{mouse.Variants[0].VariantId: 7})
+	if got := goodsVersion(t, repo, laptop.GoodsId); got != version+1 {
+		t.Fatalf("OrderRestock: expected goods version %d, got %d", version+1, got)
+	}
+	if got := goodsVersion(t, repo, mouse.GoodsId); got != mouse.Version {
+		t.Fatalf("OrderRestock: expected goods outside the order to keep version %d, got %d", mouse.Version, got)
+	}
+
+	// Повторная отмена отклоняется условием на статус, поэтому остатки не возвращаются дважды
+	err = repo.WithTx(ctx, func(txRepo repository.StoreRepository) error {
+		err := txRepo.OrderStatusUpdate(ctx, cancel, nil)
+		if err != nil {
+			return err
+		}
+		return txRepo.OrderRestock(ctx, order.OrderId)
+	})
+	expectKind(t, "OrderStatusUpdate of cancelled order", err, errs.ErrConflict)
+	expectStock("second cancel", laptop.GoodsId, 15, map[int64]int64{base: 10, pro.VariantId: 5})
+	expectStock("second cancel", tablet.GoodsId, 4, map[int64]int64{tablet.Variants[0].VariantId: 4})
+
+	// Возврат денег за оплаченный заказ возвращает его позиции на склад так же, как отмена
+	cartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 4})
+	refunded, err := repo.OrderCreate(ctx, cartId, 0, "RUB")
+	if err != nil {
+		t.Fatalf("OrderCreate: %v", err)
+	}
+	expectStock("OrderCreate", laptop.GoodsId, 11, map[int64]int64{base: 6, pro.VariantId: 5})
+	for _, change := range []models.OrderStatusChange{
+		{From: models.OrderCreated, To: models.OrderPaid},
+		{From: models.OrderPaid, To: models.OrderRefunded},
+	} {
+		change := change
+		change.OrderId = refunded.OrderId
+		change.Actor = "support"
+		change.ChangedAt = time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC)
+		err = repo.WithTx(ctx, func(txRepo repository.StoreRepository) error {
+			err := txRepo.OrderStatusUpdate(ctx, &change, nil)
+			if err != nil || change.To != models.OrderRefunded {
+				return err
+			}
+			return txRepo.OrderRestock(ctx, refunded.OrderId)
+		})
+		if err != nil {
+			t.Fatalf("OrderStatusUpdate to %s: %v", change.To, err)
+		}
+	}
+	expectStock("OrderRestock of refunded order", laptop.GoodsId, 15, map[int64]int64{base: 10, pro.VariantId: 5})
+
+	err = repo.OrderRestock(ctx, order.OrderId+1000)
+	expectKind(t, "OrderRestock of missing order", err, errs.ErrNotFound)
+}
+
+func testOrderList(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	laptop := addGoods(t, repo, "Ноутбук", rub(5000000), 10)
+	mouse := addGoods(t, repo, "Мышь", rub(150000), 10)
+	customer := addCustomer(t, repo, "buyer@example.com")
+	orders := make([]*models.Order, 0, 3)
+	for _, customerId := range []int64{customer.CustomerId, 0, customer.CustomerId} {
+		cartId := createCart(t, repo, map[int64]int64{laptop.GoodsId: 1, mouse.GoodsId: 2})
+		order, err := repo.OrderCreate(ctx, cartId, customerId, "RUB")
+		if err != nil {
+			t.Fatalf("OrderCreate: %v", err)
+		}
+		orders = append(orders, order)
+	}
+	if orders[0].CustomerId == nil || *orders[0].CustomerId != customer.CustomerId || orders[1].CustomerId != nil {
+		t.Fatalf(
+			"OrderCreate: expected customer %d and no customer, got %+v and %+v",
+			customer.CustomerId,
+			orders[0],
+			orders[1],
+		)
+	}
+
+	// Заказы покупателя по одному на странице, от новых к старым
+	filter := &dto.OrderFilter{CustomerId: customer.CustomerId, Limit: 1}
+	got := make([]models.OrderSummary, 0)
+	for {
+		page, err := repo.OrderList(ctx, filter)
+		if err != nil {
+			t.Fatalf("OrderList: %v", err)
+		}
+		got = append(got, page.Orders...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.After, err = dto.DecodeOrderCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeOrderCursor: %v", err)
+		}
+	}
+	if len(got) != 2 || got[0].OrderId != orders[2].OrderId || got[1].OrderId != orders[0].OrderId {
+		t.Fatalf("OrderList: expected orders %d and %d of customer, got %+v", orders[2].OrderId, orders[0].OrderId, got)
+	}
+	summary := got[0]
+	if summary.ItemCount != 2 || summary.Quantity != 3 || summary.Total != orders[2].Total ||
+		summary.Status != models.OrderCreated || !summary.OrderTime.Equal(orders[2].OrderTime) {
+		t.Fatalf("OrderList: expected header of order %+v with 2 items and 3 units, got %+v", *orders[2], summary)
+	}
+
+	// Границы времени: from включительно, to не включительно
+	page, err := repo.OrderList(ctx, &dto.OrderFilter{From: &orders[1].OrderTime, To: &orders[2].OrderTime, Limit: 10})
+	if err != nil {
+		t.Fatalf("OrderList: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].OrderId != orders[1].OrderId || page.NextCursor != "" {
+		t.Fatalf("OrderList: expected only order %d in time range, got %+v", orders[1].OrderId, page)
+	}
+
+	err = repo.OrderStatusUpdate(ctx, &models.OrderStatusChange{
+		OrderId:   orders[0].OrderId,
+		From:      models.OrderCreated,
+		To:        models.OrderPaid,
+		Actor:     "cashier",
+		ChangedAt: time.Date(2023, 3, 20, 10, 0, 0, 0, time.UTC),
+	}, nil)
+	if err != nil {
+		t.Fatalf("OrderStatusUpdate: %v", err)
+	}
+	page, err = repo.OrderList(ctx, &dto.OrderFilter{Status: models.OrderPaid, Limit: 10})
+	if err != nil {
+		t.Fatalf("OrderList: %v", err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].OrderId != orders[0].OrderId || page.Orders[0].Status != models.OrderPaid {
+		t.Fatalf("OrderList: expected only paid order %d, got %+v", orders[0].OrderId, page)
+	}
+
+	other := addCustomer(t, repo, "other@example.com")
+	page, err = repo.OrderList(ctx, &dto.OrderFilter{CustomerId: other.CustomerId, Limit: 10})
+	if err != nil {
+		t.Fatalf("OrderList: %v", err)
+	}
+	if len(page.Orders) != 0 {
+		t.Fatalf("OrderList: expected no orders of another customer, got %+v", page)
+	}
+}
+
+func testWithTx(t *testing.T, repo repository.StoreRepository) {
+	ctx := context.Background()
+	rollback := errors.New("rollback")
+	var rolledBack, committed *models.Goods
+
+	err := repo.WithTx(ctx, func(txRepo repository.StoreRepository) error {
+		var err error
+		rolledBack, err = txRepo.GoodsAdd(ctx, &dto.GoodsCreate{Name: "Откат", Price: rub(100), Quantity: 1})
+		if err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("WithTx: expected fn error to be returned, got %v", err)
+	}
+	_, err = repo.GoodsGet(ctx, rolledBack.GoodsId)
+	expectKind(t, "GoodsGet after rollback", err, errs.ErrNotFound)
+
+	err = repo.WithTx(ctx, func(txRepo repository.StoreRepository) error {
+		var err error
+		committed, err = txRepo.GoodsAdd(ctx, &dto.GoodsCreate{Name: "Коммит", Price: rub(100), Quantity: 1})
+		if err != nil {
+			return err
+		}
+		_, err = txRepo.GoodsUpdate(
+			ctx,
+			committed.GoodsId,
+			committed.Version,
+			&dto.GoodsUpdate{Name: "Коммит", Price: rub(200), Quantity: 2},
+		)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	got, err := repo.GoodsGet(ctx, committed.GoodsId)
+	if err != nil {
+		t.Fatalf("GoodsGet after commit: %v", err)
+	}
+	if got.Price != rub(200) || got.Quantity != 2 {
+		t.Fatalf("WithTx: expected committed changes, got %+v", got)
+	}
+}
@@ -0,0 +1,9 @@
+// Package migrations - SQL миграции схемы БД, встроенные в бинарник
+package migrations
+
+import "embed"
+
+// Postgres - миграции для postgres в формате NNNNNN_name.{up,down}.sql в каталоге postgresql
+//
+//go:embed postgresql/*.sql
+var Postgres embed.FS
@@ -7,9 +7,6 @@ create table if not exists public.goods
     quantity integer     not null
 );
 
-alter table public.goods
-    owner to postgres;
-
 create table if not exists public.carts
 (
     cart_id integer not null
@@ -17,9 +14,6 @@ create table if not exists public.carts
     total   integer not null
 );
 
-alter table public.carts
-    owner to postgres;
-
 create table if not exists public.orders
 (
     order_id    integer                  not null
@@ -29,9 +23,6 @@ create table if not exists public.orders
     finish_time timestamp with time zone
 );
 
-alter table public.orders
-    owner to postgres;
-
 create table if not exists public.goods_to_orders
 (
     order_id integer not null
@@ -44,9 +35,6 @@ create table if not exists public.goods_to_orders
     primary key (order_id, goods_id)
 );
 
-alter table public.goods_to_orders
-    owner to postgres;
-
 create table if not exists public.goods_to_carts
 (
     cart_id  integer not null
@@ -59,6 +47,3 @@ create table if not exists public.goods_to_carts
     primary key (cart_id, goods_id)
 );
 
-alter table public.goods_to_carts
-    owner to postgres;
-
@@ -0,0 +1,11 @@
+alter table public.goods_to_orders
+    drop column if exists price;
+
+alter table public.carts
+    add column if not exists total integer not null default 0;
+
+update public.carts c
+set total = coalesce((select sum(gc.quantity * g.price)
+                      from public.goods_to_carts gc
+                               join public.goods g on g.goods_id = gc.goods_id
+                      where gc.cart_id = c.cart_id), 0);
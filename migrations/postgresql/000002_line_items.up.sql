@@ -0,0 +1,13 @@
+alter table public.carts
+    drop column if exists total;
+
+alter table public.goods_to_orders
+    add column if not exists price integer not null default 0;
+
+update public.goods_to_orders gto
+set price = g.price
+from public.goods g
+where g.goods_id = gto.goods_id;
+
+alter table public.goods_to_orders
+    alter column price drop default;
@@ -0,0 +1,8 @@
+alter table public.orders
+    alter column order_id drop identity if exists;
+
+alter table public.carts
+    alter column cart_id drop identity if exists;
+
+alter table public.goods
+    alter column goods_id drop identity if exists;
@@ -0,0 +1,17 @@
+alter table public.goods
+    alter column goods_id add generated by default as identity;
+
+select setval(pg_get_serial_sequence('public.goods', 'goods_id'), coalesce(max(goods_id), 0) + 1, false)
+from public.goods;
+
+alter table public.carts
+    alter column cart_id add generated by default as identity;
+
+select setval(pg_get_serial_sequence('public.carts', 'cart_id'), coalesce(max(cart_id), 0) + 1, false)
+from public.carts;
+
+alter table public.orders
+    alter column order_id add generated by default as identity;
+
+select setval(pg_get_serial_sequence('public.orders', 'order_id'), coalesce(max(order_id), 0) + 1, false)
+from public.orders;
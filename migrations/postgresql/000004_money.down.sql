@@ -0,0 +1,14 @@
+alter table public.goods_to_orders
+    alter column price type integer using (price / 100)::integer;
+
+alter table public.orders
+    drop column if exists currency;
+
+alter table public.orders
+    alter column total type integer using (total / 100)::integer;
+
+alter table public.goods
+    drop column if exists currency;
+
+alter table public.goods
+    alter column price type integer using (price / 100)::integer;
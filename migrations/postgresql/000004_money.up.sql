@@ -0,0 +1,14 @@
+alter table public.goods
+    alter column price type bigint using price::bigint * 100;
+
+alter table public.goods
+    add column if not exists currency char(3) not null default 'RUB';
+
+alter table public.orders
+    alter column total type bigint using total::bigint * 100;
+
+alter table public.orders
+    add column if not exists currency char(3) not null default 'RUB';
+
+alter table public.goods_to_orders
+    alter column price type bigint using price::bigint * 100;
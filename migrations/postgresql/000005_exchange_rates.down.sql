@@ -0,0 +1,4 @@
+alter table public.orders
+    drop column if exists exchange_rate;
+
+drop table if exists public.exchange_rates;
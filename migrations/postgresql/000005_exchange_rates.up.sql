@@ -0,0 +1,12 @@
+create table if not exists public.exchange_rates
+(
+    currency   char(3)                  not null
+        primary key,
+    rate       numeric(20, 10)          not null
+        constraint chk_exchange_rates__rate
+            check (rate > 0),
+    updated_at timestamp with time zone not null default now()
+);
+
+alter table public.orders
+    add column if not exists exchange_rate numeric(20, 10) not null default 1;
@@ -0,0 +1,3 @@
+drop index if exists public.idx_goods__name_goods_id;
+
+drop index if exists public.idx_goods__price_goods_id;
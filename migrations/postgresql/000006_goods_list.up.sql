@@ -0,0 +1,5 @@
+create index if not exists idx_goods__price_goods_id
+    on public.goods (price, goods_id);
+
+create index if not exists idx_goods__name_goods_id
+    on public.goods (name collate "C", goods_id);
@@ -0,0 +1,4 @@
+drop index if exists public.idx_goods__search;
+
+alter table public.goods
+    drop column if exists search;
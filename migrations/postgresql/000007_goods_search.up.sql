@@ -0,0 +1,9 @@
+alter table public.goods
+    add column if not exists search tsvector
+        generated always as (
+            setweight(to_tsvector('russian', coalesce(name, '')), 'A') ||
+            setweight(to_tsvector('english', coalesce(name, '')), 'B')
+        ) stored;
+
+create index if not exists idx_goods__search
+    on public.goods using gin (search);
@@ -0,0 +1,3 @@
+drop table if exists public.goods_to_categories;
+
+drop table if exists public.categories;
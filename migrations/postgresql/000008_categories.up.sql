@@ -0,0 +1,28 @@
+create table if not exists public.categories
+(
+    category_id integer generated by default as identity
+        primary key,
+    parent_id   integer
+        constraint fk_categories__parent_id
+            references public.categories,
+    name        varchar(60) not null
+);
+
+create index if not exists idx_categories__parent_id
+    on public.categories (parent_id);
+
+create table if not exists public.goods_to_categories
+(
+    category_id integer not null
+        constraint fk_goods_to_categories__category_id
+            references public.categories
+            on delete cascade,
+    goods_id    integer not null
+        constraint fk_goods_to_categories__goods_id
+            references public.goods
+            on delete cascade,
+    primary key (category_id, goods_id)
+);
+
+create index if not exists idx_goods_to_categories__goods_id
+    on public.goods_to_categories (goods_id);
@@ -0,0 +1,40 @@
+-- Позиции разных вариантов одного товара схлопываются в одну позицию товара
+update public.goods_to_orders gto
+set quantity = s.quantity
+from (select order_id, goods_id, sum(quantity) as quantity, min(variant_id) as variant_id
+      from public.goods_to_orders
+      group by order_id, goods_id) s
+where s.order_id = gto.order_id
+  and s.variant_id = gto.variant_id;
+
+delete from public.goods_to_orders a
+    using public.goods_to_orders b
+where a.order_id = b.order_id
+  and a.goods_id = b.goods_id
+  and a.variant_id > b.variant_id;
+
+alter table public.goods_to_orders
+    drop constraint if exists goods_to_orders_pkey,
+    drop column if exists variant_id,
+    add primary key (order_id, goods_id);
+
+update public.goods_to_carts gc
+set quantity = s.quantity
+from (select cart_id, goods_id, sum(quantity) as quantity, min(variant_id) as variant_id
+      from public.goods_to_carts
+      group by cart_id, goods_id) s
+where s.cart_id = gc.cart_id
+  and s.variant_id = gc.variant_id;
+
+delete from public.goods_to_carts a
+    using public.goods_to_carts b
+where a.cart_id = b.cart_id
+  and a.goods_id = b.goods_id
+  and a.variant_id > b.variant_id;
+
+alter table public.goods_to_carts
+    drop constraint if exists goods_to_carts_pkey,
+    drop column if exists variant_id,
+    add primary key (cart_id, goods_id);
+
+drop table if exists public.variants;
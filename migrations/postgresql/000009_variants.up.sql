@@ -0,0 +1,57 @@
+create table if not exists public.variants
+(
+    variant_id integer generated by default as identity
+        primary key,
+    goods_id   integer     not null
+        constraint fk_variants__goods_id
+            references public.goods
+            on delete cascade,
+    sku        varchar(64) not null
+        constraint uq_variants__sku
+            unique,
+    attributes jsonb       not null default '{}'::jsonb,
+    price      bigint      not null,
+    currency   char(3)     not null,
+    quantity   integer     not null
+        constraint chk_variants__quantity
+            check (quantity >= 0)
+);
+
+create index if not exists idx_variants__goods_id
+    on public.variants (goods_id);
+
+-- Каждый существующий товар получает вариант по умолчанию с его ценой и остатком
+insert into public.variants (goods_id, sku, price, currency, quantity)
+select goods_id, 'GOODS-' || goods_id, price, currency, quantity
+from public.goods
+order by goods_id;
+
+alter table public.goods_to_carts
+    add column if not exists variant_id integer;
+
+update public.goods_to_carts gc
+set variant_id = v.variant_id
+from public.variants v
+where v.goods_id = gc.goods_id;
+
+alter table public.goods_to_carts
+    alter column variant_id set not null,
+    add constraint fk_goods_to_carts__variant_id
+        foreign key (variant_id) references public.variants,
+    drop constraint if exists goods_to_carts_pkey,
+    add primary key (cart_id, variant_id);
+
+alter table public.goods_to_orders
+    add column if not exists variant_id integer;
+
+update public.goods_to_orders gto
+set variant_id = v.variant_id
+from public.variants v
+where v.goods_id = gto.goods_id;
+
+alter table public.goods_to_orders
+    alter column variant_id set not null,
+    add constraint fk_goods_to_orders__variant_id
+        foreign key (variant_id) references public.variants,
+    drop constraint if exists goods_to_orders_pkey,
+    add primary key (order_id, variant_id);
@@ -0,0 +1,5 @@
+alter table public.carts
+    drop column if exists version;
+
+alter table public.goods
+    drop column if exists version;
@@ -0,0 +1,5 @@
+alter table public.goods
+    add column if not exists version integer not null default 1;
+
+alter table public.carts
+    add column if not exists version integer not null default 1;
@@ -0,0 +1,4 @@
+drop table if exists public.order_status_history;
+
+alter table public.orders
+    drop column if exists status;
@@ -0,0 +1,22 @@
+alter table public.orders
+    add column if not exists status varchar(16) not null default 'created'
+        constraint chk_orders__status
+            check (status in ('created', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
+
+create table if not exists public.order_status_history
+(
+    history_id  integer generated by default as identity
+        primary key,
+    order_id    integer                  not null
+        constraint fk_order_status_history__order_id
+            references public.orders
+            on delete cascade,
+    from_status varchar(16)              not null,
+    to_status   varchar(16)              not null,
+    actor       varchar(100)             not null,
+    reason      text                     not null default '',
+    changed_at  timestamp with time zone not null default now()
+);
+
+create index if not exists idx_order_status_history__order_id
+    on public.order_status_history (order_id, changed_at);
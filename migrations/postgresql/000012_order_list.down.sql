@@ -0,0 +1,6 @@
+drop index if exists public.idx_orders__order_time;
+
+drop index if exists public.idx_orders__customer_id_order_time;
+
+alter table public.orders
+    drop column if exists customer_id;
@@ -0,0 +1,8 @@
+alter table public.orders
+    add column if not exists customer_id integer;
+
+create index if not exists idx_orders__customer_id_order_time
+    on public.orders (customer_id, order_time desc, order_id desc);
+
+create index if not exists idx_orders__order_time
+    on public.orders (order_time desc, order_id desc);
@@ -0,0 +1,9 @@
+drop index if exists public.idx_carts__customer_id;
+
+alter table public.carts
+    drop column if exists customer_id;
+
+alter table public.orders
+    drop constraint if exists fk_orders__customer_id;
+
+drop table if exists public.customers;
@@ -0,0 +1,32 @@
+create table if not exists public.customers
+(
+    customer_id integer generated by default as identity
+        primary key,
+    email       varchar(254)             not null
+        constraint uq_customers__email
+            unique,
+    name        varchar(100)             not null,
+    created_at  timestamp with time zone not null default now()
+);
+
+-- Заказы, оформленные до появления таблицы покупателей, получают покупателей без профиля
+insert into public.customers (customer_id, email, name)
+select distinct customer_id, 'customer-' || customer_id || '@unknown', ''
+from public.orders
+where customer_id is not null
+on conflict do nothing;
+
+select setval(pg_get_serial_sequence('public.customers', 'customer_id'), coalesce(max(customer_id), 0) + 1, false)
+from public.customers;
+
+alter table public.orders
+    add constraint fk_orders__customer_id
+        foreign key (customer_id) references public.customers;
+
+alter table public.carts
+    add column if not exists customer_id integer
+        constraint fk_carts__customer_id
+            references public.customers;
+
+create index if not exists idx_carts__customer_id
+    on public.carts (customer_id);